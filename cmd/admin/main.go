@@ -0,0 +1,555 @@
+// admin 運営向けの管理コマンド
+//
+//	# 2024-01-01より前に作成した全ユーザに100コインを送る対象者の人数を確認する
+//	$ go run ./cmd/admin compensate -operator alice -message "メンテナンスのお詫び" -coin 100 -created-before 2024-01-01 -dry-run
+//	# CSVに記載したユーザにアイテムを送る
+//	$ go run ./cmd/admin compensate -operator alice -message "不具合のお詫び" -item 1 -item-count 1 -user-ids-file ids.csv
+//	# 失敗・中断した補填を続きから再開する
+//	$ go run ./cmd/admin resume -id 1
+//	# 補填の進捗・一覧を確認する
+//	$ go run ./cmd/admin status -id 1
+//	$ go run ./cmd/admin list
+//	# 1回のみ使えるコードを1000件発行してCSVに書き出す
+//	$ go run ./cmd/admin coupon-create -name "春のキャンペーン" -kind single -count 1000 -coin 100 -ends-at 2024-05-01 -out codes.csv
+//	# 全員が使える共有コードを作成する
+//	$ go run ./cmd/admin coupon-create -name "1周年記念" -kind shared -code ANNIV2024 -ticket gacha -ticket-count 1
+//	# 既存のキャンペーンにコードを追加で発行する・発行済みのコードを書き出す
+//	$ go run ./cmd/admin coupon-issue -campaign-id 1 -count 500 -out more.csv
+//	$ go run ./cmd/admin coupon-export -campaign-id 1 -out codes.csv
+//	# 基準日時点の有償コインの未使用残高を集計する
+//	$ go run ./cmd/admin currency-report -from 2023-10-01T00:00:00+09:00 -to 2024-04-01T00:00:00+09:00
+//	# 削除の猶予期間を過ぎたユーザを削除する(cronなどで定期実行する)
+//	$ go run ./cmd/admin user-purge
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	_ "github.com/go-sql-driver/mysql"
+
+	"42tokyo-road-to-dojo-go/pkg/account"
+	"42tokyo-road-to-dojo-go/pkg/compensation"
+	"42tokyo-road-to-dojo-go/pkg/coupon"
+	"42tokyo-road-to-dojo-go/pkg/report"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const usage = `usage: admin <command> [flags]
+
+commands:
+  compensate       一括補填を作成して送付する
+  resume           失敗・中断した補填を続きから再開する
+  status           補填の内容と進捗を表示する
+  list             補填の一覧を表示する
+  coupon-create    クーポンのキャンペーンを作成してコードを発行する
+  coupon-issue     既存のキャンペーンにコードを追加で発行する
+  coupon-export    キャンペーンのコードをCSVに書き出す
+  currency-report  有償コインの発行額・消費額と未使用残高を集計する
+  user-purge       削除の猶予期間を過ぎたユーザを削除する`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "compensate":
+		err = runCompensate(os.Args[2:])
+	case "resume":
+		err = runResume(os.Args[2:])
+	case "status":
+		err = runStatus(os.Args[2:])
+	case "list":
+		err = runList(os.Args[2:])
+	case "coupon-create":
+		err = runCouponCreate(os.Args[2:])
+	case "coupon-issue":
+		err = runCouponIssue(os.Args[2:])
+	case "coupon-export":
+		err = runCouponExport(os.Args[2:])
+	case "currency-report":
+		err = runCurrencyReport(os.Args[2:])
+	case "user-purge":
+		err = runUserPurge(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 各コマンド共通の接続先のフラグ
+type connectionFlags struct {
+	dsn       string
+	redisAddr string
+}
+
+func (c *connectionFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.dsn, "dsn", "root:ca-tech-dojo@tcp(localhost:3306)/CA_Tech_Dojo", "MySQL data source name")
+	fs.StringVar(&c.redisAddr, "redis-addr", "localhost:6379", "redis host:port")
+}
+
+// DBに接続してリポジトリを作成する。管理コマンドはマスターデータをDBから直接読むため、Redisには接続しない
+func (c *connectionFlags) connect() (*repositories.Repositories, func(), error) {
+	db, err := sql.Open("mysql", c.dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: c.redisAddr})
+	closeFunc := func() {
+		rdb.Close()
+		db.Close()
+	}
+	return repositories.NewRepositories(db, rdb), closeFunc, nil
+}
+
+// 付与する報酬のフラグ
+type rewardFlags struct {
+	coin        int64
+	itemID      int64
+	itemCount   int64
+	ticketType  string
+	ticketCount int64
+}
+
+func (r *rewardFlags) register(fs *flag.FlagSet) {
+	fs.Int64Var(&r.coin, "coin", 0, "coins to grant")
+	fs.Int64Var(&r.itemID, "item", 0, "item (collection) id to grant")
+	fs.Int64Var(&r.itemCount, "item-count", 0, "number of items to grant")
+	fs.StringVar(&r.ticketType, "ticket", "", "ticket type to grant")
+	fs.Int64Var(&r.ticketCount, "ticket-count", 0, "number of tickets to grant")
+}
+
+func (r *rewardFlags) reward() entities.Reward {
+	reward := entities.Reward{Coin: entities.Coin(r.coin), ItemCount: entities.ItemCount(r.itemCount), TicketCount: entities.TicketCount(r.ticketCount)}
+	if r.itemID != 0 {
+		id := entities.ItemID(r.itemID)
+		reward.ItemID = &id
+	}
+	if r.ticketType != "" {
+		t := entities.TicketType(r.ticketType)
+		reward.TicketType = &t
+	}
+	return reward
+}
+
+// 日付(2006-01-02)またはRFC3339形式の日時を解釈する。空の場合はnilを返す
+func parseTimeFlag(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("-%s must be YYYY-MM-DD or RFC3339: %q", name, value)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// 補填を送付し、バッチごとに進捗を表示する。Ctrl-Cで中断した場合は再開できる
+func process(runner *compensation.Runner, c *entities.Compensation) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := runner.Process(ctx, c, func(c *entities.Compensation) {
+		log.Printf("compensation %d: granted %d/%d (last user id %d)", c.ID, c.GrantedCount, c.TargetCount, c.LastUserID)
+	})
+	if err != nil {
+		return fmt.Errorf("compensation %d stopped after %d grants, run `admin resume -id %d` to continue: %w", c.ID, c.GrantedCount, c.ID, err)
+	}
+	log.Printf("compensation %d completed: granted %d", c.ID, c.GrantedCount)
+	return nil
+}
+
+func runCompensate(args []string) error {
+	fs := flag.NewFlagSet("compensate", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	operator := fs.String("operator", os.Getenv("USER"), "operator name recorded in the audit log")
+	reason := fs.String("reason", "", "reason recorded in the audit log")
+	message := fs.String("message", "", "message shown in the present box")
+	var reward rewardFlags
+	reward.register(fs)
+	expiresAt := fs.String("expires-at", "", "expiry of the presents (YYYY-MM-DD or RFC3339)")
+	createdBefore := fs.String("created-before", "", "target users created before this time (YYYY-MM-DD or RFC3339)")
+	createdAfter := fs.String("created-after", "", "target users created at or after this time (YYYY-MM-DD or RFC3339)")
+	userIDsFile := fs.String("user-ids-file", "", "CSV file whose first column is the target user ids")
+	allUsers := fs.Bool("all-users", false, "target every user")
+	batchSize := fs.Int("batch-size", compensation.DefaultBatchSize, "users per transaction")
+	dryRun := fs.Bool("dry-run", false, "only count the target users")
+	fs.Parse(args)
+
+	c := entities.Compensation{
+		Operator: *operator,
+		Reason:   *reason,
+		Message:  *message,
+		Reward:   reward.reward(),
+		Target:   entities.CompensationTarget{AllUsers: *allUsers},
+	}
+	var err error
+	if c.PresentExpiresAt, err = parseTimeFlag("expires-at", *expiresAt); err != nil {
+		return err
+	}
+	if c.Target.CreatedBefore, err = parseTimeFlag("created-before", *createdBefore); err != nil {
+		return err
+	}
+	if c.Target.CreatedAfter, err = parseTimeFlag("created-after", *createdAfter); err != nil {
+		return err
+	}
+	if *userIDsFile != "" {
+		f, err := os.Open(*userIDsFile)
+		if err != nil {
+			return err
+		}
+		c.Target.UserIDs, err = compensation.ReadUserIDs(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", *userIDsFile, err)
+		}
+		if len(c.Target.UserIDs) == 0 {
+			return fmt.Errorf("%s: no user ids", *userIDsFile)
+		}
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	items, err := repos.ItemRepository.GetItems()
+	if err != nil {
+		return err
+	}
+	if err := compensation.Validate(&c, *items); err != nil {
+		return err
+	}
+
+	runner := compensation.NewRunner(repos, *batchSize)
+	if *dryRun {
+		count, err := runner.Count(&c.Target)
+		if err != nil {
+			return err
+		}
+		log.Printf("dry run: %d users would receive the compensation", count)
+		return nil
+	}
+
+	if err := runner.Create(&c); err != nil {
+		return err
+	}
+	log.Printf("compensation %d created for %d users", c.ID, c.TargetCount)
+	started, err := runner.Start(c.ID)
+	if err != nil {
+		return err
+	}
+	return process(runner, started)
+}
+
+func runResume(args []string) error {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	id := fs.Int64("id", 0, "compensation id")
+	batchSize := fs.Int("batch-size", compensation.DefaultBatchSize, "users per transaction")
+	fs.Parse(args)
+	if *id < 1 {
+		return errors.New("-id is required")
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	runner := compensation.NewRunner(repos, *batchSize)
+	started, err := runner.Start(entities.CompensationID(*id))
+	if err != nil {
+		return err
+	}
+	log.Printf("resuming compensation %d from user id %d (%d/%d granted)", started.ID, started.LastUserID, started.GrantedCount, started.TargetCount)
+	return process(runner, started)
+}
+
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	id := fs.Int64("id", 0, "compensation id")
+	fs.Parse(args)
+	if *id < 1 {
+		return errors.New("-id is required")
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	c, err := repos.CompensationRepository.GetCompensationByID(entities.CompensationID(*id))
+	if err != nil {
+		return err
+	}
+	return printJSON(c)
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	limit := fs.Int("limit", 20, "number of compensations to show")
+	fs.Parse(args)
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	compensations, err := repos.CompensationRepository.GetCompensations(*limit)
+	if err != nil {
+		return err
+	}
+	return printJSON(compensations)
+}
+
+// コードを書き出すCSVファイルを作成する。pathが"-"の場合は標準出力に書き出す
+// 発行したコードを書き出せなくならないよう、コードの発行より先に作成する。既存のファイルは上書きしない
+func createCouponCSV(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+func newCouponCodes(campaignID entities.CouponCampaignID, codes []string) []entities.CouponCode {
+	couponCodes := make([]entities.CouponCode, len(codes))
+	for i, code := range codes {
+		couponCodes[i] = entities.CouponCode{Code: code, CampaignID: campaignID}
+	}
+	return couponCodes
+}
+
+func runCouponCreate(args []string) error {
+	fs := flag.NewFlagSet("coupon-create", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	name := fs.String("name", "", "campaign name")
+	kind := fs.String("kind", string(entities.CouponKindSingle), "single (one use per code) or shared (one code for many users)")
+	count := fs.Int("count", 0, "number of single-use codes to generate")
+	code := fs.String("code", "", "code for a shared campaign")
+	perUserLimit := fs.Int64("per-user-limit", 1, "redemptions allowed per user in the campaign")
+	totalLimit := fs.Int64("total-limit", 0, "total redemptions allowed for a shared code (0 for unlimited)")
+	startsAt := fs.String("starts-at", "", "start of the validity window (YYYY-MM-DD or RFC3339)")
+	endsAt := fs.String("ends-at", "", "end of the validity window (YYYY-MM-DD or RFC3339)")
+	out := fs.String("out", "-", "CSV file to write the codes to (- for stdout)")
+	var reward rewardFlags
+	reward.register(fs)
+	fs.Parse(args)
+
+	campaign := entities.CouponCampaign{
+		Name:         *name,
+		Kind:         entities.CouponKind(*kind),
+		Reward:       reward.reward(),
+		PerUserLimit: *perUserLimit,
+		TotalLimit:   *totalLimit,
+	}
+	var err error
+	if campaign.StartsAt, err = parseTimeFlag("starts-at", *startsAt); err != nil {
+		return err
+	}
+	if campaign.EndsAt, err = parseTimeFlag("ends-at", *endsAt); err != nil {
+		return err
+	}
+	if campaign.Kind == entities.CouponKindShared && *count != 0 {
+		return errors.New("-count is only for single-use campaigns, use -code for shared campaigns")
+	}
+	if campaign.Kind == entities.CouponKindSingle && *code != "" {
+		return errors.New("-code is only for shared campaigns, use -count for single-use campaigns")
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	items, err := repos.ItemRepository.GetItems()
+	if err != nil {
+		return err
+	}
+	if err := coupon.Validate(&campaign, *items); err != nil {
+		return err
+	}
+
+	w, closeCSV, err := createCouponCSV(*out)
+	if err != nil {
+		return err
+	}
+	codes, err := coupon.Create(repos, &campaign, *count, *code)
+	if err != nil {
+		closeCSV()
+		return err
+	}
+	log.Printf("coupon campaign %d created with %d codes", campaign.ID, len(codes))
+	if err := coupon.WriteCSV(w, &campaign, newCouponCodes(campaign.ID, codes)); err != nil {
+		closeCSV()
+		return err
+	}
+	return closeCSV()
+}
+
+func runCouponIssue(args []string) error {
+	fs := flag.NewFlagSet("coupon-issue", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	id := fs.Int64("campaign-id", 0, "coupon campaign id")
+	count := fs.Int("count", 0, "number of codes to generate")
+	out := fs.String("out", "-", "CSV file to write the new codes to (- for stdout)")
+	fs.Parse(args)
+	if *id < 1 {
+		return errors.New("-campaign-id is required")
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	campaign, err := repos.CouponRepository.GetCouponCampaignByID(entities.CouponCampaignID(*id))
+	if err != nil {
+		return err
+	}
+	w, closeCSV, err := createCouponCSV(*out)
+	if err != nil {
+		return err
+	}
+	codes, err := coupon.Issue(repos, campaign.ID, *count)
+	if err != nil {
+		closeCSV()
+		return err
+	}
+	log.Printf("issued %d codes for coupon campaign %d", len(codes), campaign.ID)
+	if err := coupon.WriteCSV(w, campaign, newCouponCodes(campaign.ID, codes)); err != nil {
+		closeCSV()
+		return err
+	}
+	return closeCSV()
+}
+
+func runCouponExport(args []string) error {
+	fs := flag.NewFlagSet("coupon-export", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	id := fs.Int64("campaign-id", 0, "coupon campaign id")
+	out := fs.String("out", "-", "CSV file to write the codes to (- for stdout)")
+	fs.Parse(args)
+	if *id < 1 {
+		return errors.New("-campaign-id is required")
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	campaign, err := repos.CouponRepository.GetCouponCampaignByID(entities.CouponCampaignID(*id))
+	if err != nil {
+		return err
+	}
+	codes, err := repos.CouponRepository.GetCouponCodes(campaign.ID)
+	if err != nil {
+		return err
+	}
+	w, closeCSV, err := createCouponCSV(*out)
+	if err != nil {
+		return err
+	}
+	if err := coupon.WriteCSV(w, campaign, codes); err != nil {
+		closeCSV()
+		return err
+	}
+	return closeCSV()
+}
+
+func runCurrencyReport(args []string) error {
+	fs := flag.NewFlagSet("currency-report", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	fromFlag := fs.String("from", "", "start of the period (YYYY-MM-DD or RFC3339, from the first record if empty)")
+	toFlag := fs.String("to", "", "end of the period and the date of the outstanding balance (YYYY-MM-DD or RFC3339, now if empty)")
+	fs.Parse(args)
+
+	from, err := parseTimeFlag("from", *fromFlag)
+	if err != nil {
+		return err
+	}
+	to, err := parseTimeFlag("to", *toFlag)
+	if err != nil {
+		return err
+	}
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	currencyReport, err := report.Currency(repos, from, to)
+	if err != nil {
+		return err
+	}
+	return printJSON(currencyReport)
+}
+
+func runUserPurge(args []string) error {
+	fs := flag.NewFlagSet("user-purge", flag.ExitOnError)
+	var conn connectionFlags
+	conn.register(fs)
+	limit := fs.Int("limit", account.DefaultPurgeLimit, "maximum number of users to delete")
+	fs.Parse(args)
+
+	repos, closeFunc, err := conn.connect()
+	if err != nil {
+		return err
+	}
+	defer closeFunc()
+
+	purged, err := account.PurgeDue(repos, time.Now(), *limit)
+	log.Printf("deleted %d users: %v", len(purged), purged)
+	return err
+}
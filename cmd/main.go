@@ -1,24 +1,68 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"flag"
 	"log"
+	"os"
+	"path/filepath"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/go-redis/redis/v8"
 	_ "github.com/go-sql-driver/mysql"
 
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/purchase"
+	"42tokyo-road-to-dojo-go/pkg/ratelimit"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
 	"42tokyo-road-to-dojo-go/pkg/server"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
 )
 
 var (
 	// Listenするアドレス+ポート
 	addr string
+	// サーバの設定
+	conf config.Config
+	// 月間コイン消費上限のタイムゾーン名
+	spendingCapTimezone string
+	// アプリ内課金のレシートを検証するVerifierの名前
+	purchaseVerifier string
+	// リクエストの回数を数えるLimiterの名前
+	rateLimiter string
+	// ルートごとのリクエストの回数の制限
+	rateLimits string
+	// 認証が必要なルート全体への、IPアドレスごとのリクエストの回数の制限
+	authRateLimit string
+	// エクスポートのダウンロード用URLの署名に使う鍵
+	dataExportSigningKey string
 )
 
+// 認証が不要で作成・総当たりに使われやすいルートと、コインを消費するルート、負荷の高いルートの既定の制限
+const defaultRateLimits = "/user/create=10/1h,/auth/refresh=30/1m,/transfer/redeem=10/1m,/gacha/draw=60/1m,/coupon/redeem=30/1m," +
+	"/user/export=5/24h,/user/export/download=30/1m"
+
 func init() {
 	flag.StringVar(&addr, "addr", ":8080", "tcp host:port to connect")
+	flag.DurationVar(&conf.FairSeedPeriod, "fair-seed-period", 24*time.Hour, "period of a provably fair gacha seed")
+	flag.DurationVar(&conf.TradeExpiry, "trade-expiry", 72*time.Hour, "period before a proposed trade expires")
+	flag.Int64Var(&conf.MarketFeePercent, "market-fee-percent", 5, "fee percentage taken from marketplace sales")
+	flag.DurationVar(&conf.MarketListingTTL, "market-listing-ttl", 72*time.Hour, "period before a marketplace listing expires")
+	flag.StringVar(&conf.AdminToken, "admin-token", "", "token for the admin API (the admin API is disabled if empty)")
+	flag.StringVar((*string)(&conf.CoinSpendOrder), "coin-spend-order", string(entities.SpendOrderFreeFirst), "which coins to spend first: free-first or paid-first")
+	flag.StringVar(&spendingCapTimezone, "spending-cap-timezone", "Asia/Tokyo", "timezone of the calendar month for monthly spending caps")
+	flag.DurationVar(&conf.TokenTTL, "token-ttl", 7*24*time.Hour, "period before an auth token expires")
+	flag.DurationVar(&conf.TokenRefreshTTL, "token-refresh-ttl", 90*24*time.Hour, "period after issue during which an auth token can be refreshed")
+	flag.StringVar(&purchaseVerifier, "purchase-verifier", "", "receipt verifier for in-app purchases: mock (in-app purchases are disabled if empty)")
+	flag.DurationVar(&conf.AccountDeletionGrace, "account-deletion-grace", 14*24*time.Hour, "period after a deletion request during which the user can undo it")
+	flag.DurationVar(&conf.DataExportTTL, "data-export-ttl", 24*time.Hour, "period during which a personal data export can be downloaded")
+	flag.StringVar(&conf.DataExportDir, "data-export-dir", filepath.Join(os.TempDir(), "data-exports"), "directory where generated data export files are stored until they are downloaded (must be shared when running multiple servers)")
+	flag.StringVar(&dataExportSigningKey, "data-export-signing-key", "", "key for signing data export download URLs (a random key is generated if empty)")
+	flag.StringVar(&rateLimiter, "rate-limiter", "memory", "backend counting requests for rate limits: memory or redis (rate limits are disabled if empty)")
+	flag.StringVar(&rateLimits, "rate-limits", defaultRateLimits, "comma-separated rate limits per route as route=count/period")
+	flag.StringVar(&authRateLimit, "auth-rate-limit", "600/1m", "rate limit per IP address across all authenticated routes as count/period, counted before the token is checked (disabled if empty)")
 	flag.Parse()
 	log.Default().SetFlags(log.LstdFlags | log.Llongfile)
 }
@@ -53,16 +97,89 @@ func cacheMasterData(repos *repositories.Repositories) {
 	if err := repos.GameSettingsRepository.CacheActiveGameSettings(); err != nil {
 		log.Fatalf("Failed to cache game settings: %v", err)
 	}
+	if err := repos.ItemEnhanceSettingsRepository.CacheItemEnhanceSettings(); err != nil {
+		log.Fatalf("Failed to cache item enhance settings: %v", err)
+	}
+	if err := repos.CollectionMilestoneRepository.CacheCollectionMilestones(); err != nil {
+		log.Fatalf("Failed to cache collection milestones: %v", err)
+	}
+	if err := repos.ItemSeriesRepository.CacheItemSeriesList(); err != nil {
+		log.Fatalf("Failed to cache item series: %v", err)
+	}
+	if err := repos.ShopOfferRepository.CacheShopOffers(); err != nil {
+		log.Fatalf("Failed to cache shop offers: %v", err)
+	}
 }
 
 func main() {
+	if conf.FairSeedPeriod <= 0 {
+		log.Fatalf("fair-seed-period must be positive: %v", conf.FairSeedPeriod)
+	}
+	if conf.TradeExpiry <= 0 {
+		log.Fatalf("trade-expiry must be positive: %v", conf.TradeExpiry)
+	}
+	if conf.MarketFeePercent < 0 || conf.MarketFeePercent > 100 {
+		log.Fatalf("market-fee-percent must be between 0 and 100: %v", conf.MarketFeePercent)
+	}
+	if conf.MarketListingTTL <= 0 {
+		log.Fatalf("market-listing-ttl must be positive: %v", conf.MarketListingTTL)
+	}
+	if conf.TokenTTL <= 0 {
+		log.Fatalf("token-ttl must be positive: %v", conf.TokenTTL)
+	}
+	if conf.TokenRefreshTTL < conf.TokenTTL {
+		log.Fatalf("token-refresh-ttl must not be shorter than token-ttl: %v", conf.TokenRefreshTTL)
+	}
+	if conf.AccountDeletionGrace < 0 {
+		log.Fatalf("account-deletion-grace must not be negative: %v", conf.AccountDeletionGrace)
+	}
+	if conf.DataExportTTL <= 0 {
+		log.Fatalf("data-export-ttl must be positive: %v", conf.DataExportTTL)
+	}
+	if dataExportSigningKey != "" {
+		conf.DataExportSigningKey = []byte(dataExportSigningKey)
+	} else {
+		// 再起動すると発行済みのURLは使えなくなる。複数台で動かす場合は同じ鍵を指定する
+		log.Println("data-export-signing-key is not set; using a random key for this process")
+		conf.DataExportSigningKey = make([]byte, 32)
+		if _, err := rand.Read(conf.DataExportSigningKey); err != nil {
+			log.Fatalf("Failed to generate data-export-signing-key: %v", err)
+		}
+	}
+	if err := os.MkdirAll(conf.DataExportDir, 0o700); err != nil {
+		log.Fatalf("Failed to create data-export-dir: %v", err)
+	}
+	if !conf.CoinSpendOrder.IsValid() {
+		log.Fatalf("coin-spend-order must be free-first or paid-first: %v", conf.CoinSpendOrder)
+	}
+	loc, err := time.LoadLocation(spendingCapTimezone)
+	if err != nil {
+		log.Fatalf("Failed to load spending-cap-timezone: %v", err)
+	}
+	conf.SpendingCapLocation = loc
+	verifier, err := purchase.NewVerifier(purchaseVerifier)
+	if err != nil {
+		log.Fatalf("Failed to create purchase-verifier: %v", err)
+	}
+	conf.PurchaseVerifier = verifier
+	if conf.RateLimits, err = ratelimit.ParseLimits(rateLimits); err != nil {
+		log.Fatalf("Failed to parse rate-limits: %v", err)
+	}
+	if conf.AuthRateLimit, err = ratelimit.ParseLimit(authRateLimit); err != nil {
+		log.Fatalf("Failed to parse auth-rate-limit: %v", err)
+	}
+
 	db := connectDB()
 	defer db.Close()
 
 	rdb := newRedisClient()
 	defer rdb.Close()
 
+	if conf.RateLimiter, err = ratelimit.NewLimiter(rateLimiter, rdb); err != nil {
+		log.Fatalf("Failed to create rate-limiter: %v", err)
+	}
+
 	repos := repositories.NewRepositories(db, rdb)
 	cacheMasterData(repos)
-	server.Serve(addr, repos)
+	server.Serve(addr, repos, &conf)
 }
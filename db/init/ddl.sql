@@ -7,9 +7,20 @@ CREATE TABLE IF NOT EXISTS `user` (
   `id` INT NOT NULL AUTO_INCREMENT COMMENT 'ユーザID',
   `name` VARCHAR(128) NOT NULL COMMENT '名前',
   `high_score` INT NOT NULL DEFAULT 0 COMMENT 'ハイスコア',
-  `coin` INT NOT NULL DEFAULT 0 COMMENT '所持コイン数',
-  `auth_token` VARCHAR(128) NOT NULL COMMENT 'UUIDを用いた認証用トークン',
-  PRIMARY KEY (`id`))
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '所持無償コイン数',
+  `paid_coin` INT NOT NULL DEFAULT 0 COMMENT '所持有償コイン数',
+  `age_bracket` VARCHAR(16) NULL COMMENT '未成年の場合の年齢区分(spending_caps.age_bracket)',
+  `status` VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT 'アカウントの状態(active, suspended, banned)',
+  `status_reason` VARCHAR(255) NULL COMMENT '一時停止・凍結の理由',
+  `suspended_until` DATETIME NULL COMMENT '一時停止が解除される日時',
+  `status_updated_at` DATETIME NULL COMMENT 'アカウントの状態の更新日時',
+  `deletion_scheduled_at` DATETIME NULL COMMENT '削除を予約した場合に削除する日時',
+  `deleted_at` DATETIME NULL COMMENT '削除した日時。削除したユーザの行は匿名化して残す',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  KEY (`created_at`),
+  KEY (`status`),
+  KEY (`deletion_scheduled_at`))
 ENGINE = InnoDB
 COMMENT = 'ユーザ';
 
@@ -26,16 +37,42 @@ CREATE TABLE IF NOT EXISTS `game_settings` (
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ゲーム設定情報';
 
+CREATE TABLE IF NOT EXISTS `item_series` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'シリーズID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アイテムのシリーズ';
+
+CREATE TABLE IF NOT EXISTS `item_categories` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'カテゴリID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アイテムのカテゴリ';
+
 CREATE TABLE IF NOT EXISTS `item` (
   `id` INT NOT NULL AUTO_INCREMENT COMMENT 'アイテムID',
   `name` VARCHAR(128) NOT NULL COMMENT '名称',
   `rarity` INT NOT NULL COMMENT 'レアリティ(1=N, 2=R, 3=SR)',
-  PRIMARY KEY (`id`)
+  `series_id` INT NULL COMMENT 'item_series.id',
+  `category_id` INT NULL COMMENT 'item_categories.id',
+  `description` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '説明',
+  `icon_asset_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'アイコン画像のアセットキー',
+  `illustration_asset_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'イラストのアセットキー',
+  `flavor_text` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'フレーバーテキスト',
+  `released_at` DATETIME NULL COMMENT '公開日時(この日時以降に一覧表示・排出する)',
+  `retired_at` DATETIME NULL COMMENT '排出終了日時(この日時以降はガチャから排出しない)',
+  `hidden_until_released` BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'released_atが設定されるまで非公開にする',
+  `status` ENUM('active', 'retired', 'hidden') NOT NULL DEFAULT 'active' COMMENT '状態(active=排出中, retired=排出終了, hidden=非公開)',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`series_id`) REFERENCES `item_series`(`id`),
+  FOREIGN KEY (`category_id`) REFERENCES `item_categories`(`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アイテムマスター';
 
 CREATE TABLE IF NOT EXISTS `user_items` (
   `user_id` INT NOT NULL COMMENT 'user.id',
   `item_id` INT NOT NULL COMMENT 'item.id',
+  `count` INT NOT NULL DEFAULT 1 COMMENT '所持数',
+  `level` INT NOT NULL DEFAULT 1 COMMENT '強化レベル',
   PRIMARY KEY (`user_id`, `item_id`),
   FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
   FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
@@ -48,4 +85,359 @@ CREATE TABLE IF NOT EXISTS `user_scores` (
   `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
   PRIMARY KEY (`id`),
   FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザのスコア（ここからランキングを算出する）';
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザのスコア（ここからランキングを算出する）';
+
+CREATE TABLE IF NOT EXISTS `gacha_seeds` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'シードID',
+  `seed` CHAR(64) NOT NULL COMMENT '秘密シード(期間終了後に公開)',
+  `seed_hash` CHAR(64) NOT NULL COMMENT '事前に公開するシードのSHA-256',
+  `starts_at` DATETIME NOT NULL COMMENT '期間開始日時(UTC)',
+  `ends_at` DATETIME NOT NULL COMMENT '期間終了日時(UTC)',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`starts_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='公平ガチャのシード';
+
+CREATE TABLE IF NOT EXISTS `gacha_draws` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `seed_id` INT NULL COMMENT 'gacha_seeds.id(公平モードの場合のみ)',
+  `nonce` VARCHAR(64) NULL COMMENT 'クライアントが指定したノンス(公平モードの場合のみ)',
+  `game_setting_id` INT NOT NULL COMMENT '抽選に使用したgame_settings.id',
+  `times` INT NOT NULL COMMENT '実行回数',
+  `item_ids` TEXT NOT NULL COMMENT '排出されたitem.idのJSON配列(排出順)',
+  `pool` MEDIUMTEXT NULL COMMENT '抽選に使った排出対象のitem.idと重みのJSON配列(抽選順、公平モードの場合のみ)',
+  `pool_at` DATETIME NULL COMMENT '排出対象を作った日時(公平モードの場合のみ)',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '実行日時',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`user_id`, `seed_id`, `nonce`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`seed_id`) REFERENCES `gacha_seeds`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ガチャの実行記録';
+
+
+CREATE TABLE IF NOT EXISTS `spending_caps` (
+  `age_bracket` VARCHAR(16) NOT NULL COMMENT '年齢区分',
+  `monthly_cap` INT NOT NULL COMMENT 'ガチャの月間コイン消費上限',
+  PRIMARY KEY (`age_bracket`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='年齢区分ごとの月間コイン消費上限';
+
+CREATE TABLE IF NOT EXISTS `coin_ledger` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `amount` INT NOT NULL COMMENT '増減したコイン数(消費は負)',
+  `currency` ENUM('free', 'paid') NOT NULL DEFAULT 'free' COMMENT '通貨の種類(free: 無償コイン, paid: 有償コイン)',
+  `reason` VARCHAR(32) NOT NULL COMMENT '増減の理由',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `reason`, `created_at`),
+  KEY (`currency`, `created_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='コインの増減履歴';
+
+CREATE TABLE IF NOT EXISTS `item_enhance_settings` (
+  `rarity` INT NOT NULL COMMENT 'レアリティ(1=N, 2=R, 3=SR)',
+  `max_level` INT NOT NULL COMMENT '最大レベル',
+  `copies_per_level` INT NOT NULL COMMENT '1レベルあたりに消費する重複アイテム数',
+  `coin_per_level` INT NOT NULL COMMENT '1レベルあたりに消費するコイン数',
+  PRIMARY KEY (`rarity`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='レアリティごとのアイテム強化設定';
+
+CREATE TABLE IF NOT EXISTS `collection_milestones` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '達成報酬ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  `series_id` INT NULL COMMENT '対象のitem_series.id(NULLの場合は全シリーズ)',
+  `rarity` INT NULL COMMENT '対象のレアリティ(NULLの場合は全レアリティ)',
+  `threshold_percent` INT NOT NULL COMMENT '達成に必要な所持率(%)',
+  `reward_coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `reward_item_id` INT NULL COMMENT '報酬のitem.id',
+  `reward_item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`series_id`) REFERENCES `item_series`(`id`),
+  FOREIGN KEY (`reward_item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='コレクションの達成報酬';
+
+CREATE TABLE IF NOT EXISTS `user_collection_milestones` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `milestone_id` INT NOT NULL COMMENT 'collection_milestones.id',
+  `achieved_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '達成日時',
+  PRIMARY KEY (`user_id`, `milestone_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`milestone_id`) REFERENCES `collection_milestones`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが達成したコレクションの達成報酬';
+
+CREATE TABLE IF NOT EXISTS `trades` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'トレードID',
+  `proposer_id` INT NOT NULL COMMENT '提案したuser.id',
+  `receiver_id` INT NOT NULL COMMENT '提案を受けたuser.id',
+  `offer_coin` INT NOT NULL DEFAULT 0 COMMENT '提案者が渡すコイン数',
+  `request_coin` INT NOT NULL DEFAULT 0 COMMENT '提案者が受け取るコイン数',
+  `status` ENUM('pending', 'accepted', 'declined', 'canceled', 'expired') NOT NULL DEFAULT 'pending' COMMENT '状態',
+  `expires_at` DATETIME NOT NULL COMMENT '応答期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新日時',
+  PRIMARY KEY (`id`),
+  KEY (`proposer_id`, `status`),
+  KEY (`receiver_id`, `status`),
+  FOREIGN KEY (`proposer_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`receiver_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザ間のトレード';
+
+CREATE TABLE IF NOT EXISTS `trade_items` (
+  `trade_id` INT NOT NULL COMMENT 'trades.id',
+  `side` ENUM('proposer', 'receiver') NOT NULL COMMENT 'アイテムを渡す側',
+  `item_id` INT NOT NULL COMMENT 'item.id',
+  `count` INT NOT NULL COMMENT '個数',
+  PRIMARY KEY (`trade_id`, `side`, `item_id`),
+  FOREIGN KEY (`trade_id`) REFERENCES `trades`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='トレードで渡すアイテム';
+
+CREATE TABLE IF NOT EXISTS `market_listings` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '出品ID',
+  `seller_id` INT NOT NULL COMMENT '出品したuser.id',
+  `item_id` INT NOT NULL COMMENT 'item.id',
+  `count` INT NOT NULL COMMENT '個数',
+  `price` INT NOT NULL COMMENT '価格(コイン)',
+  `fee` INT NOT NULL COMMENT '売上から差し引く手数料(コイン)',
+  `status` ENUM('active', 'sold', 'canceled', 'expired') NOT NULL DEFAULT 'active' COMMENT '状態',
+  `buyer_id` INT NULL COMMENT '購入したuser.id',
+  `expires_at` DATETIME NOT NULL COMMENT '出品期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '出品日時',
+  `closed_at` TIMESTAMP NULL COMMENT '出品を終了した日時',
+  PRIMARY KEY (`id`),
+  KEY (`status`, `expires_at`),
+  KEY (`status`, `item_id`, `price`),
+  KEY (`seller_id`, `status`),
+  FOREIGN KEY (`seller_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`buyer_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='マーケットへの出品';
+
+CREATE TABLE IF NOT EXISTS `user_tickets` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `ticket_type` VARCHAR(32) NOT NULL COMMENT 'チケットの種類',
+  `count` INT NOT NULL DEFAULT 0 COMMENT '所持枚数',
+  PRIMARY KEY (`user_id`, `ticket_type`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが所持するチケット';
+
+CREATE TABLE IF NOT EXISTS `presents` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'プレゼントID',
+  `user_id` INT NOT NULL COMMENT '受け取るuser.id',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `message` VARCHAR(1024) NOT NULL COMMENT 'メッセージ',
+  `source` VARCHAR(32) NOT NULL COMMENT '送り元(admin, compensation, eventなど)',
+  `expires_at` DATETIME NULL COMMENT '受け取り期限(NULLの場合は期限なし)',
+  `claimed_at` DATETIME NULL COMMENT '受け取った日時',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `claimed_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='プレゼントボックス';
+
+CREATE TABLE IF NOT EXISTS `compensations` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '補填ID',
+  `operator` VARCHAR(64) NOT NULL COMMENT '実行した運営者',
+  `reason` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '補填の理由',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `message` VARCHAR(1024) NOT NULL COMMENT 'プレゼントのメッセージ',
+  `present_expires_at` DATETIME NULL COMMENT 'プレゼントの受け取り期限',
+  `created_before` DATETIME NULL COMMENT 'この日時より前に作成したユーザを対象にする',
+  `created_after` DATETIME NULL COMMENT 'この日時以降に作成したユーザを対象にする',
+  `has_user_ids` BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'compensation_usersに記録したユーザを対象にするか',
+  `all_users` BOOLEAN NOT NULL DEFAULT FALSE COMMENT '全ユーザを対象にするか',
+  `status` ENUM('pending', 'running', 'completed', 'failed') NOT NULL DEFAULT 'pending' COMMENT '状態',
+  `target_count` INT NOT NULL DEFAULT 0 COMMENT '作成時点の対象ユーザ数',
+  `granted_count` INT NOT NULL DEFAULT 0 COMMENT 'プレゼントを送付したユーザ数',
+  `last_user_id` INT NOT NULL DEFAULT 0 COMMENT '最後に送付したuser.id(再開位置)',
+  `last_error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '最後に失敗した理由',
+  `lease_expires_at` DATETIME NULL COMMENT '実行中の処理が保持するリースの期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  `completed_at` TIMESTAMP NULL COMMENT '完了日時',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='一括補填の実行記録';
+
+CREATE TABLE IF NOT EXISTS `compensation_users` (
+  `compensation_id` INT NOT NULL COMMENT 'compensations.id',
+  `user_id` INT NOT NULL COMMENT '対象のuser.id(存在しないIDは送付時に無視する)',
+  PRIMARY KEY (`compensation_id`, `user_id`),
+  FOREIGN KEY (`compensation_id`) REFERENCES `compensations`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザIDを指定した一括補填の対象ユーザ';
+
+CREATE TABLE IF NOT EXISTS `coupon_campaigns` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'キャンペーンID',
+  `name` VARCHAR(128) NOT NULL COMMENT 'キャンペーン名',
+  `kind` ENUM('single', 'shared') NOT NULL COMMENT 'コードの種類(single: コードごとに1回, shared: 1つのコードを複数のユーザが使う)',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `per_user_limit` INT NOT NULL DEFAULT 1 COMMENT '1ユーザがキャンペーン内で使える回数',
+  `total_limit` INT NOT NULL DEFAULT 0 COMMENT '共有コードの合計の使用回数の上限(0の場合は上限なし)',
+  `starts_at` DATETIME NULL COMMENT '開始日時(NULLの場合は制限なし)',
+  `ends_at` DATETIME NULL COMMENT '終了日時(NULLの場合は制限なし)',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンのキャンペーン';
+
+CREATE TABLE IF NOT EXISTS `coupon_codes` (
+  `code` VARCHAR(32) NOT NULL COMMENT '正規化したシリアルコード',
+  `campaign_id` INT NOT NULL COMMENT 'coupon_campaigns.id',
+  `redeemed_count` INT NOT NULL DEFAULT 0 COMMENT '使用された回数',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '発行日時',
+  PRIMARY KEY (`code`),
+  KEY (`campaign_id`, `created_at`),
+  FOREIGN KEY (`campaign_id`) REFERENCES `coupon_campaigns`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンのシリアルコード';
+
+CREATE TABLE IF NOT EXISTS `coupon_redemptions` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '使用履歴ID',
+  `campaign_id` INT NOT NULL COMMENT 'coupon_campaigns.id',
+  `code` VARCHAR(32) NOT NULL COMMENT 'coupon_codes.code',
+  `user_id` INT NOT NULL COMMENT '使用したuser.id',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '使用日時',
+  PRIMARY KEY (`id`),
+  KEY (`campaign_id`, `user_id`),
+  FOREIGN KEY (`campaign_id`) REFERENCES `coupon_campaigns`(`id`),
+  FOREIGN KEY (`code`) REFERENCES `coupon_codes`(`code`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンの使用履歴';
+
+CREATE TABLE IF NOT EXISTS `shop_offers` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '商品ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '商品名',
+  `description` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '説明',
+  `price` INT NOT NULL COMMENT '価格(コイン)',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `per_user_limit` INT NOT NULL DEFAULT 0 COMMENT '1ユーザが購入できる個数(0の場合は制限なし)',
+  `starts_at` DATETIME NULL COMMENT '販売開始日時(NULLの場合は制限なし)',
+  `ends_at` DATETIME NULL COMMENT '販売終了日時(NULLの場合は制限なし)',
+  `sort_order` INT NOT NULL DEFAULT 0 COMMENT '表示順',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ショップの商品';
+
+CREATE TABLE IF NOT EXISTS `user_shop_purchases` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `offer_id` INT NOT NULL COMMENT 'shop_offers.id',
+  `count` INT NOT NULL DEFAULT 0 COMMENT '購入した個数',
+  PRIMARY KEY (`user_id`, `offer_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`offer_id`) REFERENCES `shop_offers`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが商品を購入した個数';
+
+CREATE TABLE IF NOT EXISTS `purchase_products` (
+  `product_id` VARCHAR(64) NOT NULL COMMENT 'ストアの商品ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '商品名',
+  `paid_coin` INT NOT NULL COMMENT '付与する有償コイン数',
+  `bonus_coin` INT NOT NULL DEFAULT 0 COMMENT 'おまけで付与する無償コイン数',
+  `is_active` BOOLEAN NOT NULL DEFAULT TRUE COMMENT '販売中か',
+  PRIMARY KEY (`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アプリ内課金の商品';
+
+CREATE TABLE IF NOT EXISTS `purchases` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '購入ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `store` ENUM('apple', 'google') NOT NULL COMMENT 'ストア',
+  `transaction_id` VARCHAR(128) NOT NULL COMMENT 'ストアの取引ID',
+  `product_id` VARCHAR(64) NOT NULL COMMENT 'purchase_products.product_id',
+  `paid_coin` INT NOT NULL COMMENT '付与した有償コイン数',
+  `bonus_coin` INT NOT NULL COMMENT '付与した無償コイン数',
+  `status` ENUM('completed', 'refunded') NOT NULL DEFAULT 'completed' COMMENT '状態(completed: 付与済み, refunded: 返金済み)',
+  `needs_support` BOOLEAN NOT NULL DEFAULT FALSE COMMENT '返金で残高が負になり、サポートの対応が必要か',
+  `purchased_at` DATETIME NOT NULL COMMENT 'ストアでの購入日時',
+  `refunded_at` TIMESTAMP NULL COMMENT '返金日時',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '付与日時',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`store`, `transaction_id`),
+  KEY (`user_id`),
+  KEY (`needs_support`, `refunded_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`product_id`) REFERENCES `purchase_products`(`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アプリ内課金の購入履歴。取引IDごとに1件だけ記録し、二重の付与を防ぐ';
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'セッションID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `device_name` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '端末名',
+  `created_at` DATETIME NOT NULL COMMENT 'ログイン日時',
+  `last_seen_at` DATETIME NOT NULL COMMENT '最終利用日時',
+  `expires_at` DATETIME NOT NULL COMMENT 'トークンを交換できる期限。交換するたびに延長する',
+  `revoked_at` DATETIME NULL COMMENT '失効日時(ログアウトした場合)',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `revoked_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='端末ごとのログイン';
+
+CREATE TABLE IF NOT EXISTS `auth_tokens` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'トークンID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `session_id` BIGINT NOT NULL COMMENT 'sessions.id',
+  `token_hash` CHAR(64) NOT NULL COMMENT '認証用トークンのSHA-256ハッシュ(16進数)',
+  `issued_at` DATETIME NOT NULL COMMENT '発行日時',
+  `expires_at` DATETIME NOT NULL COMMENT '有効期限',
+  `refresh_expires_at` DATETIME NOT NULL COMMENT '新しいトークンに交換できる期限',
+  `revoked_at` DATETIME NULL COMMENT '失効日時(交換・ログアウトで失効した場合)',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`token_hash`),
+  KEY (`user_id`, `revoked_at`),
+  KEY (`session_id`, `revoked_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='認証用トークン。トークンそのものは保存せずハッシュで照合する';
+
+CREATE TABLE IF NOT EXISTS `transfer_codes` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `transfer_id` CHAR(10) NOT NULL COMMENT '引き継ぎID',
+  `password_salt` CHAR(32) NOT NULL COMMENT 'パスワードのハッシュのソルト(16進数)',
+  `password_hash` CHAR(64) NOT NULL COMMENT 'ソルトを付けたパスワードのSHA-256ハッシュ(16進数)',
+  `created_at` DATETIME NOT NULL COMMENT '発行日時',
+  PRIMARY KEY (`user_id`),
+  UNIQUE KEY (`transfer_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='機種変更用の引き継ぎコード。使用すると削除する';
+
+CREATE TABLE IF NOT EXISTS `transfer_audit_logs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'ログID',
+  `user_id` INT NULL COMMENT '対象のuser.id(存在しない引き継ぎIDの場合はNULL)',
+  `transfer_id` VARCHAR(64) NOT NULL COMMENT '入力された引き継ぎID',
+  `action` ENUM('issue', 'redeem', 'failure', 'locked') NOT NULL COMMENT '操作(issue: 発行, redeem: 引き継ぎ成功, failure: 失敗, locked: 試行回数の超過)',
+  `ip` VARCHAR(45) NOT NULL COMMENT 'リクエスト元のIPアドレス',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `created_at`),
+  KEY (`transfer_id`, `created_at`),
+  KEY (`ip`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='引き継ぎの監査ログ。ユーザを削除しても残すため外部キーは設定しない';
+
+CREATE TABLE IF NOT EXISTS `data_exports` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'エクスポートID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `format` ENUM('json', 'zip') NOT NULL COMMENT '形式(json: 1つのJSON, zip: JSON Linesのファイルをまとめたzip)',
+  `status` ENUM('pending', 'ready', 'failed', 'downloaded', 'expired') NOT NULL DEFAULT 'pending' COMMENT '状態(pending: 作成中, ready: ダウンロード可能, failed: 作成失敗, downloaded: ダウンロード済み, expired: 期限切れ)',
+  `file_path` VARCHAR(1024) NULL COMMENT '作成したファイルのパス。ダウンロードした時点と期限切れになった時点でファイルを消す',
+  `error` VARCHAR(255) NULL COMMENT '作成に失敗した理由',
+  `created_at` DATETIME NOT NULL COMMENT '依頼日時',
+  `completed_at` DATETIME NULL COMMENT '作成が完了した日時',
+  `expires_at` DATETIME NULL COMMENT 'ダウンロードできる期限',
+  `downloaded_at` DATETIME NULL COMMENT 'ダウンロードした日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `created_at`),
+  KEY (`status`, `expires_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザの個人データのエクスポート。ダウンロードは1回のみ';
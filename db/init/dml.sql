@@ -5,17 +5,43 @@ SET NAMES utf8mb4;
 INSERT INTO `game_settings` (`gacha_coin_consumption`, `ranking_list_limit`, `n_weight`, `r_weight`, `sr_weight`, `max_gacha_times`) VALUES (100, 10, 5, 3, 1, 30);
 INSERT INTO `game_settings` (`gacha_coin_consumption`, `ranking_list_limit`, `n_weight`, `r_weight`, `sr_weight`, `max_gacha_times`, `is_active`) VALUES (10, 10, 5, 3, 1, 50, true);
 
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル1', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル2', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル3', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル4', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル5', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル6', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('ノーマル7', 1);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('レア1', 2);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('レア2', 2);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('レア3', 2);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('レア4', 2);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('レア5', 2);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('スーパーレア1', 3);
-INSERT INTO `item` (`name`, `rarity`) VALUES ('スーパーレア2', 3);
+INSERT INTO `item_series` (`name`) VALUES ('第1弾');
+INSERT INTO `item_series` (`name`) VALUES ('第2弾');
+
+INSERT INTO `item_categories` (`name`) VALUES ('キャラクター');
+INSERT INTO `item_categories` (`name`) VALUES ('装備');
+
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル1', 1, 1, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル2', 1, 1, 2);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル3', 1, 1, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル4', 1, 1, 2);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル5', 1, 2, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル6', 1, 2, 2);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('ノーマル7', 1, 2, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('レア1', 2, 1, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('レア2', 2, 1, 2);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('レア3', 2, 1, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('レア4', 2, 2, 2);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('レア5', 2, 2, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('スーパーレア1', 3, 1, 1);
+INSERT INTO `item` (`name`, `rarity`, `series_id`, `category_id`) VALUES ('スーパーレア2', 3, 2, 2);
+
+INSERT INTO `spending_caps` (`age_bracket`, `monthly_cap`) VALUES ('under16', 500);
+INSERT INTO `spending_caps` (`age_bracket`, `monthly_cap`) VALUES ('16to19', 1000);
+
+INSERT INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (1, 5, 1, 10);
+INSERT INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (2, 5, 1, 30);
+INSERT INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (3, 5, 1, 100);
+
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('コレクション50%達成', NULL, NULL, 50, 100, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('ノーマルコンプリート', NULL, 1, 100, 100, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('レアコンプリート', NULL, 2, 100, 300, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('スーパーレアコンプリート', NULL, 3, 100, 500, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('コレクションコンプリート', NULL, NULL, 100, 1000, 13, 1);
+INSERT INTO `collection_milestones` (`name`, `series_id`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('第1弾コンプリート', 1, NULL, 100, 300, NULL, 0);
+
+INSERT INTO `shop_offers` (`name`, `description`, `price`, `item_id`, `item_count`, `ticket_type`, `ticket_count`, `per_user_limit`, `sort_order`) VALUES ('ガチャチケット', 'ガチャを1回引けるチケット', 100, NULL, 0, 'gacha', 1, 0, 1);
+INSERT INTO `shop_offers` (`name`, `description`, `price`, `item_id`, `item_count`, `ticket_type`, `ticket_count`, `per_user_limit`, `sort_order`) VALUES ('ノーマル1', 'ノーマル1を1個', 50, 1, 1, NULL, 0, 5, 2);
+INSERT INTO `shop_offers` (`name`, `description`, `price`, `item_id`, `item_count`, `ticket_type`, `ticket_count`, `per_user_limit`, `sort_order`) VALUES ('スターターセット', 'ノーマル2とガチャチケット3枚のセット', 250, 2, 1, 'gacha', 3, 1, 0);
+INSERT INTO `purchase_products` (`product_id`, `name`, `paid_coin`, `bonus_coin`) VALUES ('coin_100', 'コイン100枚', 100, 0);
+INSERT INTO `purchase_products` (`product_id`, `name`, `paid_coin`, `bonus_coin`) VALUES ('coin_500', 'コイン500枚', 500, 50);
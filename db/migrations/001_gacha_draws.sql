@@ -0,0 +1,26 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `gacha_seeds` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'シードID',
+  `seed` CHAR(64) NOT NULL COMMENT '秘密シード(期間終了後に公開)',
+  `seed_hash` CHAR(64) NOT NULL COMMENT '事前に公開するシードのSHA-256',
+  `starts_at` DATETIME NOT NULL COMMENT '期間開始日時(UTC)',
+  `ends_at` DATETIME NOT NULL COMMENT '期間終了日時(UTC)',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`starts_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='公平ガチャのシード';
+
+CREATE TABLE IF NOT EXISTS `gacha_draws` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `seed_id` INT NULL COMMENT 'gacha_seeds.id(公平モードの場合のみ)',
+  `nonce` VARCHAR(64) NULL COMMENT 'クライアントが指定したノンス(公平モードの場合のみ)',
+  `game_setting_id` INT NOT NULL COMMENT '抽選に使用したgame_settings.id',
+  `times` INT NOT NULL COMMENT '実行回数',
+  `item_ids` TEXT NOT NULL COMMENT '排出されたitem.idのJSON配列(排出順)',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '実行日時',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`user_id`, `seed_id`, `nonce`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`seed_id`) REFERENCES `gacha_seeds`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ガチャの実行記録';
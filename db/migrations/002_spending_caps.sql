@@ -0,0 +1,23 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `user` ADD COLUMN `age_bracket` VARCHAR(16) NULL COMMENT '未成年の場合の年齢区分(spending_caps.age_bracket)';
+
+CREATE TABLE IF NOT EXISTS `spending_caps` (
+  `age_bracket` VARCHAR(16) NOT NULL COMMENT '年齢区分',
+  `monthly_cap` INT NOT NULL COMMENT 'ガチャの月間コイン消費上限',
+  PRIMARY KEY (`age_bracket`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='年齢区分ごとの月間コイン消費上限';
+
+CREATE TABLE IF NOT EXISTS `coin_ledger` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `amount` INT NOT NULL COMMENT '増減したコイン数(消費は負)',
+  `reason` VARCHAR(32) NOT NULL COMMENT '増減の理由',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `reason`, `created_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='コインの増減履歴';
+
+INSERT IGNORE INTO `spending_caps` (`age_bracket`, `monthly_cap`) VALUES ('under16', 500);
+INSERT IGNORE INTO `spending_caps` (`age_bracket`, `monthly_cap`) VALUES ('16to19', 1000);
@@ -0,0 +1,4 @@
+USE `CA_Tech_Dojo`;
+
+-- 既存の所持アイテムは所持数1として移行する
+ALTER TABLE `user_items` ADD COLUMN `count` INT NOT NULL DEFAULT 1 COMMENT '所持数' AFTER `item_id`;
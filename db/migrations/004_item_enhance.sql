@@ -0,0 +1,15 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `user_items` ADD COLUMN `level` INT NOT NULL DEFAULT 1 COMMENT '強化レベル' AFTER `count`;
+
+CREATE TABLE IF NOT EXISTS `item_enhance_settings` (
+  `rarity` INT NOT NULL COMMENT 'レアリティ(1=N, 2=R, 3=SR)',
+  `max_level` INT NOT NULL COMMENT '最大レベル',
+  `copies_per_level` INT NOT NULL COMMENT '1レベルあたりに消費する重複アイテム数',
+  `coin_per_level` INT NOT NULL COMMENT '1レベルあたりに消費するコイン数',
+  PRIMARY KEY (`rarity`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='レアリティごとのアイテム強化設定';
+
+INSERT IGNORE INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (1, 5, 1, 10);
+INSERT IGNORE INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (2, 5, 1, 30);
+INSERT IGNORE INTO `item_enhance_settings` (`rarity`, `max_level`, `copies_per_level`, `coin_per_level`) VALUES (3, 5, 1, 100);
@@ -0,0 +1,28 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `collection_milestones` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '達成報酬ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  `rarity` INT NULL COMMENT '対象のレアリティ(NULLの場合は全アイテム)',
+  `threshold_percent` INT NOT NULL COMMENT '達成に必要な所持率(%)',
+  `reward_coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `reward_item_id` INT NULL COMMENT '報酬のitem.id',
+  `reward_item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`reward_item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='コレクションの達成報酬';
+
+CREATE TABLE IF NOT EXISTS `user_collection_milestones` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `milestone_id` INT NOT NULL COMMENT 'collection_milestones.id',
+  `achieved_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '達成日時',
+  PRIMARY KEY (`user_id`, `milestone_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`milestone_id`) REFERENCES `collection_milestones`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが達成したコレクションの達成報酬';
+
+INSERT INTO `collection_milestones` (`name`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('コレクション50%達成', NULL, 50, 100, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('ノーマルコンプリート', 1, 100, 100, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('レアコンプリート', 2, 100, 300, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('スーパーレアコンプリート', 3, 100, 500, NULL, 0);
+INSERT INTO `collection_milestones` (`name`, `rarity`, `threshold_percent`, `reward_coin`, `reward_item_id`, `reward_item_count`) VALUES ('コレクションコンプリート', NULL, 100, 1000, 13, 1);
@@ -0,0 +1,23 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `item_series` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'シリーズID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アイテムのシリーズ';
+
+CREATE TABLE IF NOT EXISTS `item_categories` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'カテゴリID',
+  `name` VARCHAR(128) NOT NULL COMMENT '名称',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アイテムのカテゴリ';
+
+ALTER TABLE `item`
+  ADD COLUMN `series_id` INT NULL COMMENT 'item_series.id' AFTER `rarity`,
+  ADD COLUMN `category_id` INT NULL COMMENT 'item_categories.id' AFTER `series_id`,
+  ADD FOREIGN KEY (`series_id`) REFERENCES `item_series`(`id`),
+  ADD FOREIGN KEY (`category_id`) REFERENCES `item_categories`(`id`);
+
+ALTER TABLE `collection_milestones`
+  ADD COLUMN `series_id` INT NULL COMMENT '対象のitem_series.id(NULLの場合は全シリーズ)' AFTER `name`,
+  ADD FOREIGN KEY (`series_id`) REFERENCES `item_series`(`id`);
@@ -0,0 +1,10 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `item`
+  ADD COLUMN `description` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '説明' AFTER `category_id`,
+  ADD COLUMN `icon_asset_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'アイコン画像のアセットキー' AFTER `description`,
+  ADD COLUMN `illustration_asset_key` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'イラストのアセットキー' AFTER `icon_asset_key`,
+  ADD COLUMN `flavor_text` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'フレーバーテキスト' AFTER `illustration_asset_key`,
+  ADD COLUMN `released_at` DATETIME NULL COMMENT '公開日時(この日時以降に一覧表示・排出する)' AFTER `flavor_text`,
+  ADD COLUMN `retired_at` DATETIME NULL COMMENT '排出終了日時(この日時以降はガチャから排出しない)' AFTER `released_at`,
+  ADD COLUMN `hidden_until_released` BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'released_atが設定されるまで非公開にする' AFTER `retired_at`;
@@ -0,0 +1,4 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `item`
+  ADD COLUMN `status` ENUM('active', 'retired', 'hidden') NOT NULL DEFAULT 'active' COMMENT '状態(active=排出中, retired=排出終了, hidden=非公開)' AFTER `hidden_until_released`;
@@ -0,0 +1,28 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `trades` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'トレードID',
+  `proposer_id` INT NOT NULL COMMENT '提案したuser.id',
+  `receiver_id` INT NOT NULL COMMENT '提案を受けたuser.id',
+  `offer_coin` INT NOT NULL DEFAULT 0 COMMENT '提案者が渡すコイン数',
+  `request_coin` INT NOT NULL DEFAULT 0 COMMENT '提案者が受け取るコイン数',
+  `status` ENUM('pending', 'accepted', 'declined', 'canceled', 'expired') NOT NULL DEFAULT 'pending' COMMENT '状態',
+  `expires_at` DATETIME NOT NULL COMMENT '応答期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新日時',
+  PRIMARY KEY (`id`),
+  KEY (`proposer_id`, `status`),
+  KEY (`receiver_id`, `status`),
+  FOREIGN KEY (`proposer_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`receiver_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザ間のトレード';
+
+CREATE TABLE IF NOT EXISTS `trade_items` (
+  `trade_id` INT NOT NULL COMMENT 'trades.id',
+  `side` ENUM('proposer', 'receiver') NOT NULL COMMENT 'アイテムを渡す側',
+  `item_id` INT NOT NULL COMMENT 'item.id',
+  `count` INT NOT NULL COMMENT '個数',
+  PRIMARY KEY (`trade_id`, `side`, `item_id`),
+  FOREIGN KEY (`trade_id`) REFERENCES `trades`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='トレードで渡すアイテム';
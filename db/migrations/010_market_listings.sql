@@ -0,0 +1,22 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `market_listings` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '出品ID',
+  `seller_id` INT NOT NULL COMMENT '出品したuser.id',
+  `item_id` INT NOT NULL COMMENT 'item.id',
+  `count` INT NOT NULL COMMENT '個数',
+  `price` INT NOT NULL COMMENT '価格(コイン)',
+  `fee` INT NOT NULL COMMENT '売上から差し引く手数料(コイン)',
+  `status` ENUM('active', 'sold', 'canceled', 'expired') NOT NULL DEFAULT 'active' COMMENT '状態',
+  `buyer_id` INT NULL COMMENT '購入したuser.id',
+  `expires_at` DATETIME NOT NULL COMMENT '出品期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '出品日時',
+  `closed_at` TIMESTAMP NULL COMMENT '出品を終了した日時',
+  PRIMARY KEY (`id`),
+  KEY (`status`, `expires_at`),
+  KEY (`status`, `item_id`, `price`),
+  KEY (`seller_id`, `status`),
+  FOREIGN KEY (`seller_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`buyer_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='マーケットへの出品';
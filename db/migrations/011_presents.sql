@@ -0,0 +1,28 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `user_tickets` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `ticket_type` VARCHAR(32) NOT NULL COMMENT 'チケットの種類',
+  `count` INT NOT NULL DEFAULT 0 COMMENT '所持枚数',
+  PRIMARY KEY (`user_id`, `ticket_type`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが所持するチケット';
+
+CREATE TABLE IF NOT EXISTS `presents` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'プレゼントID',
+  `user_id` INT NOT NULL COMMENT '受け取るuser.id',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `message` VARCHAR(1024) NOT NULL COMMENT 'メッセージ',
+  `source` VARCHAR(32) NOT NULL COMMENT '送り元(admin, compensation, eventなど)',
+  `expires_at` DATETIME NULL COMMENT '受け取り期限(NULLの場合は期限なし)',
+  `claimed_at` DATETIME NULL COMMENT '受け取った日時',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `claimed_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='プレゼントボックス';
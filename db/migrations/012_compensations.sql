@@ -0,0 +1,40 @@
+USE `CA_Tech_Dojo`;
+
+-- 既存のユーザの作成日時はマイグレーションを実行した日時になる
+ALTER TABLE `user`
+  ADD COLUMN `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時' AFTER `age_bracket`,
+  ADD KEY (`created_at`);
+
+CREATE TABLE IF NOT EXISTS `compensations` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '補填ID',
+  `operator` VARCHAR(64) NOT NULL COMMENT '実行した運営者',
+  `reason` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '補填の理由',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `message` VARCHAR(1024) NOT NULL COMMENT 'プレゼントのメッセージ',
+  `present_expires_at` DATETIME NULL COMMENT 'プレゼントの受け取り期限',
+  `created_before` DATETIME NULL COMMENT 'この日時より前に作成したユーザを対象にする',
+  `created_after` DATETIME NULL COMMENT 'この日時以降に作成したユーザを対象にする',
+  `has_user_ids` BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'compensation_usersに記録したユーザを対象にするか',
+  `all_users` BOOLEAN NOT NULL DEFAULT FALSE COMMENT '全ユーザを対象にするか',
+  `status` ENUM('pending', 'running', 'completed', 'failed') NOT NULL DEFAULT 'pending' COMMENT '状態',
+  `target_count` INT NOT NULL DEFAULT 0 COMMENT '作成時点の対象ユーザ数',
+  `granted_count` INT NOT NULL DEFAULT 0 COMMENT 'プレゼントを送付したユーザ数',
+  `last_user_id` INT NOT NULL DEFAULT 0 COMMENT '最後に送付したuser.id(再開位置)',
+  `last_error` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '最後に失敗した理由',
+  `lease_expires_at` DATETIME NULL COMMENT '実行中の処理が保持するリースの期限',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  `completed_at` TIMESTAMP NULL COMMENT '完了日時',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='一括補填の実行記録';
+
+CREATE TABLE IF NOT EXISTS `compensation_users` (
+  `compensation_id` INT NOT NULL COMMENT 'compensations.id',
+  `user_id` INT NOT NULL COMMENT '対象のuser.id(存在しないIDは送付時に無視する)',
+  PRIMARY KEY (`compensation_id`, `user_id`),
+  FOREIGN KEY (`compensation_id`) REFERENCES `compensations`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザIDを指定した一括補填の対象ユーザ';
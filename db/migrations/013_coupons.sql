@@ -0,0 +1,42 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `coupon_campaigns` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT 'キャンペーンID',
+  `name` VARCHAR(128) NOT NULL COMMENT 'キャンペーン名',
+  `kind` ENUM('single', 'shared') NOT NULL COMMENT 'コードの種類(single: コードごとに1回, shared: 1つのコードを複数のユーザが使う)',
+  `coin` INT NOT NULL DEFAULT 0 COMMENT '報酬のコイン数',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `per_user_limit` INT NOT NULL DEFAULT 1 COMMENT '1ユーザがキャンペーン内で使える回数',
+  `total_limit` INT NOT NULL DEFAULT 0 COMMENT '共有コードの合計の使用回数の上限(0の場合は上限なし)',
+  `starts_at` DATETIME NULL COMMENT '開始日時(NULLの場合は制限なし)',
+  `ends_at` DATETIME NULL COMMENT '終了日時(NULLの場合は制限なし)',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '作成日時',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンのキャンペーン';
+
+CREATE TABLE IF NOT EXISTS `coupon_codes` (
+  `code` VARCHAR(32) NOT NULL COMMENT '正規化したシリアルコード',
+  `campaign_id` INT NOT NULL COMMENT 'coupon_campaigns.id',
+  `redeemed_count` INT NOT NULL DEFAULT 0 COMMENT '使用された回数',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '発行日時',
+  PRIMARY KEY (`code`),
+  KEY (`campaign_id`, `created_at`),
+  FOREIGN KEY (`campaign_id`) REFERENCES `coupon_campaigns`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンのシリアルコード';
+
+CREATE TABLE IF NOT EXISTS `coupon_redemptions` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '使用履歴ID',
+  `campaign_id` INT NOT NULL COMMENT 'coupon_campaigns.id',
+  `code` VARCHAR(32) NOT NULL COMMENT 'coupon_codes.code',
+  `user_id` INT NOT NULL COMMENT '使用したuser.id',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '使用日時',
+  PRIMARY KEY (`id`),
+  KEY (`campaign_id`, `user_id`),
+  FOREIGN KEY (`campaign_id`) REFERENCES `coupon_campaigns`(`id`),
+  FOREIGN KEY (`code`) REFERENCES `coupon_codes`(`code`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='クーポンの使用履歴';
@@ -0,0 +1,27 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `shop_offers` (
+  `id` INT NOT NULL AUTO_INCREMENT COMMENT '商品ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '商品名',
+  `description` VARCHAR(1024) NOT NULL DEFAULT '' COMMENT '説明',
+  `price` INT NOT NULL COMMENT '価格(コイン)',
+  `item_id` INT NULL COMMENT '報酬のitem.id',
+  `item_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のアイテム数',
+  `ticket_type` VARCHAR(32) NULL COMMENT '報酬のチケットの種類',
+  `ticket_count` INT NOT NULL DEFAULT 0 COMMENT '報酬のチケット枚数',
+  `per_user_limit` INT NOT NULL DEFAULT 0 COMMENT '1ユーザが購入できる個数(0の場合は制限なし)',
+  `starts_at` DATETIME NULL COMMENT '販売開始日時(NULLの場合は制限なし)',
+  `ends_at` DATETIME NULL COMMENT '販売終了日時(NULLの場合は制限なし)',
+  `sort_order` INT NOT NULL DEFAULT 0 COMMENT '表示順',
+  PRIMARY KEY (`id`),
+  FOREIGN KEY (`item_id`) REFERENCES `item`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ショップの商品';
+
+CREATE TABLE IF NOT EXISTS `user_shop_purchases` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `offer_id` INT NOT NULL COMMENT 'shop_offers.id',
+  `count` INT NOT NULL DEFAULT 0 COMMENT '購入した個数',
+  PRIMARY KEY (`user_id`, `offer_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`offer_id`) REFERENCES `shop_offers`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザが商品を購入した個数';
@@ -0,0 +1,9 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `user`
+  ADD COLUMN `paid_coin` INT NOT NULL DEFAULT 0 COMMENT '所持有償コイン数' AFTER `coin`;
+
+-- 既存の記録はすべて無償コインとして扱う
+ALTER TABLE `coin_ledger`
+  ADD COLUMN `currency` ENUM('free', 'paid') NOT NULL DEFAULT 'free' COMMENT '通貨の種類(free: 無償コイン, paid: 有償コイン)' AFTER `amount`,
+  ADD KEY (`currency`, `created_at`);
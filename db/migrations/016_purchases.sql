@@ -0,0 +1,31 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `purchase_products` (
+  `product_id` VARCHAR(64) NOT NULL COMMENT 'ストアの商品ID',
+  `name` VARCHAR(128) NOT NULL COMMENT '商品名',
+  `paid_coin` INT NOT NULL COMMENT '付与する有償コイン数',
+  `bonus_coin` INT NOT NULL DEFAULT 0 COMMENT 'おまけで付与する無償コイン数',
+  `is_active` BOOLEAN NOT NULL DEFAULT TRUE COMMENT '販売中か',
+  PRIMARY KEY (`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アプリ内課金の商品';
+
+CREATE TABLE IF NOT EXISTS `purchases` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT '購入ID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `store` ENUM('apple', 'google') NOT NULL COMMENT 'ストア',
+  `transaction_id` VARCHAR(128) NOT NULL COMMENT 'ストアの取引ID',
+  `product_id` VARCHAR(64) NOT NULL COMMENT 'purchase_products.product_id',
+  `paid_coin` INT NOT NULL COMMENT '付与した有償コイン数',
+  `bonus_coin` INT NOT NULL COMMENT '付与した無償コイン数',
+  `status` ENUM('completed', 'refunded') NOT NULL DEFAULT 'completed' COMMENT '状態(completed: 付与済み, refunded: 返金済み)',
+  `needs_support` BOOLEAN NOT NULL DEFAULT FALSE COMMENT '返金で残高が負になり、サポートの対応が必要か',
+  `purchased_at` DATETIME NOT NULL COMMENT 'ストアでの購入日時',
+  `refunded_at` TIMESTAMP NULL COMMENT '返金日時',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '付与日時',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`store`, `transaction_id`),
+  KEY (`user_id`),
+  KEY (`needs_support`, `refunded_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`),
+  FOREIGN KEY (`product_id`) REFERENCES `purchase_products`(`product_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='アプリ内課金の購入履歴。取引IDごとに1件だけ記録し、二重の付与を防ぐ';
@@ -0,0 +1,22 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `auth_tokens` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'トークンID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `token_hash` CHAR(64) NOT NULL COMMENT '認証用トークンのSHA-256ハッシュ(16進数)',
+  `issued_at` DATETIME NOT NULL COMMENT '発行日時',
+  `expires_at` DATETIME NOT NULL COMMENT '有効期限',
+  `refresh_expires_at` DATETIME NOT NULL COMMENT '新しいトークンに交換できる期限',
+  `revoked_at` DATETIME NULL COMMENT '失効日時(交換・ログアウトで失効した場合)',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY (`token_hash`),
+  KEY (`user_id`, `revoked_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='認証用トークン。トークンそのものは保存せずハッシュで照合する';
+
+-- 既存の平文のトークンはハッシュにして移行する。移行したトークンにも有効期限を設け、期限内に新しいトークンへ交換してもらう
+INSERT INTO `auth_tokens` (`user_id`, `token_hash`, `issued_at`, `expires_at`, `refresh_expires_at`)
+SELECT `id`, SHA2(`auth_token`, 256), UTC_TIMESTAMP(), UTC_TIMESTAMP() + INTERVAL 7 DAY, UTC_TIMESTAMP() + INTERVAL 90 DAY
+FROM `user`;
+
+ALTER TABLE `user` DROP COLUMN `auth_token`;
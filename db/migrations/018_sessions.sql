@@ -0,0 +1,29 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'セッションID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `device_name` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '端末名',
+  `created_at` DATETIME NOT NULL COMMENT 'ログイン日時',
+  `last_seen_at` DATETIME NOT NULL COMMENT '最終利用日時',
+  `expires_at` DATETIME NOT NULL COMMENT 'トークンを交換できる期限。交換するたびに延長する',
+  `revoked_at` DATETIME NULL COMMENT '失効日時(ログアウトした場合)',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `revoked_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='端末ごとのログイン';
+
+ALTER TABLE `auth_tokens`
+  ADD COLUMN `session_id` BIGINT NULL COMMENT 'sessions.id' AFTER `user_id`;
+
+-- 既存のトークンはそれぞれ1つのセッションとして移行する
+INSERT INTO `sessions` (`id`, `user_id`, `device_name`, `created_at`, `last_seen_at`, `expires_at`, `revoked_at`)
+SELECT `id`, `user_id`, '', `issued_at`, `issued_at`, `refresh_expires_at`, `revoked_at`
+FROM `auth_tokens`;
+
+UPDATE `auth_tokens` SET `session_id` = `id`;
+
+ALTER TABLE `auth_tokens`
+  MODIFY COLUMN `session_id` BIGINT NOT NULL COMMENT 'sessions.id',
+  ADD KEY (`session_id`, `revoked_at`),
+  ADD FOREIGN KEY (`session_id`) REFERENCES `sessions`(`id`);
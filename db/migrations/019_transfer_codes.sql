@@ -0,0 +1,25 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `transfer_codes` (
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `transfer_id` CHAR(10) NOT NULL COMMENT '引き継ぎID',
+  `password_salt` CHAR(32) NOT NULL COMMENT 'パスワードのハッシュのソルト(16進数)',
+  `password_hash` CHAR(64) NOT NULL COMMENT 'ソルトを付けたパスワードのSHA-256ハッシュ(16進数)',
+  `created_at` DATETIME NOT NULL COMMENT '発行日時',
+  PRIMARY KEY (`user_id`),
+  UNIQUE KEY (`transfer_id`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='機種変更用の引き継ぎコード。使用すると削除する';
+
+CREATE TABLE IF NOT EXISTS `transfer_audit_logs` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'ログID',
+  `user_id` INT NULL COMMENT '対象のuser.id(存在しない引き継ぎIDの場合はNULL)',
+  `transfer_id` VARCHAR(64) NOT NULL COMMENT '入力された引き継ぎID',
+  `action` ENUM('issue', 'redeem', 'failure', 'locked') NOT NULL COMMENT '操作(issue: 発行, redeem: 引き継ぎ成功, failure: 失敗, locked: 試行回数の超過)',
+  `ip` VARCHAR(45) NOT NULL COMMENT 'リクエスト元のIPアドレス',
+  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `created_at`),
+  KEY (`transfer_id`, `created_at`),
+  KEY (`ip`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='引き継ぎの監査ログ。ユーザを削除しても残すため外部キーは設定しない';
@@ -0,0 +1,8 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `user`
+  ADD COLUMN `status` VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT 'アカウントの状態(active, suspended, banned)' AFTER `age_bracket`,
+  ADD COLUMN `status_reason` VARCHAR(255) NULL COMMENT '一時停止・凍結の理由' AFTER `status`,
+  ADD COLUMN `suspended_until` DATETIME NULL COMMENT '一時停止が解除される日時' AFTER `status_reason`,
+  ADD COLUMN `status_updated_at` DATETIME NULL COMMENT 'アカウントの状態の更新日時' AFTER `suspended_until`,
+  ADD KEY (`status`);
@@ -0,0 +1,6 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `user`
+  ADD COLUMN `deletion_scheduled_at` DATETIME NULL COMMENT '削除を予約した場合に削除する日時' AFTER `status_updated_at`,
+  ADD COLUMN `deleted_at` DATETIME NULL COMMENT '削除した日時。削除したユーザの行は匿名化して残す' AFTER `deletion_scheduled_at`,
+  ADD KEY (`deletion_scheduled_at`);
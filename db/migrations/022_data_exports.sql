@@ -0,0 +1,18 @@
+USE `CA_Tech_Dojo`;
+
+CREATE TABLE IF NOT EXISTS `data_exports` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT COMMENT 'エクスポートID',
+  `user_id` INT NOT NULL COMMENT 'user.id',
+  `format` ENUM('json', 'zip') NOT NULL COMMENT '形式(json: 1つのJSON, zip: JSON Linesのファイルをまとめたzip)',
+  `status` ENUM('pending', 'ready', 'failed', 'downloaded', 'expired') NOT NULL DEFAULT 'pending' COMMENT '状態(pending: 作成中, ready: ダウンロード可能, failed: 作成失敗, downloaded: ダウンロード済み, expired: 期限切れ)',
+  `content` LONGBLOB NULL COMMENT '作成したファイル。ダウンロードした時点と期限切れになった時点で消す',
+  `error` VARCHAR(255) NULL COMMENT '作成に失敗した理由',
+  `created_at` DATETIME NOT NULL COMMENT '依頼日時',
+  `completed_at` DATETIME NULL COMMENT '作成が完了した日時',
+  `expires_at` DATETIME NULL COMMENT 'ダウンロードできる期限',
+  `downloaded_at` DATETIME NULL COMMENT 'ダウンロードした日時',
+  PRIMARY KEY (`id`),
+  KEY (`user_id`, `created_at`),
+  KEY (`status`, `expires_at`),
+  FOREIGN KEY (`user_id`) REFERENCES `user`(`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='ユーザの個人データのエクスポート。ダウンロードは1回のみ';
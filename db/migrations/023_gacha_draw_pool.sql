@@ -0,0 +1,5 @@
+USE `CA_Tech_Dojo`;
+
+ALTER TABLE `gacha_draws`
+  ADD COLUMN `pool` MEDIUMTEXT NULL COMMENT '抽選に使った排出対象のitem.idと重みのJSON配列(抽選順、公平モードの場合のみ)' AFTER `item_ids`,
+  ADD COLUMN `pool_at` DATETIME NULL COMMENT '排出対象を作った日時(公平モードの場合のみ)' AFTER `pool`;
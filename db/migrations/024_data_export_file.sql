@@ -0,0 +1,9 @@
+USE `CA_Tech_Dojo`;
+
+-- 作成したファイルは行に保存せず、-data-export-dirのファイルのパスを保存する
+-- 行に保存済みのファイルは移せないため、ダウンロードできる状態のエクスポートは期限切れにする
+UPDATE `data_exports` SET `status` = 'expired' WHERE `status` = 'ready';
+
+ALTER TABLE `data_exports`
+  DROP COLUMN `content`,
+  ADD COLUMN `file_path` VARCHAR(1024) NULL COMMENT '作成したファイルのパス。ダウンロードした時点と期限切れになった時点でファイルを消す' AFTER `status`;
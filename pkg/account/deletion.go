@@ -0,0 +1,102 @@
+// Package account ユーザの削除の予約・取り消しと、猶予期間を過ぎたユーザの削除を行う。
+// 削除はcmd/adminのuser-purgeを定期実行して行う。
+package account
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/export"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// DefaultPurgeLimit 1回の実行で削除するユーザの最大人数
+const DefaultPurgeLimit = 100
+
+// RequestDeletion ユーザの削除を予約し、削除する日時を返す。予約済みの場合は予約済みの日時を返す
+// 削除する日時までは、ユーザはこれまで通りAPIを使え、CancelDeletionで予約を取り消せる
+func RequestDeletion(repos *repositories.Repositories, conf *config.Config, userID entities.UserID) (time.Time, error) {
+	return repos.AccountDeletionRepository.ScheduleDeletion(userID, time.Now().Add(conf.AccountDeletionGrace))
+}
+
+// CancelDeletion ユーザの削除の予約を取り消す。予約していない場合はrepositories.ErrDeletionNotScheduledを返す
+func CancelDeletion(repos *repositories.Repositories, userID entities.UserID) error {
+	return repos.AccountDeletionRepository.CancelDeletion(userID)
+}
+
+// Purge 削除する日時を過ぎたユーザを1つのトランザクションで削除する
+// 所持品・スコア・セッションなどを削除し、ユーザの行は会計の記録のために匿名化して残す
+// 予約が取り消された場合や、他のプロセスが削除済みの場合はrepositories.ErrDeletionNotScheduledを返す
+func Purge(repos *repositories.Repositories, userID entities.UserID, at time.Time) error {
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	// 削除と同時に予約を取り消されても、どちらか一方だけが成功するようにユーザの行をロックする
+	if err := repos.AccountDeletionRepository.LockUserDueForDeletionTransaction(tx, userID, at); err != nil {
+		return err
+	}
+	_, revokedHashes, err := auth.RevokeAllTransaction(tx, repos, userID)
+	if err != nil {
+		return err
+	}
+	// 行を消すとエクスポートのファイルが分からなくなるため、消す前にパスを取得する
+	exportFiles, err := repos.DataExportRepository.GetUserDataExportFilePathsTransaction(tx, userID)
+	if err != nil {
+		return err
+	}
+	if err := repos.AccountDeletionRepository.DeleteUserDataTransaction(tx, userID); err != nil {
+		return err
+	}
+	if err := repos.AccountDeletionRepository.AnonymizeUserTransaction(tx, userID, at); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	// キャッシュに残ったトークンと状態で、削除したユーザのリクエストが通らないようにする
+	auth.InvalidateTokens(repos, revokedHashes)
+	if err := repos.UserStatusRepository.DeleteUserStatusCache(userID); err != nil {
+		log.Println(err)
+	}
+	export.RemoveFiles(exportFiles)
+	return nil
+}
+
+// PurgeDue 削除する日時を過ぎたユーザを古い予約からlimit人まで削除し、削除したユーザのIDを返す
+// 途中で失敗した場合は、それまでに削除したユーザのIDとエラーを返す。次の実行で続きから削除する
+func PurgeDue(repos *repositories.Repositories, at time.Time, limit int) ([]entities.UserID, error) {
+	if limit <= 0 {
+		limit = DefaultPurgeLimit
+	}
+	userIDs, err := repos.AccountDeletionRepository.GetUsersDueForDeletion(at, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	purged := []entities.UserID{}
+	for _, userID := range userIDs {
+		if err := Purge(repos, userID, at); err != nil {
+			if errors.Is(err, repositories.ErrDeletionNotScheduled) {
+				continue
+			}
+			return purged, err
+		}
+		purged = append(purged, userID)
+	}
+	return purged, nil
+}
@@ -0,0 +1,69 @@
+// Package authcontext 認証のミドルウェアが認証したリクエスト元の情報をContextに保存し、ハンドラから取り出す。
+// キーの型を公開しないため、このパッケージの関数以外からは保存・取得できない。
+package authcontext
+
+import (
+	"context"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// Role リクエスト元に許可された権限
+type Role string
+
+const (
+	// RoleUser ユーザのトークンで認証されたリクエスト
+	RoleUser Role = "user"
+	// RoleAdmin 管理用トークンで認証されたリクエスト
+	RoleAdmin Role = "admin"
+)
+
+// Principal 認証されたリクエスト元。管理用トークンで認証された場合はユーザIDとセッションIDを持たない
+type Principal struct {
+	UserID         entities.UserID
+	SessionID      entities.SessionID
+	Roles          []Role
+	TokenExpiresAt time.Time
+}
+
+// HasRole 権限が許可されているか判定する
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithPrincipal リクエスト元をContextに保存する
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext Contextからリクエスト元を取得する。認証のミドルウェアを通っていない場合はokがfalseになる
+func FromContext(ctx context.Context) (principal *Principal, ok bool) {
+	principal, ok = ctx.Value(contextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// UserID Contextからユーザのトークンで認証されたユーザのIDを取得する
+func UserID(ctx context.Context) (entities.UserID, bool) {
+	principal, ok := FromContext(ctx)
+	if !ok || !principal.HasRole(RoleUser) {
+		return 0, false
+	}
+	return principal.UserID, true
+}
+
+// SessionID Contextからユーザのトークンで認証されたセッションのIDを取得する
+func SessionID(ctx context.Context) (entities.SessionID, bool) {
+	principal, ok := FromContext(ctx)
+	if !ok || !principal.HasRole(RoleUser) {
+		return 0, false
+	}
+	return principal.SessionID, true
+}
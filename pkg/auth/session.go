@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"42tokyo-road-to-dojo-go/pkg/cache"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// sessions.device_nameのカラムの長さ
+	maxDeviceNameLength = 64
+	// セッションの最終利用日時を更新する間隔
+	sessionTouchInterval = time.Minute
+	// 最終利用日時を更新したセッションを覚えておく件数
+	touchedSessionCacheSize = 10000
+)
+
+// sessionTouchIntervalの間に最終利用日時を更新したセッション。同じセッションの認証のたびにDBへ書き込まないようにする
+var touchedSessions = cache.NewLRU[entities.SessionID, struct{}](touchedSessionCacheSize, sessionTouchInterval)
+
+// NormalizeDeviceName 端末名の前後の空白を取り除き、長さを検証する
+func NormalizeDeviceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxDeviceNameLength {
+		return "", fmt.Errorf("deviceName must be at most %d characters", maxDeviceNameLength)
+	}
+	return name, nil
+}
+
+// CreateSessionTransaction ユーザに新しいセッションを作成し、そのセッションのトークンを発行する
+func CreateSessionTransaction(tx *sql.Tx, repos *repositories.Repositories, conf *config.Config, userID entities.UserID, deviceName string) (*entities.IssuedAuthToken, error) {
+	now := time.Now()
+	session := entities.Session{
+		UserID:     userID,
+		DeviceName: deviceName,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(conf.TokenRefreshTTL),
+	}
+	if err := repos.SessionRepository.AddSessionTransaction(tx, &session); err != nil {
+		return nil, err
+	}
+	return issueTokenTransaction(tx, repos, conf, userID, session.ID, now)
+}
+
+// CreateSession ユーザに新しいセッションを作成し、そのセッションのトークンを発行する
+func CreateSession(repos *repositories.Repositories, conf *config.Config, userID entities.UserID, deviceName string) (*entities.IssuedAuthToken, error) {
+	var issued *entities.IssuedAuthToken
+	err := inTransaction(repos, func(tx *sql.Tx) error {
+		var err error
+		issued, err = CreateSessionTransaction(tx, repos, conf, userID, deviceName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// TouchSession セッションの最終利用日時を更新する。認証のたびに呼び出し、失敗してもリクエストは続ける
+func TouchSession(repos *repositories.Repositories, sessionID entities.SessionID, at time.Time) {
+	if _, ok := touchedSessions.Get(sessionID); ok {
+		return
+	}
+	touchedSessions.Set(sessionID, struct{}{})
+	if err := repos.SessionRepository.TouchSession(sessionID, at, sessionTouchInterval); err != nil {
+		log.Println(err)
+	}
+}
+
+// RevokeSession ユーザのセッションとそのトークンを失効させる。失効済みのセッションの場合は何もしない
+// 他のユーザのセッションの場合はrepositories.ErrSessionNotFoundを返す
+func RevokeSession(repos *repositories.Repositories, userID entities.UserID, sessionID entities.SessionID) error {
+	var hashes []string
+	err := inTransaction(repos, func(tx *sql.Tx) error {
+		session, err := repos.SessionRepository.GetSessionForUpdateTransaction(tx, userID, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now()
+		if err := repos.SessionRepository.RevokeSessionTransaction(tx, session.ID, now); err != nil {
+			return err
+		}
+		hashes, err = repos.AuthTokenRepository.RevokeSessionAuthTokensTransaction(tx, session.ID, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	InvalidateTokens(repos, hashes)
+	return nil
+}
+
+// RevokeAllTransaction ユーザのすべてのセッションとトークンを失効させ、失効させたセッションの数と失効させたトークンのハッシュを返す
+// コミット後にハッシュをInvalidateTokensへ渡し、キャッシュから削除すること
+func RevokeAllTransaction(tx *sql.Tx, repos *repositories.Repositories, userID entities.UserID) (int64, []string, error) {
+	now := time.Now()
+	revoked, err := repos.SessionRepository.RevokeUserSessionsTransaction(tx, userID, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	hashes, err := repos.AuthTokenRepository.RevokeUserAuthTokensTransaction(tx, userID, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	return revoked, hashes, nil
+}
+
+// RevokeAll ユーザのすべてのセッションとトークンを失効させ、失効させたセッションの数を返す
+func RevokeAll(repos *repositories.Repositories, userID entities.UserID) (int64, error) {
+	var revoked int64
+	var hashes []string
+	err := inTransaction(repos, func(tx *sql.Tx) error {
+		var err error
+		revoked, hashes, err = RevokeAllTransaction(tx, repos, userID)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	InvalidateTokens(repos, hashes)
+	return revoked, nil
+}
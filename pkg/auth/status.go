@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrAccountSuspended = errors.New("account is suspended")
+	ErrAccountBanned    = errors.New("account is banned")
+	ErrAccountDeleted   = errors.New("account has been deleted")
+)
+
+// CheckAccountStatus ユーザのアカウントの状態を確認する
+// 一時停止中の場合はErrAccountSuspended、凍結されている場合はErrAccountBanned、削除済みの場合はErrAccountDeletedを状態と合わせて返す
+func CheckAccountStatus(repos *repositories.Repositories, userID entities.UserID, at time.Time) (*entities.UserAccountStatus, error) {
+	status, err := repos.UserStatusRepository.GetUserStatus(userID)
+	if err != nil {
+		return nil, err
+	}
+	switch status.EffectiveStatus(at) {
+	case entities.UserStatusSuspended:
+		return status, ErrAccountSuspended
+	case entities.UserStatusBanned:
+		return status, ErrAccountBanned
+	case entities.UserStatusDeleted:
+		return status, ErrAccountDeleted
+	default:
+		return status, nil
+	}
+}
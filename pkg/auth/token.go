@@ -0,0 +1,158 @@
+// Package auth ユーザの認証用トークンの発行・検証・失効を行う。
+// トークンはクライアントにのみ渡し、サーバにはSHA-256のハッシュだけを保存する。
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token has expired")
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// ErrorCode 認証に失敗した理由をクライアントが判別するためのエラーコードを返す。認証の失敗でない場合は空文字を返す
+// クライアントはtoken_expiredの場合に/auth/refreshでトークンを交換し、トークンの他の失敗の場合はトークンを破棄する
+// account_suspended、account_bannedの場合はトークンを破棄せず、アカウントの状態を表示する
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenInvalid):
+		return "invalid_token"
+	case errors.Is(err, ErrTokenExpired):
+		return "token_expired"
+	case errors.Is(err, ErrTokenRevoked):
+		return "token_revoked"
+	case errors.Is(err, ErrAccountSuspended):
+		return "account_suspended"
+	case errors.Is(err, ErrAccountBanned):
+		return "account_banned"
+	case errors.Is(err, ErrAccountDeleted):
+		return "account_deleted"
+	default:
+		return ""
+	}
+}
+
+// HashToken 保存・照合に使うトークンのハッシュを求める
+// トークンは推測できない乱数のため、ソルトやストレッチングは行わない
+func HashToken(token entities.AuthToken) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// セッションに新しいトークンを発行する
+func issueTokenTransaction(tx *sql.Tx, repos *repositories.Repositories, conf *config.Config, userID entities.UserID, sessionID entities.SessionID, now time.Time) (*entities.IssuedAuthToken, error) {
+	token := entities.AuthToken(uuid.New().String())
+	record := entities.AuthTokenRecord{
+		UserID:           userID,
+		SessionID:        sessionID,
+		TokenHash:        HashToken(token),
+		IssuedAt:         now,
+		ExpiresAt:        now.Add(conf.TokenTTL),
+		RefreshExpiresAt: now.Add(conf.TokenRefreshTTL),
+	}
+	if err := repos.AuthTokenRepository.AddAuthTokenTransaction(tx, &record); err != nil {
+		return nil, err
+	}
+	return &entities.IssuedAuthToken{SessionID: sessionID, Token: token, ExpiresAt: record.ExpiresAt, RefreshExpiresAt: record.RefreshExpiresAt}, nil
+}
+
+// トランザクション内でfnを実行し、エラーがなければコミットする
+func inTransaction(repos *repositories.Repositories, fn func(tx *sql.Tx) error) error {
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// InvalidateTokens 失効させたトークンの記録をキャッシュから削除する。失効させたトランザクションのコミット後に呼び出す
+// 削除に失敗してもキャッシュの期限が切れれば失効が反映されるため、エラーは記録するだけにする
+func InvalidateTokens(repos *repositories.Repositories, hashes []string) {
+	if err := repos.AuthTokenRepository.DeleteAuthTokenCache(hashes...); err != nil {
+		log.Println(err)
+	}
+}
+
+// Verify トークンを照合し、有効な場合はその記録を返す
+// 失効したトークンはErrTokenRevoked、期限切れのトークンはErrTokenExpired、記録のないトークンはErrTokenInvalidを返す
+func Verify(repos *repositories.Repositories, token entities.AuthToken, at time.Time) (*entities.AuthTokenRecord, error) {
+	record, err := repos.AuthTokenRepository.GetAuthTokenByHash(HashToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrAuthTokenNotFound) {
+			return nil, ErrTokenInvalid
+		}
+		return nil, err
+	}
+	if record.IsRevoked() {
+		return nil, ErrTokenRevoked
+	}
+	if record.IsExpired(at) {
+		return nil, ErrTokenExpired
+	}
+	return record, nil
+}
+
+// Refresh トークンを同じセッションの新しいトークンに交換し、古いトークンを失効させる
+// 有効期限が切れていても、交換できる期限内であれば交換する
+func Refresh(repos *repositories.Repositories, conf *config.Config, token entities.AuthToken) (*entities.IssuedAuthToken, error) {
+	var issued *entities.IssuedAuthToken
+	hash := HashToken(token)
+	err := inTransaction(repos, func(tx *sql.Tx) error {
+		// 同じトークンで同時に交換されても1回だけ交換するよう、行をロックして確認する
+		record, err := repos.AuthTokenRepository.GetAuthTokenByHashForUpdateTransaction(tx, hash)
+		if err != nil {
+			if errors.Is(err, repositories.ErrAuthTokenNotFound) {
+				return ErrTokenInvalid
+			}
+			return err
+		}
+		now := time.Now()
+		if record.IsRevoked() {
+			return ErrTokenRevoked
+		}
+		if !record.IsRefreshable(now) {
+			return ErrTokenExpired
+		}
+
+		if err := repos.AuthTokenRepository.RevokeAuthTokenTransaction(tx, record.ID, now); err != nil {
+			return err
+		}
+		issued, err = issueTokenTransaction(tx, repos, conf, record.UserID, record.SessionID, now)
+		if err != nil {
+			return err
+		}
+		return repos.SessionRepository.ExtendSessionTransaction(tx, record.SessionID, issued.RefreshExpiresAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	InvalidateTokens(repos, []string{hash})
+	return issued, nil
+}
@@ -0,0 +1,87 @@
+// Package cache プロセス内で値を保持するキャッシュ。
+// 複数のサーバで共有しないため、他のサーバでの更新はTTLが切れるまで反映されない。
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU 件数の上限を超えると最も古く使われた値から捨てる、TTL付きのキャッシュ。複数のgoroutineから使える
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element, capacity),
+	}
+}
+
+// Get キーの値を返す。値がない場合やTTLが切れた場合はokがfalseになる
+func (c *LRU[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if !time.Now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return value, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Set キーの値を保持する。件数の上限を超えた場合は最も古く使われた値を捨てる
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+	}
+}
+
+// Delete キーの値を捨てる
+func (c *LRU[K, V]) Delete(keys ...K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+func (c *LRU[K, V]) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry[K, V]).key)
+}
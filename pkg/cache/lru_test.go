@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		// 順に実行する操作。getは値を読んで最近使ったことにする
+		ops      func(c *LRU[string, int])
+		wantKeys []string
+		lostKeys []string
+	}{
+		{
+			name:     "上限以内",
+			capacity: 2,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+			},
+			wantKeys: []string{"a", "b"},
+		},
+		{
+			name:     "最も古く追加した値を捨てる",
+			capacity: 2,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+			},
+			wantKeys: []string{"b", "c"},
+			lostKeys: []string{"a"},
+		},
+		{
+			name:     "読んだ値は最近使ったものとして残す",
+			capacity: 2,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Get("a")
+				c.Set("c", 3)
+			},
+			wantKeys: []string{"a", "c"},
+			lostKeys: []string{"b"},
+		},
+		{
+			name:     "上書きした値は最近使ったものとして残す",
+			capacity: 2,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("a", 10)
+				c.Set("c", 3)
+			},
+			wantKeys: []string{"a", "c"},
+			lostKeys: []string{"b"},
+		},
+		{
+			name:     "削除した値",
+			capacity: 3,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+				c.Set("b", 2)
+				c.Set("c", 3)
+				c.Delete("a", "c", "missing")
+			},
+			wantKeys: []string{"b"},
+			lostKeys: []string{"a", "c"},
+		},
+		{
+			name:     "上限が0",
+			capacity: 0,
+			ops: func(c *LRU[string, int]) {
+				c.Set("a", 1)
+			},
+			lostKeys: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[string, int](tt.capacity, time.Hour)
+			tt.ops(c)
+			for _, key := range tt.wantKeys {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("Get(%q) ok = false, want true", key)
+				}
+			}
+			for _, key := range tt.lostKeys {
+				if v, ok := c.Get(key); ok {
+					t.Errorf("Get(%q) = %d, want no value", key, v)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUValue(t *testing.T) {
+	c := NewLRU[int, string](2, time.Hour)
+	c.Set(1, "one")
+	c.Set(1, "uno")
+	if v, ok := c.Get(1); !ok || v != "uno" {
+		t.Errorf("Get(1) = %q, %v, want %q, true", v, ok, "uno")
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		wait   time.Duration
+		wantOk bool
+	}{
+		{name: "TTL内", ttl: time.Hour, wait: 0, wantOk: true},
+		{name: "TTLが0", ttl: 0, wait: 0, wantOk: false},
+		{name: "TTLが切れた後", ttl: 10 * time.Millisecond, wait: 30 * time.Millisecond, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[string, int](1, tt.ttl)
+			c.Set("a", 1)
+			time.Sleep(tt.wait)
+			if _, ok := c.Get("a"); ok != tt.wantOk {
+				t.Errorf("Get() ok = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
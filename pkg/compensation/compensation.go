@@ -0,0 +1,206 @@
+// Package compensation 運営からの一括補填を対象者のプレゼントボックスにバッチで送付する。
+// 管理APIとcmd/adminの両方から使う。
+package compensation
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// DefaultBatchSize 1回のトランザクションで送付する対象者の人数
+	DefaultBatchSize = 500
+	// 実行中のプロセスが止まった場合に、他のプロセスが再開できるようになるまでの時間
+	leaseDuration = time.Minute
+	// compensations.operatorとreason, messageのカラムの長さ
+	maxOperatorLength = 64
+	maxTextLength     = 1024
+)
+
+var ErrNotRunnable = errors.New("compensation is already running or completed")
+
+// Runner 補填の作成と送付を行う
+type Runner struct {
+	repos     *repositories.Repositories
+	batchSize int
+}
+
+func NewRunner(repos *repositories.Repositories, batchSize int) *Runner {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Runner{repos: repos, batchSize: batchSize}
+}
+
+// Validate 補填の内容を検証する。itemsは報酬のアイテムの確認に使う
+func Validate(compensation *entities.Compensation, items entities.Items) error {
+	if strings.TrimSpace(compensation.Operator) == "" {
+		return errors.New("operator is required")
+	}
+	if utf8.RuneCountInString(compensation.Operator) > maxOperatorLength {
+		return fmt.Errorf("operator must be at most %d characters", maxOperatorLength)
+	}
+	if utf8.RuneCountInString(compensation.Reason) > maxTextLength {
+		return fmt.Errorf("reason must be at most %d characters", maxTextLength)
+	}
+	if strings.TrimSpace(compensation.Message) == "" {
+		return errors.New("message is required")
+	}
+	if utf8.RuneCountInString(compensation.Message) > maxTextLength {
+		return fmt.Errorf("message must be at most %d characters", maxTextLength)
+	}
+	if compensation.Target.IsEmpty() && !compensation.Target.AllUsers {
+		return errors.New("target is required (set allUsers to send to every user)")
+	}
+	if !compensation.Target.IsEmpty() && compensation.Target.AllUsers {
+		return errors.New("allUsers cannot be combined with other conditions")
+	}
+	if compensation.PresentExpiresAt != nil && !compensation.PresentExpiresAt.After(time.Now()) {
+		return errors.New("presentExpiresAt must be in the future")
+	}
+	return compensation.Reward.Validate(items.ByID())
+}
+
+// Count 補填を作成せずに対象者の人数を数える(dry-run)
+func (r *Runner) Count(target *entities.CompensationTarget) (int64, error) {
+	return r.repos.CompensationRepository.CountTargetUsers(target)
+}
+
+// Create 補填を未実行の状態で作成する。送付はStartとProcessで行う
+func (r *Runner) Create(compensation *entities.Compensation) error {
+	compensation.Status = entities.CompensationStatusPending
+	return r.repos.CompensationRepository.AddCompensation(compensation)
+}
+
+// Start 補填を実行中にして返す。完了済みの場合や他のプロセスが実行中の場合はErrNotRunnableを返す
+// 失敗した補填や、実行中のプロセスが止まってリースが切れた補填は、送付済みの続きから再開できる
+func (r *Runner) Start(ID entities.CompensationID) (*entities.Compensation, error) {
+	now := time.Now()
+	ok, err := r.repos.CompensationRepository.AcquireCompensation(ID, now, now.Add(leaseDuration))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if _, err := r.repos.CompensationRepository.GetCompensationByID(ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotRunnable
+	}
+	return r.repos.CompensationRepository.GetCompensationByID(ID)
+}
+
+// Process Startで実行中にした補填を、送付済みの最後のユーザIDの続きからバッチで送付する
+// バッチごとにプレゼントの追加と進捗の記録を同じトランザクションで行う
+// progressを指定した場合は、バッチごとに進捗を渡して呼び出す
+func (r *Runner) Process(ctx context.Context, compensation *entities.Compensation, progress func(*entities.Compensation)) error {
+	err := r.process(ctx, compensation, progress)
+	status := entities.CompensationStatusCompleted
+	lastError := ""
+	if err != nil {
+		status = entities.CompensationStatusFailed
+		lastError = err.Error()
+	}
+	if finishErr := r.repos.CompensationRepository.FinishCompensation(compensation.ID, status, lastError); finishErr != nil {
+		if err == nil {
+			err = finishErr
+		}
+		return err
+	}
+	compensation.Status = status
+	compensation.LastError = lastError
+	return err
+}
+
+func (r *Runner) process(ctx context.Context, compensation *entities.Compensation, progress func(*entities.Compensation)) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		userIDs, err := r.repos.CompensationRepository.GetTargetUserIDs(compensation, compensation.LastUserID, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		presents := make([]entities.Present, 0, len(userIDs))
+		for _, userID := range userIDs {
+			presents = append(presents, entities.Present{
+				UserID:    userID,
+				Reward:    compensation.Reward,
+				Message:   compensation.Message,
+				Source:    entities.PresentSourceCompensation,
+				ExpiresAt: compensation.PresentExpiresAt,
+			})
+		}
+		lastUserID := userIDs[len(userIDs)-1]
+
+		tx, err := r.repos.DB.Begin()
+		if err != nil {
+			return err
+		}
+		err = r.repos.PresentRepository.AddPresentsTransaction(tx, presents)
+		if err == nil {
+			err = r.repos.CompensationRepository.AdvanceCompensationTransaction(tx, compensation.ID, lastUserID, int64(len(userIDs)), time.Now().Add(leaseDuration))
+		}
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return fmt.Errorf("%w (rollback: %v)", err, rollbackErr)
+			}
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		compensation.LastUserID = lastUserID
+		compensation.GrantedCount += int64(len(userIDs))
+		if progress != nil {
+			progress(compensation)
+		}
+	}
+}
+
+// ReadUserIDs CSVの1列目からユーザIDを読み込む。空行と、数値でない1行目(ヘッダ)は読み飛ばす
+func ReadUserIDs(reader io.Reader) ([]entities.UserID, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+
+	var userIDs []entities.UserID
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid user id %q", line, record[0])
+		}
+		if id < 1 {
+			return nil, fmt.Errorf("line %d: user id must be positive", line)
+		}
+		userIDs = append(userIDs, entities.UserID(id))
+	}
+	return userIDs, nil
+}
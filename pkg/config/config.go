@@ -0,0 +1,49 @@
+package config
+
+import (
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/purchase"
+	"42tokyo-road-to-dojo-go/pkg/ratelimit"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// Config コマンドライン引数で指定するサーバの設定
+type Config struct {
+	// FairSeedPeriod 公平ガチャのシードを切り替える間隔。期間終了後にシードを公開する
+	FairSeedPeriod time.Duration
+	// SpendingCapLocation 月間コイン消費上限をリセットする暦月の境界のタイムゾーン
+	SpendingCapLocation *time.Location
+	// TradeExpiry トレードを提案してから応答できる期間
+	TradeExpiry time.Duration
+	// MarketFeePercent マーケットの売上から差し引く手数料率(%)
+	MarketFeePercent int64
+	// MarketListingTTL マーケットに出品してから期限切れになるまでの期間
+	MarketListingTTL time.Duration
+	// AdminToken 管理APIの認証に使うトークン。空の場合は管理APIを無効にする
+	AdminToken string
+	// CoinSpendOrder ガチャやショップでコインを消費する際に、有償コインと無償コインのどちらから消費するか
+	CoinSpendOrder entities.SpendOrder
+	// TokenTTL 認証用トークンの有効期限
+	TokenTTL time.Duration
+	// TokenRefreshTTL 認証用トークンを発行してから新しいトークンに交換できる期間
+	TokenRefreshTTL time.Duration
+	// PurchaseVerifier アプリ内課金のレシートと返金通知の検証に使うVerifier。nilの場合は課金を無効にする
+	PurchaseVerifier purchase.Verifier
+	// AccountDeletionGrace ユーザが削除を予約してから削除するまでの猶予期間。この間は予約を取り消せる
+	AccountDeletionGrace time.Duration
+	// DataExportTTL 個人データのエクスポートを作成してからダウンロードできる期間
+	DataExportTTL time.Duration
+	// DataExportSigningKey エクスポートのダウンロード用URLの署名に使う鍵
+	DataExportSigningKey []byte
+	// DataExportDir 作成したエクスポートのファイルを保存するディレクトリ
+	// 複数のサーバで動かす場合は、全てのサーバから読み書きできる共有のディレクトリを指定する
+	DataExportDir string
+	// RateLimiter リクエストの回数を数えるLimiter。nilの場合は回数を制限しない
+	RateLimiter ratelimit.Limiter
+	// RateLimits ルートごとのリクエストの回数の制限。指定していないルートは制限しない
+	RateLimits map[string]ratelimit.Limit
+	// AuthRateLimit 認証が必要なルート全体への、認証の前に数えるIPアドレスごとの制限。ゼロ値の場合は制限しない
+	// 不正なトークンでトークンの照合を繰り返されるのを防ぐ
+	AuthRateLimit ratelimit.Limit
+}
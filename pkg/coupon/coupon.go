@@ -0,0 +1,183 @@
+// Package coupon シリアルコードのキャンペーンの作成とコードの発行を行う。
+// コードの発行とCSVへの書き出しはcmd/adminから使う。
+package coupon
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"42tokyo-road-to-dojo-go/pkg/randcode"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// CodeLength 発行するコードの長さ
+	CodeLength = randcode.SecureLength
+	// 手入力する共有コードと、入力を受け付けるコードの長さ
+	minCodeLength = 4
+	maxCodeLength = 32
+	// MaxBatchSize 1回で発行できるコードの最大件数
+	MaxBatchSize = 100000
+	// 既存のコードと重複した場合に発行し直す回数
+	maxIssueAttempts = 3
+	// coupon_campaigns.nameのカラムの長さ
+	maxNameLength = 128
+)
+
+// ValidCode 正規化したコードが受け付けられる形式か判定する
+func ValidCode(code string) bool {
+	if len(code) < minCodeLength || len(code) > maxCodeLength {
+		return false
+	}
+	for _, r := range code {
+		if !('0' <= r && r <= '9' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatCode 配布用にコードを4文字ごとに"-"で区切る。入力時は区切りを取り除いて照合する
+func FormatCode(code string) string {
+	var b strings.Builder
+	for i, r := range code {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// GenerateCodes 重複のないランダムなコードをn件生成する
+func GenerateCodes(n int) ([]string, error) {
+	seen := make(map[string]struct{}, n)
+	codes := make([]string, 0, n)
+	for len(codes) < n {
+		code, err := randcode.New(CodeLength)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// Validate キャンペーンの内容を検証する。itemsは報酬のアイテムの確認に使う
+func Validate(campaign *entities.CouponCampaign, items entities.Items) error {
+	if strings.TrimSpace(campaign.Name) == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(campaign.Name) > maxNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxNameLength)
+	}
+	if !campaign.Kind.IsValid() {
+		return errors.New("kind must be single or shared")
+	}
+	if campaign.PerUserLimit < 1 {
+		return errors.New("perUserLimit must be at least 1")
+	}
+	if campaign.TotalLimit < 0 {
+		return errors.New("totalLimit must not be negative")
+	}
+	if campaign.Kind == entities.CouponKindSingle && campaign.TotalLimit != 0 {
+		return errors.New("totalLimit is only for shared codes")
+	}
+	if campaign.StartsAt != nil && campaign.EndsAt != nil && !campaign.StartsAt.Before(*campaign.EndsAt) {
+		return errors.New("startsAt must be before endsAt")
+	}
+	if campaign.EndsAt != nil && !campaign.EndsAt.After(time.Now()) {
+		return errors.New("endsAt must be in the future")
+	}
+	return campaign.Reward.Validate(items.ByID())
+}
+
+// Create キャンペーンを作成してコードを発行する
+// 共有コードの場合はsharedCodeを正規化して登録し、1回のみのコードの場合はcount件のコードを生成する
+func Create(repos *repositories.Repositories, campaign *entities.CouponCampaign, count int, sharedCode string) ([]string, error) {
+	if campaign.Kind == entities.CouponKindShared {
+		code := entities.NormalizeCouponCode(sharedCode)
+		if !ValidCode(code) {
+			return nil, fmt.Errorf("code must be %d to %d letters or digits", minCodeLength, maxCodeLength)
+		}
+		if err := repos.CouponRepository.AddCouponCampaign(campaign, []string{code}); err != nil {
+			return nil, err
+		}
+		return []string{code}, nil
+	}
+
+	if err := validateBatchSize(count); err != nil {
+		return nil, err
+	}
+	return issue(count, func(codes []string) error {
+		return repos.CouponRepository.AddCouponCampaign(campaign, codes)
+	})
+}
+
+// Issue 1回のみのコードのキャンペーンにcount件のコードを追加で発行する
+func Issue(repos *repositories.Repositories, campaignID entities.CouponCampaignID, count int) ([]string, error) {
+	campaign, err := repos.CouponRepository.GetCouponCampaignByID(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Kind != entities.CouponKindSingle {
+		return nil, errors.New("codes can only be added to single-use campaigns")
+	}
+	if err := validateBatchSize(count); err != nil {
+		return nil, err
+	}
+	return issue(count, func(codes []string) error {
+		return repos.CouponRepository.AddCouponCodes(campaignID, codes)
+	})
+}
+
+func validateBatchSize(count int) error {
+	if count < 1 || count > MaxBatchSize {
+		return fmt.Errorf("count must be between 1 and %d", MaxBatchSize)
+	}
+	return nil
+}
+
+// コードを生成して登録する。既存のコードと重複した場合は生成し直す
+func issue(count int, add func(codes []string) error) ([]string, error) {
+	for attempt := 1; ; attempt++ {
+		codes, err := GenerateCodes(count)
+		if err != nil {
+			return nil, err
+		}
+		err = add(codes)
+		if err == nil {
+			return codes, nil
+		}
+		if !errors.Is(err, repositories.ErrCouponCodeDuplicate) || attempt >= maxIssueAttempts {
+			return nil, err
+		}
+	}
+}
+
+// WriteCSV コードを配布用の形式でCSVに書き出す
+func WriteCSV(w io.Writer, campaign *entities.CouponCampaign, codes []entities.CouponCode) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"code", "campaign_id", "campaign_name", "redeemed_count"}); err != nil {
+		return err
+	}
+	campaignID := strconv.FormatInt(int64(campaign.ID), 10)
+	for _, code := range codes {
+		if err := writer.Write([]string{FormatCode(code.Code), campaignID, campaign.Name, strconv.FormatInt(code.RedeemedCount, 10)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,75 @@
+package coupon
+
+import (
+	"strings"
+	"testing"
+
+	"42tokyo-road-to-dojo-go/pkg/randcode"
+)
+
+func TestValidCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{code: "ABCD", want: true},
+		{code: "SPRING2026", want: true},
+		{code: strings.Repeat("A", maxCodeLength), want: true},
+		{code: "ABC", want: false},
+		{code: strings.Repeat("A", maxCodeLength+1), want: false},
+		{code: "", want: false},
+		// 正規化した後のコードのみを受け付ける
+		{code: "abcd", want: false},
+		{code: "ABCD-EFGH", want: false},
+		{code: "ABC D", want: false},
+		{code: "ＡＢＣＤ", want: false},
+	}
+	for _, tt := range tests {
+		if got := ValidCode(tt.code); got != tt.want {
+			t.Errorf("ValidCode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{code: "", want: ""},
+		{code: "ABC", want: "ABC"},
+		{code: "ABCD", want: "ABCD"},
+		{code: "ABCDE", want: "ABCD-E"},
+		{code: "ABCDEFGHJKLM", want: "ABCD-EFGH-JKLM"},
+	}
+	for _, tt := range tests {
+		if got := FormatCode(tt.code); got != tt.want {
+			t.Errorf("FormatCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateCodes(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		codes, err := GenerateCodes(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(codes) != n {
+			t.Fatalf("len(GenerateCodes(%d)) = %d", n, len(codes))
+		}
+		seen := make(map[string]struct{}, n)
+		for _, code := range codes {
+			if len(code) != CodeLength || !ValidCode(code) {
+				t.Errorf("GenerateCodes(%d) returned an invalid code %q", n, code)
+			}
+			if strings.Trim(code, randcode.Alphabet) != "" {
+				t.Errorf("GenerateCodes(%d) returned %q with letters outside the alphabet", n, code)
+			}
+			if _, ok := seen[code]; ok {
+				t.Errorf("GenerateCodes(%d) returned %q twice", n, code)
+			}
+			seen[code] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// documentWriter エクスポートの項目を書き出す。項目の名前はJSONのキー、zipのファイル名に使う
+type documentWriter interface {
+	// 1つの値からなる項目を書き出す
+	writeObject(name string, v interface{}) error
+	// 記録の一覧からなる項目を書き始める。endListまでwriteRecordで記録を1件ずつ書き出す
+	beginList(name string) error
+	writeRecord(v interface{}) error
+	endList() error
+	close() error
+}
+
+// ユーザの個人データを全て読み込み、指定した形式で書き出す
+func writeDocument(w io.Writer, repos *repositories.Repositories, userID entities.UserID, format entities.DataExportFormat) error {
+	var doc documentWriter
+	switch format {
+	case entities.DataExportFormatJSON:
+		doc = &jsonDocumentWriter{w: w}
+	case entities.DataExportFormatZip:
+		doc = &zipDocumentWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unknown data export format: %v", format)
+	}
+
+	user, err := repos.UserRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := doc.writeObject("profile", user); err != nil {
+		return err
+	}
+	status, err := repos.UserStatusRepository.GetUserStatus(userID)
+	if err != nil {
+		return err
+	}
+	if err := doc.writeObject("accountStatus", status); err != nil {
+		return err
+	}
+
+	// 所持アイテム・チケット・ショップの購入数・コレクションの達成報酬は種類数までしかないため、ページに分けずに読み込む
+	userItems, err := repos.CollectionItemRepository.GetUserItems(userID)
+	if err != nil {
+		return err
+	}
+	collection := make([]entities.ExportedItem, 0, len(userItems))
+	for _, item := range userItems {
+		collection = append(collection, entities.ExportedItem{ItemID: item.ItemID, Count: item.Count, Level: item.Level})
+	}
+	if err := writeAll(doc, "collection", collection); err != nil {
+		return err
+	}
+	tickets, err := repos.TicketRepository.GetUserTickets(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeAll(doc, "tickets", tickets); err != nil {
+		return err
+	}
+	purchaseCounts, err := repos.ShopOfferRepository.GetUserPurchaseCounts(userID)
+	if err != nil {
+		return err
+	}
+	shopPurchases := make([]entities.ExportedShopPurchase, 0, len(purchaseCounts))
+	for offerID, count := range purchaseCounts {
+		shopPurchases = append(shopPurchases, entities.ExportedShopPurchase{OfferID: offerID, Count: count})
+	}
+	sort.Slice(shopPurchases, func(i, j int) bool { return shopPurchases[i].OfferID < shopPurchases[j].OfferID })
+	if err := writeAll(doc, "shopPurchases", shopPurchases); err != nil {
+		return err
+	}
+	achieved, err := repos.CollectionMilestoneRepository.GetAchievedMilestones(userID)
+	if err != nil {
+		return err
+	}
+	milestones := make([]entities.ExportedCollectionMilestone, 0, len(achieved))
+	for milestoneID, achievedAt := range achieved {
+		milestones = append(milestones, entities.ExportedCollectionMilestone{MilestoneID: milestoneID, AchievedAt: achievedAt})
+	}
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i].MilestoneID < milestones[j].MilestoneID })
+	if err := writeAll(doc, "collectionMilestones", milestones); err != nil {
+		return err
+	}
+
+	if err := writeList(doc, "scores", func(afterID int64) ([]entities.ExportedScore, error) {
+		return repos.UserScoresRepository.GetUserScoresAfter(userID, afterID, pageSize)
+	}, func(score entities.ExportedScore) int64 { return score.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "gachaDraws", func(afterID entities.GachaDrawID) ([]entities.GachaDraw, error) {
+		return repos.GachaDrawRepository.GetUserGachaDrawsAfter(userID, afterID, pageSize)
+	}, func(draw entities.GachaDraw) entities.GachaDrawID { return draw.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "coinTransactions", func(afterID entities.CoinLedgerID) ([]entities.CoinLedgerEntry, error) {
+		return repos.CoinLedgerRepository.GetUserCoinLedgerEntriesAfter(userID, afterID, pageSize)
+	}, func(entry entities.CoinLedgerEntry) entities.CoinLedgerID { return entry.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "purchases", func(afterID entities.PurchaseID) ([]entities.Purchase, error) {
+		return repos.PurchaseRepository.GetUserPurchasesAfter(userID, afterID, pageSize)
+	}, func(purchase entities.Purchase) entities.PurchaseID { return purchase.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "presents", func(afterID entities.PresentID) ([]entities.Present, error) {
+		return repos.PresentRepository.GetUserPresentsAfter(userID, afterID, pageSize)
+	}, func(present entities.Present) entities.PresentID { return present.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "trades", func(afterID entities.TradeID) ([]entities.Trade, error) {
+		return repos.TradeRepository.GetUserTradesAfter(userID, afterID, pageSize)
+	}, func(trade entities.Trade) entities.TradeID { return trade.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "marketListings", func(afterID entities.MarketListingID) ([]entities.MarketListing, error) {
+		return repos.MarketListingRepository.GetUserMarketListingsAfter(userID, afterID, pageSize)
+	}, func(listing entities.MarketListing) entities.MarketListingID { return listing.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "couponRedemptions", func(afterID int64) ([]entities.ExportedCouponRedemption, error) {
+		return repos.CouponRepository.GetUserRedemptionsAfter(userID, afterID, pageSize)
+	}, func(redemption entities.ExportedCouponRedemption) int64 { return redemption.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "sessions", func(afterID entities.SessionID) ([]entities.ExportedSession, error) {
+		sessions, err := repos.SessionRepository.GetUserSessionsAfter(userID, afterID, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		exported := make([]entities.ExportedSession, 0, len(sessions))
+		for _, session := range sessions {
+			exported = append(exported, entities.ExportedSession{
+				ID:         session.ID,
+				DeviceName: session.DeviceName,
+				CreatedAt:  session.CreatedAt,
+				LastSeenAt: session.LastSeenAt,
+				ExpiresAt:  session.ExpiresAt,
+				RevokedAt:  session.RevokedAt,
+			})
+		}
+		return exported, nil
+	}, func(session entities.ExportedSession) entities.SessionID { return session.ID }); err != nil {
+		return err
+	}
+	if err := writeList(doc, "transferAuditLogs", func(afterID int64) ([]entities.ExportedTransferAuditLog, error) {
+		return repos.TransferRepository.GetUserTransferAuditLogsAfter(userID, afterID, pageSize)
+	}, func(entry entities.ExportedTransferAuditLog) int64 { return entry.ID }); err != nil {
+		return err
+	}
+
+	return doc.close()
+}
+
+// writeAll 読み込み済みの記録を全て一覧として書き出す
+func writeAll[T any](doc documentWriter, name string, records []T) error {
+	if err := doc.beginList(name); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := doc.writeRecord(record); err != nil {
+			return err
+		}
+	}
+	return doc.endList()
+}
+
+// writeList 記録を1ページずつ読み込んで書き出す。cursorは書き出した記録から次のページの読み込みを始める位置を返す
+// 全ての記録をメモリに載せないように、空のページを読み込むまで繰り返す
+func writeList[T any, C any](doc documentWriter, name string, fetch func(after C) ([]T, error), cursor func(record T) C) error {
+	if err := doc.beginList(name); err != nil {
+		return err
+	}
+	var after C
+	for {
+		records, err := fetch(after)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			break
+		}
+		for _, record := range records {
+			if err := doc.writeRecord(record); err != nil {
+				return err
+			}
+			after = cursor(record)
+		}
+	}
+	return doc.endList()
+}
+
+// jsonDocumentWriter 全ての項目を1つのJSONのオブジェクトとして書き出す
+type jsonDocumentWriter struct {
+	w io.Writer
+	// 最初の項目を書き出したか
+	started bool
+	// 一覧の最初の記録を書き出したか
+	listStarted bool
+}
+
+func (d *jsonDocumentWriter) writeKey(name string) error {
+	prefix := ","
+	if !d.started {
+		prefix = "{"
+		d.started = true
+	}
+	key, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(d.w, "%s%s:", prefix, key)
+	return err
+}
+
+func (d *jsonDocumentWriter) writeObject(name string, v interface{}) error {
+	if err := d.writeKey(name); err != nil {
+		return err
+	}
+	return d.writeValue(v)
+}
+
+func (d *jsonDocumentWriter) beginList(name string) error {
+	if err := d.writeKey(name); err != nil {
+		return err
+	}
+	d.listStarted = false
+	_, err := io.WriteString(d.w, "[")
+	return err
+}
+
+func (d *jsonDocumentWriter) writeRecord(v interface{}) error {
+	if d.listStarted {
+		if _, err := io.WriteString(d.w, ","); err != nil {
+			return err
+		}
+	}
+	d.listStarted = true
+	return d.writeValue(v)
+}
+
+func (d *jsonDocumentWriter) writeValue(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = d.w.Write(b)
+	return err
+}
+
+func (d *jsonDocumentWriter) endList() error {
+	_, err := io.WriteString(d.w, "]")
+	return err
+}
+
+func (d *jsonDocumentWriter) close() error {
+	if !d.started {
+		_, err := io.WriteString(d.w, "{}")
+		return err
+	}
+	_, err := io.WriteString(d.w, "}")
+	return err
+}
+
+// zipDocumentWriter 1つの値からなる項目は<name>.json、記録の一覧は1行に1件の<name>.jsonlとしてzipに書き出す
+type zipDocumentWriter struct {
+	zw *zip.Writer
+	// 書き出し中の一覧のファイル
+	list io.Writer
+}
+
+func (d *zipDocumentWriter) writeObject(name string, v interface{}) error {
+	f, err := d.zw.Create(name + ".json")
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(f).Encode(v)
+}
+
+func (d *zipDocumentWriter) beginList(name string) error {
+	f, err := d.zw.Create(name + ".jsonl")
+	if err != nil {
+		return err
+	}
+	d.list = f
+	return nil
+}
+
+func (d *zipDocumentWriter) writeRecord(v interface{}) error {
+	return json.NewEncoder(d.list).Encode(v)
+}
+
+func (d *zipDocumentWriter) endList() error {
+	d.list = nil
+	return nil
+}
+
+func (d *zipDocumentWriter) close() error {
+	return d.zw.Close()
+}
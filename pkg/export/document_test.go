@@ -0,0 +1,138 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type testRecord struct {
+	ID int64 `json:"id"`
+}
+
+// idsのうちafterより後の記録をpageSize件まで返すfetchと、fetchを呼び出した回数
+func pagedRecords(ids []int64, pageSize int) (fetch func(after int64) ([]testRecord, error), calls *int) {
+	calls = new(int)
+	return func(after int64) ([]testRecord, error) {
+		*calls++
+		records := []testRecord{}
+		for _, id := range ids {
+			if id > after && len(records) < pageSize {
+				records = append(records, testRecord{ID: id})
+			}
+		}
+		return records, nil
+	}, calls
+}
+
+func TestWriteList(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       []int64
+		pageSize  int
+		wantCalls int
+	}{
+		{name: "記録がない", ids: nil, pageSize: 2, wantCalls: 1},
+		{name: "1ページ", ids: []int64{1, 2}, pageSize: 3, wantCalls: 2},
+		{name: "複数ページ", ids: []int64{1, 3, 4, 8, 9}, pageSize: 2, wantCalls: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			doc := &jsonDocumentWriter{w: &buf}
+			fetch, calls := pagedRecords(tt.ids, tt.pageSize)
+			if err := writeList(doc, "records", fetch, func(record testRecord) int64 { return record.ID }); err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got struct {
+				Records []testRecord `json:"records"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+			}
+			want := []testRecord{}
+			for _, id := range tt.ids {
+				want = append(want, testRecord{ID: id})
+			}
+			if !reflect.DeepEqual(got.Records, want) {
+				t.Errorf("records = %v, want %v", got.Records, want)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("fetch called %d times, want %d", *calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// 全ての項目の種類を書き出す
+func writeTestDocument(doc documentWriter) error {
+	if err := doc.writeObject("profile", map[string]string{"name": "test"}); err != nil {
+		return err
+	}
+	if err := writeAll(doc, "empty", []testRecord{}); err != nil {
+		return err
+	}
+	if err := writeAll(doc, "records", []testRecord{{ID: 1}, {ID: 2}}); err != nil {
+		return err
+	}
+	return doc.close()
+}
+
+func TestJSONDocumentWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTestDocument(&jsonDocumentWriter{w: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"profile":{"name":"test"},"empty":[],"records":[{"id":1},{"id":2}]}`
+	if buf.String() != want {
+		t.Errorf("document = %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := (&jsonDocumentWriter{w: &buf}).close(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "{}" {
+		t.Errorf("empty document = %s, want {}", buf.String())
+	}
+}
+
+func TestZipDocumentWriter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTestDocument(&zipDocumentWriter{zw: zip.NewWriter(&buf)}); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(b)
+	}
+	want := map[string]string{
+		"profile.json":  "{\"name\":\"test\"}\n",
+		"empty.jsonl":   "",
+		"records.jsonl": "{\"id\":1}\n{\"id\":2}\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("zip files = %q, want %q", got, want)
+	}
+}
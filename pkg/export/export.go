@@ -0,0 +1,147 @@
+// Package export ユーザの個人データのエクスポートを作成し、署名付きのダウンロード用URLを発行する。
+// 記録の多いユーザでもリクエストを待たせないように、作成はバックグラウンドで行う。
+// 作成したファイルは-data-export-dirに保存し、DBにはファイルのパスのみを保存する。
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// pageSize 1回のクエリで読み込む記録の件数
+	pageSize = 1000
+	// pendingTimeout 依頼してからこの期間を過ぎても作成中のエクスポートは、作成していたプロセスが止まったとみなす
+	pendingTimeout = 30 * time.Minute
+)
+
+var ErrExportInProgress = errors.New("data export is already in progress")
+
+// Request エクスポートを依頼し、バックグラウンドで作成を始める
+// 作成中のエクスポートがある場合はErrExportInProgressを返す
+func Request(repos *repositories.Repositories, conf *config.Config, userID entities.UserID, format entities.DataExportFormat) (*entities.DataExport, error) {
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	// 同時に依頼されても作成中のエクスポートが1つになるように、ユーザの行をロックしてから確認する
+	if _, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, userID); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	pending, err := repos.DataExportRepository.GetPendingDataExportTransaction(tx, userID, now.Add(-pendingTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, ErrExportInProgress
+	}
+	export, err := repos.DataExportRepository.AddDataExportTransaction(tx, userID, format, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	generateInBackground(repos, conf, export)
+	return export, nil
+}
+
+// エクスポートをバックグラウンドで作成する。失敗した場合はエクスポートに理由が記録され、ユーザは依頼し直せる
+// Generateは状態を書き換えるため、レスポンスに使う値とは別のコピーを渡す
+func generateInBackground(repos *repositories.Repositories, conf *config.Config, export *entities.DataExport) {
+	generating := *export
+	go func() {
+		if err := Generate(repos, conf, &generating); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// Generate エクスポートのファイルを作成して保存し、-data-export-ttlの期間ダウンロードできる状態にする
+func Generate(repos *repositories.Repositories, conf *config.Config, export *entities.DataExport) error {
+	// 定期実行の仕組みがないため、期限切れのファイルは新しいエクスポートを作成するたびに消す
+	expired, err := repos.DataExportRepository.ExpireDataExports(time.Now())
+	if err != nil {
+		log.Println(err)
+	}
+	RemoveFiles(expired)
+
+	filePath, err := writeFile(conf.DataExportDir, repos, export)
+	if err != nil {
+		if failErr := repos.DataExportRepository.FailDataExport(export.ID, err.Error(), time.Now()); failErr != nil {
+			log.Println(failErr)
+		}
+		return err
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(conf.DataExportTTL)
+	if err := repos.DataExportRepository.CompleteDataExport(export.ID, filePath, completedAt, expiresAt); err != nil {
+		RemoveFiles([]string{filePath})
+		return err
+	}
+	export.Status = entities.DataExportStatusReady
+	export.FilePath = filePath
+	export.CompletedAt = &completedAt
+	export.ExpiresAt = &expiresAt
+	return nil
+}
+
+// Download 署名を検証し、エクスポートのファイルを1回だけ開く。読み終えたらFileをCloseしてファイルを消すこと
+// 署名が正しくない場合はErrInvalidSignatureを、ダウンロード済み・期限切れの場合はrepositories.ErrDataExportUnavailableを返す
+func Download(repos *repositories.Repositories, conf *config.Config, ID entities.DataExportID, expires int64, signature string) (*entities.DataExport, *File, error) {
+	if !verify(conf.DataExportSigningKey, ID, expires, signature) {
+		return nil, nil, ErrInvalidSignature
+	}
+	now := time.Now()
+	if now.Unix() >= expires {
+		return nil, nil, repositories.ErrDataExportUnavailable
+	}
+
+	export, err := repos.DataExportRepository.GetDataExport(ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.Status != entities.DataExportStatusReady || export.FilePath == "" {
+		return nil, nil, repositories.ErrDataExportUnavailable
+	}
+	// ファイルを開けない場合にダウンロード済みにしないよう、開いてからダウンロード済みにする
+	file, err := openFile(export.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := repos.DataExportRepository.ClaimDataExportDownload(ID, now); err != nil {
+		// 同時にダウンロードした他のリクエストがファイルを消すため、閉じるだけにする
+		file.File.Close()
+		return nil, nil, err
+	}
+	downloadedAt := now.UTC().Truncate(time.Second)
+	export.Status = entities.DataExportStatusDownloaded
+	export.FilePath = ""
+	export.DownloadedAt = &downloadedAt
+	return export, file, nil
+}
+
+// ContentType エクスポートの形式に対応するContent-Type
+func ContentType(format entities.DataExportFormat) string {
+	if format == entities.DataExportFormatZip {
+		return "application/zip"
+	}
+	return "application/json"
+}
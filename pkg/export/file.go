@@ -0,0 +1,77 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// File ダウンロードするエクスポートのファイル。Closeでファイルを閉じて消す
+type File struct {
+	*os.File
+	// Size ファイルのバイト数
+	Size int64
+}
+
+func (f *File) Close() error {
+	err := f.File.Close()
+	RemoveFiles([]string{f.Name()})
+	return err
+}
+
+// ユーザの個人データをdirに新しく作成したファイルに書き出し、ファイルのパスを返す
+// 記録の多いユーザでもメモリに載せないように、読み込んだ記録から順にファイルへ書き出す
+// ファイル名は推測されないようにランダムにする。失敗した場合は書きかけのファイルを消す
+func writeFile(dir string, repos *repositories.Repositories, export *entities.DataExport) (filePath string, err error) {
+	f, err := os.CreateTemp(dir, fmt.Sprintf("export-%d-*.%s", export.ID, export.Format))
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			RemoveFiles([]string{f.Name()})
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	if err := writeDocument(w, repos, export.UserID, export.Format); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// openFile ダウンロードするファイルを開く
+func openFile(filePath string) (*File, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &File{File: f, Size: info.Size()}, nil
+}
+
+// RemoveFiles エクスポートのファイルを消す。既に消えているファイルは無視する
+func RemoveFiles(filePaths []string) {
+	for _, filePath := range filePaths {
+		if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+	}
+}
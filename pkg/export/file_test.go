@@ -0,0 +1,57 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCloseRemovesFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "export-1.json")
+	if err := os.WriteFile(filePath, []byte(`{"profile":{}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := openFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Size != 14 {
+		t.Errorf("Size = %d, want 14", file.Size)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != `{"profile":{}}` {
+		t.Errorf("content = %s", content)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filePath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file still exists after Close: %v", err)
+	}
+}
+
+func TestOpenFileMissing(t *testing.T) {
+	if _, err := openFile(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("openFile() error = %v, want %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestRemoveFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "export-1.zip")
+	if err := os.WriteFile(existing, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// 既に消えたファイルがあっても残りのファイルを消す
+	RemoveFiles([]string{filepath.Join(dir, "missing.zip"), existing})
+	if _, err := os.Stat(existing); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("file still exists after RemoveFiles: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// DownloadPath 署名付きのダウンロード用URLのパス
+const DownloadPath = "/user/export/download"
+
+var ErrInvalidSignature = errors.New("invalid download signature")
+
+// DownloadURL エクスポートのファイルをダウンロードするURLを返す。認証は不要で、URLの有効期限はエクスポートの期限と同じ
+// ダウンロードできる状態でない場合は空文字列を返す
+func DownloadURL(key []byte, export *entities.DataExport) string {
+	if export.Status != entities.DataExportStatusReady || export.ExpiresAt == nil {
+		return ""
+	}
+	expires := export.ExpiresAt.Unix()
+	values := url.Values{}
+	values.Set("exportId", strconv.FormatInt(int64(export.ID), 10))
+	values.Set("expires", strconv.FormatInt(expires, 10))
+	values.Set("signature", sign(key, export.ID, expires))
+	return DownloadPath + "?" + values.Encode()
+}
+
+// エクスポートIDと有効期限に対するHMAC-SHA256の署名(16進数)を返す
+func sign(key []byte, ID entities.DataExportID, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d:%d", ID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verify(key []byte, ID entities.DataExportID, expires int64, signature string) bool {
+	expected, err := hex.DecodeString(sign(key, ID, expires))
+	if err != nil {
+		return false
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, actual)
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+func TestDownloadURL(t *testing.T) {
+	key := []byte("signing-key")
+	expiresAt := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		export *entities.DataExport
+		want   bool
+	}{
+		{name: "ダウンロードできる", export: &entities.DataExport{ID: 5, Status: entities.DataExportStatusReady, ExpiresAt: &expiresAt}, want: true},
+		{name: "作成中", export: &entities.DataExport{ID: 5, Status: entities.DataExportStatusPending}, want: false},
+		{name: "期限が未設定", export: &entities.DataExport{ID: 5, Status: entities.DataExportStatusReady}, want: false},
+		{name: "ダウンロード済み", export: &entities.DataExport{ID: 5, Status: entities.DataExportStatusDownloaded, ExpiresAt: &expiresAt}, want: false},
+		{name: "期限切れ", export: &entities.DataExport{ID: 5, Status: entities.DataExportStatusExpired, ExpiresAt: &expiresAt}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DownloadURL(key, tt.export)
+			if !tt.want {
+				if got != "" {
+					t.Errorf("DownloadURL() = %q, want empty", got)
+				}
+				return
+			}
+
+			path, rawQuery, ok := strings.Cut(got, "?")
+			if !ok || path != DownloadPath {
+				t.Fatalf("DownloadURL() = %q, want %s?...", got, DownloadPath)
+			}
+			query, err := url.ParseQuery(rawQuery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query.Get("exportId") != "5" {
+				t.Errorf("exportId = %q, want 5", query.Get("exportId"))
+			}
+			expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+			if err != nil || expires != expiresAt.Unix() {
+				t.Errorf("expires = %q, want %d", query.Get("expires"), expiresAt.Unix())
+			}
+			if !verify(key, tt.export.ID, expires, query.Get("signature")) {
+				t.Errorf("signature %q does not verify", query.Get("signature"))
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := []byte("signing-key")
+	const (
+		ID      = entities.DataExportID(5)
+		expires = int64(1775001600)
+	)
+	signature := sign(key, ID, expires)
+
+	tests := []struct {
+		name      string
+		key       []byte
+		ID        entities.DataExportID
+		expires   int64
+		signature string
+		want      bool
+	}{
+		{name: "正しい署名", key: key, ID: ID, expires: expires, signature: signature, want: true},
+		{name: "大文字の16進数", key: key, ID: ID, expires: expires, signature: strings.ToUpper(signature), want: true},
+		{name: "鍵が異なる", key: []byte("other-key"), ID: ID, expires: expires, signature: signature, want: false},
+		{name: "エクスポートIDを書き換えた", key: key, ID: ID + 1, expires: expires, signature: signature, want: false},
+		{name: "有効期限を書き換えた", key: key, ID: ID, expires: expires + 1, signature: signature, want: false},
+		{name: "署名が短い", key: key, ID: ID, expires: expires, signature: signature[:len(signature)-2], want: false},
+		{name: "16進数でない", key: key, ID: ID, expires: expires, signature: "zz" + signature[2:], want: false},
+		{name: "署名がない", key: key, ID: ID, expires: expires, signature: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verify(tt.key, tt.ID, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package gacha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// SeedSize 公平ガチャの秘密シードのバイト数
+const SeedSize = 32
+
+// NewSeed 公平ガチャ用の秘密シードを生成し、16進文字列で返す
+func NewSeed() (string, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(seed), nil
+}
+
+// HashSeed 事前に公開するシードのハッシュ(SHA-256の16進文字列)を返す
+func HashSeed(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewFairRand シード・ユーザID・クライアントのノンスから決定的な乱数生成器を作る。
+// 鍵 = HMAC-SHA256(seed, "userID:nonce")、i番目のブロック = SHA-256(鍵 || i(ビッグエンディアン8バイト))
+// とし、ブロックを先頭から8バイトずつ取り出して乱数とする。
+func NewFairRand(seed string, userID entities.UserID, nonce string) *mathrand.Rand {
+	mac := hmac.New(sha256.New, []byte(seed))
+	mac.Write([]byte(fmt.Sprintf("%d:%s", userID, nonce)))
+	return mathrand.New(&fairSource{key: mac.Sum(nil)})
+}
+
+// fairSource math/rand.Source64 の決定的な実装
+type fairSource struct {
+	key     []byte
+	counter uint64
+	block   []byte
+}
+
+func (s *fairSource) Uint64() uint64 {
+	if len(s.block) < 8 {
+		var counter [8]byte
+		binary.BigEndian.PutUint64(counter[:], s.counter)
+		s.counter++
+		sum := sha256.Sum256(append(append([]byte{}, s.key...), counter[:]...))
+		s.block = sum[:]
+	}
+	v := binary.BigEndian.Uint64(s.block[:8])
+	s.block = s.block[8:]
+	return v
+}
+
+func (s *fairSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// Seed 結果の再現性を保つため、外部からのシード変更は無視する
+func (s *fairSource) Seed(int64) {}
@@ -0,0 +1,86 @@
+package gacha
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+func TestHashSeed(t *testing.T) {
+	tests := []struct {
+		seed string
+		want string
+	}{
+		{seed: "", want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{seed: "abc", want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, tt := range tests {
+		if got := HashSeed(tt.seed); got != tt.want {
+			t.Errorf("HashSeed(%q) = %s, want %s", tt.seed, got, tt.want)
+		}
+	}
+}
+
+func TestNewSeed(t *testing.T) {
+	first, err := NewSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := hex.DecodeString(first)
+	if err != nil {
+		t.Fatalf("NewSeed() = %q is not hex: %v", first, err)
+	}
+	if len(decoded) != SeedSize {
+		t.Errorf("len(NewSeed()) = %d bytes, want %d", len(decoded), SeedSize)
+	}
+	second, err := NewSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("NewSeed() returned the same seed twice: %s", first)
+	}
+}
+
+func TestNewFairRand(t *testing.T) {
+	sequence := func(seed string, userID entities.UserID, nonce string) [4]int64 {
+		rng := NewFairRand(seed, userID, nonce)
+		var values [4]int64
+		for i := range values {
+			values[i] = rng.Int63()
+		}
+		return values
+	}
+	base := sequence("seed", 1, "nonce")
+
+	tests := []struct {
+		name   string
+		seed   string
+		userID entities.UserID
+		nonce  string
+		same   bool
+	}{
+		{name: "同じ入力", seed: "seed", userID: 1, nonce: "nonce", same: true},
+		{name: "シードが異なる", seed: "seed2", userID: 1, nonce: "nonce", same: false},
+		{name: "ユーザIDが異なる", seed: "seed", userID: 2, nonce: "nonce", same: false},
+		{name: "ノンスが異なる", seed: "seed", userID: 1, nonce: "nonce2", same: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sequence(tt.seed, tt.userID, tt.nonce)
+			if (got == base) != tt.same {
+				t.Errorf("sequence = %v, base = %v, want same = %v", got, base, tt.same)
+			}
+		})
+	}
+}
+
+func TestFairSourceIgnoresSeed(t *testing.T) {
+	rng := NewFairRand("seed", 1, "nonce")
+	rng.Seed(42)
+	want := NewFairRand("seed", 1, "nonce").Int63()
+	if got := rng.Int63(); got != want {
+		t.Errorf("Int63() after Seed = %d, want %d", got, want)
+	}
+}
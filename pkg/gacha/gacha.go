@@ -0,0 +1,91 @@
+package gacha
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrEmptyPool = errors.New("gacha pool is empty")
+
+// Pool 排出対象のアイテムと重みの一覧
+type Pool struct {
+	Items       []entities.ItemWithWeight
+	TotalWeight entities.Weight
+}
+
+// NewPool アイテム一覧とゲーム設定から、指定した日時に排出できるアイテムの排出対象を作成する。
+// settingのNWeight, RWeight, SrWeightを各itemのrarityに応じて採用する。
+// キャッシュから取得したアイテムは順序が不定なので、結果を再現できるようにID順に並べる。
+func NewPool(items entities.Items, settings *entities.GameSettings, at time.Time) *Pool {
+	sorted := make(entities.Items, 0, len(items))
+	for _, item := range items {
+		if item.IsDrawable(at) {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	pool := &Pool{Items: make([]entities.ItemWithWeight, 0, len(sorted))}
+	for _, item := range sorted {
+		var weight entities.Weight
+		switch item.Rarity {
+		case entities.N:
+			weight = settings.NWeight
+		case entities.R:
+			weight = settings.RWeight
+		case entities.SR:
+			weight = settings.SrWeight
+		}
+		pool.Items = append(pool.Items, entities.ItemWithWeight{Item: item, Weight: weight})
+		pool.TotalWeight += weight
+	}
+	return pool
+}
+
+// NewPoolFromEntries 保存した排出対象から抽選時と同じ排出対象を作成する。entriesの順序をそのまま抽選順とする
+func NewPoolFromEntries(entries []entities.GachaPoolEntry) *Pool {
+	pool := &Pool{Items: make([]entities.ItemWithWeight, 0, len(entries))}
+	for _, entry := range entries {
+		pool.Items = append(pool.Items, entities.ItemWithWeight{Item: entities.Item{ID: entry.ItemID}, Weight: entry.Weight})
+		pool.TotalWeight += entry.Weight
+	}
+	return pool
+}
+
+// Entries 抽選順のアイテムIDと重みの一覧を返す。NewPoolFromEntriesに渡すと同じ排出対象になる
+func (p *Pool) Entries() []entities.GachaPoolEntry {
+	entries := make([]entities.GachaPoolEntry, 0, len(p.Items))
+	for _, item := range p.Items {
+		entries = append(entries, entities.GachaPoolEntry{ItemID: item.ID, Weight: item.Weight})
+	}
+	return entries
+}
+
+// Draw times回ガチャを引き、排出されたアイテムのIDを返す。
+// 同じ乱数生成器を渡せば同じ結果になる。
+func (p *Pool) Draw(rng *rand.Rand, times int64) ([]entities.ItemID, error) {
+	if p.TotalWeight <= 0 {
+		return nil, ErrEmptyPool
+	}
+
+	gachaGetIDs := make([]entities.ItemID, 0, times)
+	for i := int64(0); i < times; i++ {
+		// 乱数を生成
+		randomNum := rng.Int63n(int64(p.TotalWeight))
+
+		// 重み付けの合計を超えるまで、重み付けを足していく
+		var weightSum int64 = 0
+		for _, itemWithWeight := range p.Items {
+			weightSum += int64(itemWithWeight.Weight)
+			if randomNum < weightSum {
+				gachaGetIDs = append(gachaGetIDs, itemWithWeight.ID)
+				break
+			}
+		}
+	}
+	return gachaGetIDs, nil
+}
@@ -0,0 +1,154 @@
+package gacha
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+func TestNewPool(t *testing.T) {
+	at := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	before := at.Add(-time.Hour)
+	after := at.Add(time.Hour)
+	settings := &entities.GameSettings{NWeight: 60, RWeight: 30, SrWeight: 10}
+
+	tests := []struct {
+		name        string
+		items       entities.Items
+		want        []entities.GachaPoolEntry
+		totalWeight entities.Weight
+	}{
+		{
+			name:        "空のアイテム一覧",
+			items:       entities.Items{},
+			want:        []entities.GachaPoolEntry{},
+			totalWeight: 0,
+		},
+		{
+			name: "レアリティごとの重みをID順に並べる",
+			items: entities.Items{
+				{ID: 3, Rarity: entities.SR},
+				{ID: 1, Rarity: entities.N},
+				{ID: 2, Rarity: entities.R},
+			},
+			want:        []entities.GachaPoolEntry{{ItemID: 1, Weight: 60}, {ItemID: 2, Weight: 30}, {ItemID: 3, Weight: 10}},
+			totalWeight: 100,
+		},
+		{
+			name: "状態がactive以外のアイテムを除く",
+			items: entities.Items{
+				{ID: 1, Rarity: entities.N, Status: entities.ItemStatusActive},
+				{ID: 2, Rarity: entities.N, Status: entities.ItemStatusRetired},
+				{ID: 3, Rarity: entities.N, Status: entities.ItemStatusHidden},
+				{ID: 4, Rarity: entities.R},
+			},
+			want:        []entities.GachaPoolEntry{{ItemID: 1, Weight: 60}, {ItemID: 4, Weight: 30}},
+			totalWeight: 90,
+		},
+		{
+			name: "公開前・排出終了後のアイテムを除く",
+			items: entities.Items{
+				{ID: 1, Rarity: entities.N, ReleasedAt: &before},
+				{ID: 2, Rarity: entities.N, ReleasedAt: &after},
+				{ID: 3, Rarity: entities.N, RetiredAt: &at},
+				{ID: 4, Rarity: entities.N, RetiredAt: &after},
+				{ID: 5, Rarity: entities.N, HiddenUntilReleased: true},
+			},
+			want:        []entities.GachaPoolEntry{{ItemID: 1, Weight: 60}, {ItemID: 4, Weight: 60}},
+			totalWeight: 120,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(tt.items, settings, at)
+			if got := pool.Entries(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entries() = %v, want %v", got, tt.want)
+			}
+			if pool.TotalWeight != tt.totalWeight {
+				t.Errorf("TotalWeight = %d, want %d", pool.TotalWeight, tt.totalWeight)
+			}
+		})
+	}
+}
+
+func TestNewPoolFromEntries(t *testing.T) {
+	entries := []entities.GachaPoolEntry{{ItemID: 5, Weight: 10}, {ItemID: 2, Weight: 30}}
+	pool := NewPoolFromEntries(entries)
+	if got := pool.Entries(); !reflect.DeepEqual(got, entries) {
+		t.Errorf("Entries() = %v, want %v", got, entries)
+	}
+	if pool.TotalWeight != 40 {
+		t.Errorf("TotalWeight = %d, want 40", pool.TotalWeight)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entities.GachaPoolEntry
+		times   int64
+		want    []entities.ItemID
+		wantErr error
+	}{
+		{
+			name:    "排出対象がない",
+			entries: nil,
+			times:   1,
+			wantErr: ErrEmptyPool,
+		},
+		{
+			name:    "重みの合計が0",
+			entries: []entities.GachaPoolEntry{{ItemID: 1, Weight: 0}},
+			times:   1,
+			wantErr: ErrEmptyPool,
+		},
+		{
+			name:    "重みのあるアイテムが1つだけ",
+			entries: []entities.GachaPoolEntry{{ItemID: 1, Weight: 0}, {ItemID: 2, Weight: 5}, {ItemID: 3, Weight: 0}},
+			times:   3,
+			want:    []entities.ItemID{2, 2, 2},
+		},
+		{
+			name:    "0回",
+			entries: []entities.GachaPoolEntry{{ItemID: 1, Weight: 1}},
+			times:   0,
+			want:    []entities.ItemID{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPoolFromEntries(tt.entries).Draw(rand.New(rand.NewSource(1)), tt.times)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Draw() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Draw() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrawIsReproducible(t *testing.T) {
+	pool := NewPoolFromEntries([]entities.GachaPoolEntry{{ItemID: 1, Weight: 60}, {ItemID: 2, Weight: 30}, {ItemID: 3, Weight: 10}})
+	first, err := pool.Draw(NewFairRand("seed", 1, "nonce"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 保存した排出対象から作り直しても、同じ乱数生成器なら同じ結果になる
+	second, err := NewPoolFromEntries(pool.Entries()).Draw(NewFairRand("seed", 1, "nonce"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Draw() = %v, want %v", second, first)
+	}
+	for _, id := range first {
+		if id < 1 || id > 3 {
+			t.Fatalf("Draw() returned an item outside the pool: %d", id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Package verifier 公開されたシードから公平ガチャの結果を再計算して検証する。
+// サーバと同じ gacha.Pool の抽選処理を使うため、クライアントや第三者も同じ結果を得られる。
+package verifier
+
+import (
+	"errors"
+	"fmt"
+
+	"42tokyo-road-to-dojo-go/pkg/gacha"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrSeedNotRevealed = errors.New("seed is not revealed yet")
+	ErrSeedMismatch    = errors.New("seed does not match the published hash")
+	ErrNotFairDraw     = errors.New("draw was not made in provably fair mode")
+	// ErrPoolNotRecorded 排出対象を保存する前の実行記録のため、抽選時の排出対象が分からない
+	ErrPoolNotRecorded = errors.New("gacha pool of the draw is not recorded")
+)
+
+// Recompute シード・ユーザID・ノンスと抽選時の排出対象からガチャの結果を再計算する
+func Recompute(seed string, userID entities.UserID, nonce string, times int64, pool []entities.GachaPoolEntry) ([]entities.ItemID, error) {
+	return gacha.NewPoolFromEntries(pool).Draw(gacha.NewFairRand(seed, userID, nonce), times)
+}
+
+// Verify 記録されたガチャの結果を公開済みのシードで再計算し、一致するか検証する。
+// 抽選後にアイテムやゲーム設定を変更しても結果が変わらないように、実行記録に保存した排出対象を使う
+func Verify(draw *entities.GachaDraw, seed *entities.GachaSeed) (*entities.GachaVerifyResult, error) {
+	if draw.SeedID == nil {
+		return nil, ErrNotFairDraw
+	}
+	if *draw.SeedID != seed.ID {
+		return nil, fmt.Errorf("draw %d was made with seed %d, not %d", draw.ID, *draw.SeedID, seed.ID)
+	}
+	if seed.Seed == "" {
+		return nil, ErrSeedNotRevealed
+	}
+	if gacha.HashSeed(seed.Seed) != seed.SeedHash {
+		return nil, ErrSeedMismatch
+	}
+	if len(draw.Pool) == 0 {
+		return nil, ErrPoolNotRecorded
+	}
+
+	expected, err := Recompute(seed.Seed, draw.UserID, draw.Nonce, draw.Times, draw.Pool)
+	if err != nil {
+		return nil, err
+	}
+
+	verified := len(expected) == len(draw.ItemIDs)
+	for i := 0; verified && i < len(expected); i++ {
+		verified = expected[i] == draw.ItemIDs[i]
+	}
+
+	return &entities.GachaVerifyResult{
+		DrawID:   draw.ID,
+		SeedID:   seed.ID,
+		Seed:     seed.Seed,
+		SeedHash: seed.SeedHash,
+		Nonce:    draw.Nonce,
+		Pool:     draw.Pool,
+		Expected: expected,
+		Actual:   draw.ItemIDs,
+		Verified: verified,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package verifier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/gacha"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const testSeed = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// サーバと同じ手順で公平ガチャを引き、実行記録と公開済みのシードを返す
+func newFairDraw(t *testing.T, items entities.Items, settings *entities.GameSettings, at time.Time) (*entities.GachaDraw, *entities.GachaSeed) {
+	t.Helper()
+	seed := &entities.GachaSeed{ID: 7, SeedHash: gacha.HashSeed(testSeed), Seed: testSeed}
+	pool := gacha.NewPool(items, settings, at)
+	itemIDs, err := pool.Draw(gacha.NewFairRand(testSeed, 1, "nonce"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedID := seed.ID
+	return &entities.GachaDraw{
+		ID:      3,
+		UserID:  1,
+		SeedID:  &seedID,
+		Nonce:   "nonce",
+		Times:   10,
+		ItemIDs: itemIDs,
+		Pool:    pool.Entries(),
+		PoolAt:  &at,
+	}, seed
+}
+
+func testItems() entities.Items {
+	return entities.Items{
+		{ID: 1, Rarity: entities.N},
+		{ID: 2, Rarity: entities.N},
+		{ID: 3, Rarity: entities.R},
+		{ID: 4, Rarity: entities.SR},
+	}
+}
+
+func TestRecompute(t *testing.T) {
+	pool := []entities.GachaPoolEntry{{ItemID: 1, Weight: 60}, {ItemID: 3, Weight: 30}, {ItemID: 4, Weight: 10}}
+	want, err := gacha.NewPoolFromEntries(pool).Draw(gacha.NewFairRand(testSeed, 1, "nonce"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Recompute(testSeed, 1, "nonce", 10, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Recompute() = %v, want %v", got, want)
+	}
+
+	if _, err := Recompute(testSeed, 1, "nonce", 10, nil); !errors.Is(err, gacha.ErrEmptyPool) {
+		t.Errorf("Recompute() with empty pool error = %v, want %v", err, gacha.ErrEmptyPool)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	at := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	settings := &entities.GameSettings{NWeight: 60, RWeight: 30, SrWeight: 10}
+
+	tests := []struct {
+		name         string
+		modify       func(draw *entities.GachaDraw, seed *entities.GachaSeed)
+		wantErr      error
+		wantVerified bool
+	}{
+		{
+			name:         "記録と一致する",
+			modify:       func(draw *entities.GachaDraw, seed *entities.GachaSeed) {},
+			wantVerified: true,
+		},
+		{
+			name: "記録した結果が改ざんされている",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				draw.ItemIDs[0] = 99
+			},
+			wantVerified: false,
+		},
+		{
+			name: "記録した結果の件数が異なる",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				draw.ItemIDs = draw.ItemIDs[:9]
+			},
+			wantVerified: false,
+		},
+		{
+			name: "公平モードでない",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				draw.SeedID = nil
+			},
+			wantErr: ErrNotFairDraw,
+		},
+		{
+			name: "シードを公開していない",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				seed.Seed = ""
+			},
+			wantErr: ErrSeedNotRevealed,
+		},
+		{
+			name: "シードがハッシュと一致しない",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				seed.Seed = testSeed[1:] + "0"
+			},
+			wantErr: ErrSeedMismatch,
+		},
+		{
+			name: "排出対象を保存する前の実行記録",
+			modify: func(draw *entities.GachaDraw, seed *entities.GachaSeed) {
+				draw.Pool = nil
+				draw.PoolAt = nil
+			},
+			wantErr: ErrPoolNotRecorded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draw, seed := newFairDraw(t, testItems(), settings, at)
+			tt.modify(draw, seed)
+			result, err := Verify(draw, seed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if result.Verified != tt.wantVerified {
+				t.Errorf("Verified = %v, want %v (expected %v, actual %v)", result.Verified, tt.wantVerified, result.Expected, result.Actual)
+			}
+		})
+	}
+}
+
+func TestVerifyOtherSeed(t *testing.T) {
+	draw, seed := newFairDraw(t, testItems(), &entities.GameSettings{NWeight: 60, RWeight: 30, SrWeight: 10}, time.Now())
+	seed.ID = 8
+	if _, err := Verify(draw, seed); err == nil {
+		t.Errorf("Verify() with seed %d for a draw made with seed %d succeeded", seed.ID, *draw.SeedID)
+	}
+}
+
+// 抽選後にアイテムの状態・排出期間やゲーム設定の重みを変更しても、保存した排出対象で検証できる
+func TestVerifyAfterMasterDataChange(t *testing.T) {
+	at := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	settings := &entities.GameSettings{NWeight: 60, RWeight: 30, SrWeight: 10}
+	draw, seed := newFairDraw(t, testItems(), settings, at)
+
+	changed := testItems()
+	changed[0].Status = entities.ItemStatusRetired
+	retiredAt := at.Add(time.Hour)
+	changed[1].RetiredAt = &retiredAt
+	changedSettings := &entities.GameSettings{NWeight: 10, RWeight: 30, SrWeight: 60}
+	current := gacha.NewPool(changed, changedSettings, retiredAt)
+	if reflect.DeepEqual(current.Entries(), draw.Pool) {
+		t.Fatal("changing the master data did not change the pool")
+	}
+
+	result, err := Verify(draw, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Verified {
+		t.Errorf("Verified = false after changing the master data (expected %v, actual %v)", result.Expected, result.Actual)
+	}
+	if !reflect.DeepEqual(result.Pool, draw.Pool) {
+		t.Errorf("Pool = %v, want %v", result.Pool, draw.Pool)
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/auth/authcontext"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+)
+
+// AdminAuthenticate x-admin-tokenヘッダを起動時に指定した管理用トークンと照合する
+// 管理用トークンが指定されていない場合は、管理APIを無効にする
+// 認証したリクエストは管理者の権限を持つリクエスト元としてContextへ保存する
+func AdminAuthenticate(conf *config.Config, nextFunc http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if conf.AdminToken == "" {
+			response.SetStatusAndJson(writer, http.StatusForbidden, map[string]string{"error": "admin api is disabled"})
+			return
+		}
+
+		token := request.Header.Get("x-admin-token")
+		if token == "" {
+			response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": "x-admin-token header is required"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(conf.AdminToken)) != 1 {
+			response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": "invalid admin token"})
+			return
+		}
+
+		ctx := authcontext.WithPrincipal(request.Context(), &authcontext.Principal{Roles: []authcontext.Role{authcontext.RoleAdmin}})
+		nextFunc(writer, request.WithContext(ctx))
+	}
+}
@@ -1,17 +1,23 @@
 package middleware
 
 import (
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/auth/authcontext"
 	"42tokyo-road-to-dojo-go/pkg/http/response"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
 	"42tokyo-road-to-dojo-go/pkg/server/entities"
 	"context"
+	"log"
 	"net/http"
+	"time"
 )
 
-// Authenticate ユーザ認証を行ってContextへユーザID情報を保存する
+// Authenticate トークンからセッションを特定してユーザ認証を行い、Contextへリクエスト元のユーザとセッションを保存する
+// 期限切れ・失効したトークンは、それぞれ異なるエラーコードで拒否する
+// 一時停止中・凍結されたユーザのリクエストは403で拒否し、理由と一時停止の解除日時を返す
+// トークンの記録はキャッシュから引くため、認証のたびにDBへ問い合わせない
 func Authenticate(repos *repositories.Repositories, nextFunc http.HandlerFunc) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		userRepo := repos.UserRepository
 		ctx := request.Context()
 		if ctx == nil {
 			ctx = context.Background()
@@ -24,15 +30,42 @@ func Authenticate(repos *repositories.Repositories, nextFunc http.HandlerFunc) h
 			return
 		}
 
-		authToken := entities.AuthToken(token)
-		userID, err := userRepo.GetUserIDAuthToken(authToken)
+		now := time.Now()
+		record, err := auth.Verify(repos, entities.AuthToken(token), now)
 		if err != nil {
-			response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			if code := auth.ErrorCode(err); code != "" {
+				response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": err.Error(), "code": code})
+				return
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
 
-		// ユーザIDをContextへ保存
-		ctx = context.WithValue(ctx, "userID", userID)
+		status, err := auth.CheckAccountStatus(repos, record.UserID, now)
+		if err != nil {
+			if code := auth.ErrorCode(err); code != "" {
+				body := map[string]interface{}{"error": err.Error(), "code": code, "reason": status.Reason}
+				if status.EffectiveStatus(now) == entities.UserStatusSuspended {
+					body["suspendedUntil"] = status.SuspendedUntil
+				}
+				response.SetStatusAndJson(writer, http.StatusForbidden, body)
+				return
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		auth.TouchSession(repos, record.SessionID, now)
+
+		// リクエスト元をContextへ保存
+		ctx = authcontext.WithPrincipal(ctx, &authcontext.Principal{
+			UserID:         record.UserID,
+			SessionID:      record.SessionID,
+			Roles:          []authcontext.Role{authcontext.RoleUser},
+			TokenExpiresAt: record.ExpiresAt,
+		})
 
 		// 次のハンドラを実行
 		nextFunc(writer, request.WithContext(ctx))
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/auth/authcontext"
+	httprequest "42tokyo-road-to-dojo-go/pkg/http/request"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/ratelimit"
+)
+
+// RateLimitKey リクエスト元を区別するキーを返す
+type RateLimitKey func(request *http.Request) string
+
+// ByIP リクエスト元のIPアドレスごとに数える。認証が不要なAPIに使う
+func ByIP(request *http.Request) string {
+	return "ip:" + httprequest.ClientIP(request)
+}
+
+// ByUser 認証されたユーザごとに数える。Authenticateの内側で使い、認証されていない場合はIPアドレスごとに数える
+func ByUser(request *http.Request) string {
+	if userID, ok := authcontext.UserID(request.Context()); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return ByIP(request)
+}
+
+// RateLimit ルートへのリクエストの回数をリクエスト元ごとに制限し、超えた場合は429とRetry-Afterヘッダを返す
+// limiterがnil、または制限が指定されていない場合は制限しない
+// Limiterが失敗した場合は、制限のためにAPIを止めないようリクエストを通す
+func RateLimit(limiter ratelimit.Limiter, route string, limit ratelimit.Limit, key RateLimitKey, nextFunc http.HandlerFunc) http.HandlerFunc {
+	if limiter == nil || limit.IsZero() {
+		return nextFunc
+	}
+	return func(writer http.ResponseWriter, request *http.Request) {
+		result, err := limiter.Allow(request.Context(), route+":"+key(request), limit)
+		if err != nil {
+			log.Println(err)
+			nextFunc(writer, request)
+			return
+		}
+		if !result.Allowed {
+			writer.Header().Set("Retry-After", fmt.Sprint(int64(math.Ceil(result.RetryAfter.Seconds()))))
+			response.SetStatusAndJson(writer, http.StatusTooManyRequests, map[string]string{"error": "too many requests, try again later"})
+			return
+		}
+
+		nextFunc(writer, request)
+	}
+}
@@ -0,0 +1,15 @@
+package request
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP リクエスト元のIPアドレスを返す
+func ClientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
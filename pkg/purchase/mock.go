@@ -0,0 +1,48 @@
+package purchase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// MockVerifier 開発・テスト用のVerifier。ストアに問い合わせず、JSONのレシートをそのまま信用する
+// レシート: {"transactionId": "tx-1", "productId": "coin_100"}
+// 返金通知: {"transactionId": "tx-1"}
+// 誰でも任意のコインを得られるため、本番環境では使わないこと
+type MockVerifier struct{}
+
+func (v *MockVerifier) VerifyReceipt(ctx context.Context, store entities.PurchaseStore, receipt string) (*Transaction, error) {
+	var body struct {
+		TransactionID string     `json:"transactionId"`
+		ProductID     string     `json:"productId"`
+		PurchasedAt   *time.Time `json:"purchasedAt"`
+	}
+	if err := json.Unmarshal([]byte(receipt), &body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReceipt, err)
+	}
+	if body.TransactionID == "" || body.ProductID == "" {
+		return nil, fmt.Errorf("%w: transactionId and productId are required", ErrInvalidReceipt)
+	}
+	transaction := Transaction{Store: store, TransactionID: body.TransactionID, ProductID: body.ProductID, PurchasedAt: time.Now()}
+	if body.PurchasedAt != nil {
+		transaction.PurchasedAt = *body.PurchasedAt
+	}
+	return &transaction, nil
+}
+
+func (v *MockVerifier) VerifyRefundNotification(ctx context.Context, store entities.PurchaseStore, body []byte) (*Refund, error) {
+	var notification struct {
+		TransactionID string `json:"transactionId"`
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReceipt, err)
+	}
+	if notification.TransactionID == "" {
+		return nil, fmt.Errorf("%w: transactionId is required", ErrInvalidReceipt)
+	}
+	return &Refund{Store: store, TransactionID: notification.TransactionID}, nil
+}
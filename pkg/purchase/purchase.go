@@ -0,0 +1,51 @@
+// Package purchase ストアのレシートと返金通知を検証する。
+// ストアごとの検証はVerifierインタフェースの実装に任せ、起動時の設定で切り替える。
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// ErrInvalidReceipt レシートや通知が不正、または検証に失敗した
+var ErrInvalidReceipt = errors.New("invalid receipt")
+
+type (
+	// Transaction ストアで検証済みの取引
+	Transaction struct {
+		Store         entities.PurchaseStore
+		TransactionID string
+		ProductID     string
+		PurchasedAt   time.Time
+	}
+
+	// Refund ストアから通知された返金
+	Refund struct {
+		Store         entities.PurchaseStore
+		TransactionID string
+	}
+
+	// Verifier ストアのレシートと返金通知を検証する
+	// 不正なレシートや通知の場合はErrInvalidReceiptを返し、ストアとの通信の失敗などはそれ以外のエラーを返す
+	Verifier interface {
+		VerifyReceipt(ctx context.Context, store entities.PurchaseStore, receipt string) (*Transaction, error)
+		VerifyRefundNotification(ctx context.Context, store entities.PurchaseStore, body []byte) (*Refund, error)
+	}
+)
+
+// NewVerifier 名前に対応するVerifierを作成する。空の場合はnilを返し、課金を無効にする
+// ストアの本番の検証は、このインタフェースを実装したVerifierをここに追加する
+func NewVerifier(name string) (Verifier, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "mock":
+		return &MockVerifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown purchase verifier: %q", name)
+	}
+}
@@ -0,0 +1,29 @@
+// Package randcode 人が読み取って入力するランダムなコードを生成する。
+// クーポンのシリアルコードと、引き継ぎIDとパスワードに使う。
+package randcode
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const (
+	// Alphabet コードに使う文字。読み間違えやすい0, O, 1, Iは使わない
+	Alphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	// SecureLength 総当たりで当てられないコードの長さ。32文字から12文字で約60bitになる
+	SecureLength = 12
+)
+
+// New Alphabetの文字からなる長さnのコードをcrypto/randで生成する
+func New(n int) (string, error) {
+	max := big.NewInt(int64(len(Alphabet)))
+	buf := make([]byte, n)
+	for i := range buf {
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = Alphabet[v.Int64()]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,35 @@
+package randcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, n := range []int{0, 1, SecureLength, 64} {
+		code, err := New(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != n {
+			t.Errorf("len(New(%d)) = %d", n, len(code))
+		}
+		if strings.Trim(code, Alphabet) != "" {
+			t.Errorf("New(%d) = %q has letters outside the alphabet", n, code)
+		}
+	}
+}
+
+func TestAlphabet(t *testing.T) {
+	if len(Alphabet) != 32 {
+		t.Errorf("len(Alphabet) = %d, want 32", len(Alphabet))
+	}
+	if strings.ContainsAny(Alphabet, "0O1I") {
+		t.Errorf("Alphabet %q contains a letter that is easy to misread", Alphabet)
+	}
+	for i, r := range Alphabet {
+		if strings.IndexRune(Alphabet, r) != i {
+			t.Errorf("Alphabet %q contains %q twice", Alphabet, r)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// 満タンまで回復したバケットを捨てる間隔
+const memorySweepInterval = time.Minute
+
+// MemoryLimiter プロセス内でバケットを保持するLimiter。サーバごとに別々に数える
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	at     time.Time
+	// バケットが満タンまで回復する日時。過ぎたバケットは新しいバケットと区別できないため捨てる
+	fullAt time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*memoryBucket{}, lastSweep: time.Now()}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	capacity := float64(limit.Count)
+	rate := limit.ratePerMillisecond()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, at: now}
+		l.buckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.at); elapsed > 0 {
+		bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed.Milliseconds())*rate)
+		bucket.at = now
+	}
+
+	result := Result{}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1-bucket.tokens)/rate)) * time.Millisecond
+	}
+	result.Remaining = int64(bucket.tokens)
+	bucket.fullAt = now.Add(time.Duration(math.Ceil((capacity-bucket.tokens)/rate)) * time.Millisecond)
+	return &result, nil
+}
+
+// 満タンまで回復したバケットを捨てる。リクエスト元が増え続けてもメモリを使い切らないようにする
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
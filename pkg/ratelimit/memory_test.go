@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	// 回復を待たないように、期間はテストより十分長くする
+	limit := Limit{Count: 3, Period: time.Hour}
+
+	tests := []struct {
+		name          string
+		requests      int
+		wantAllowed   bool
+		wantRemaining int64
+	}{
+		{name: "1回目", requests: 1, wantAllowed: true, wantRemaining: 2},
+		{name: "上限ちょうど", requests: 3, wantAllowed: true, wantRemaining: 0},
+		{name: "上限を超える", requests: 4, wantAllowed: false, wantRemaining: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLimiter()
+			var result *Result
+			for i := 0; i < tt.requests; i++ {
+				var err error
+				if result, err = l.Allow(context.Background(), "key", limit); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if result.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", result.Allowed, tt.wantAllowed)
+			}
+			if result.Remaining != tt.wantRemaining {
+				t.Errorf("Remaining = %d, want %d", result.Remaining, tt.wantRemaining)
+			}
+			if tt.wantAllowed && result.RetryAfter != 0 {
+				t.Errorf("RetryAfter = %v, want 0", result.RetryAfter)
+			}
+			// 1回分はPeriod/Countで回復する
+			if !tt.wantAllowed && (result.RetryAfter <= 0 || result.RetryAfter > limit.Period/time.Duration(limit.Count)) {
+				t.Errorf("RetryAfter = %v, want at most %v", result.RetryAfter, limit.Period/time.Duration(limit.Count))
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterKeys(t *testing.T) {
+	l := NewMemoryLimiter()
+	limit := Limit{Count: 1, Period: time.Hour}
+	for _, key := range []string{"user:1", "user:2", "ip:127.0.0.1"} {
+		result, err := l.Allow(context.Background(), key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Errorf("Allow(%q) was limited by another key", key)
+		}
+	}
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	l := NewMemoryLimiter()
+	limit := Limit{Count: 1, Period: 20 * time.Millisecond}
+	for i, wantAllowed := range []bool{true, false} {
+		result, err := l.Allow(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != wantAllowed {
+			t.Fatalf("request %d Allowed = %v, want %v", i+1, result.Allowed, wantAllowed)
+		}
+	}
+	time.Sleep(40 * time.Millisecond)
+	result, err := l.Allow(context.Background(), "key", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed {
+		t.Errorf("Allowed = false after the bucket refilled")
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		lastSweep time.Time
+		fullAt    time.Time
+		wantKept  bool
+	}{
+		{name: "満タンまで回復したバケットを捨てる", lastSweep: now.Add(-memorySweepInterval), fullAt: now, wantKept: false},
+		{name: "回復中のバケットは残す", lastSweep: now.Add(-memorySweepInterval), fullAt: now.Add(time.Second), wantKept: true},
+		{name: "前回から間隔が空いていない", lastSweep: now.Add(-time.Second), fullAt: now.Add(-time.Second), wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLimiter()
+			l.lastSweep = tt.lastSweep
+			l.buckets["key"] = &memoryBucket{fullAt: tt.fullAt}
+			l.sweep(now)
+			if _, ok := l.buckets["key"]; ok != tt.wantKept {
+				t.Errorf("bucket kept = %v, want %v", ok, tt.wantKept)
+			}
+		})
+	}
+}
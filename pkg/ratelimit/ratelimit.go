@@ -0,0 +1,100 @@
+// Package ratelimit リクエスト元ごとのリクエスト回数をトークンバケットで制限する。
+// バケットの保持はLimiterインタフェースの実装に任せ、起動時の設定でプロセス内とRedisを切り替える。
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type (
+	// Limit Periodの間にCount回までリクエストを許可する。バケットの容量はCountで、Period/Countごとに1回分回復する
+	Limit struct {
+		Count  int64
+		Period time.Duration
+	}
+
+	// Result リクエストを許可したかどうか。許可しない場合はRetryAfterの後に再試行できる
+	Result struct {
+		Allowed    bool
+		Remaining  int64
+		RetryAfter time.Duration
+	}
+
+	// Limiter キーごとのバケットから1回分を消費する
+	Limiter interface {
+		Allow(ctx context.Context, key string, limit Limit) (*Result, error)
+	}
+)
+
+func (l Limit) IsZero() bool {
+	return l.Count == 0
+}
+
+// 1ミリ秒あたりに回復する回数
+func (l Limit) ratePerMillisecond() float64 {
+	return float64(l.Count) / float64(l.Period.Milliseconds())
+}
+
+// NewLimiter 名前に対応するLimiterを作成する。空の場合はnilを返し、回数の制限を無効にする
+// 複数のサーバで動かす場合は、サーバ間で回数を共有するredisを指定する
+func NewLimiter(name string, rdb *redis.Client) (Limiter, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "memory":
+		return NewMemoryLimiter(), nil
+	case "redis":
+		return NewRedisLimiter(rdb), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter: %q", name)
+	}
+}
+
+// ParseLimits "/gacha/draw=60/1m,/user/create=10/1h"のようなルートごとの制限を解析する
+func ParseLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(route, "/") || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("rate limit must be route=count/period: %q", entry)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, entry)
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// ParseLimit "60/1m"のような1つの制限を解析する。空文字列の場合は制限しない
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	countStr, periodStr, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, errors.New("rate limit must be count/period")
+	}
+	count, err := strconv.ParseInt(countStr, 10, 64)
+	if err != nil || count < 1 {
+		return Limit{}, errors.New("rate limit count must be a positive integer")
+	}
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period < time.Millisecond {
+		return Limit{}, errors.New("rate limit period must be a duration of at least 1ms")
+	}
+	return Limit{Count: count, Period: period}, nil
+}
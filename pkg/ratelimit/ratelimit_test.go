@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Limit
+		wantErr bool
+	}{
+		{input: "", want: Limit{}},
+		{input: "  ", want: Limit{}},
+		{input: "60/1m", want: Limit{Count: 60, Period: time.Minute}},
+		{input: " 10/1h ", want: Limit{Count: 10, Period: time.Hour}},
+		{input: "5/1ms", want: Limit{Count: 5, Period: time.Millisecond}},
+		{input: "60", wantErr: true},
+		{input: "0/1m", wantErr: true},
+		{input: "-1/1m", wantErr: true},
+		{input: "x/1m", wantErr: true},
+		{input: "60/", wantErr: true},
+		{input: "60/1d", wantErr: true},
+		{input: "60/1us", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]Limit
+		wantErr bool
+	}{
+		{input: "", want: map[string]Limit{}},
+		{
+			input: "/gacha/draw=60/1m,/user/create=10/1h",
+			want: map[string]Limit{
+				"/gacha/draw":  {Count: 60, Period: time.Minute},
+				"/user/create": {Count: 10, Period: time.Hour},
+			},
+		},
+		{
+			input: " /gacha/draw=60/1m , ,",
+			want:  map[string]Limit{"/gacha/draw": {Count: 60, Period: time.Minute}},
+		},
+		{input: "/gacha/draw", wantErr: true},
+		{input: "gacha/draw=60/1m", wantErr: true},
+		{input: "/gacha/draw=", wantErr: true},
+		{input: "/gacha/draw=60/1m,/user/create=0/1h", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimits(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimits(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLimits(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// トークンバケットの回復と消費を1回の問い合わせで原子的に行う
+// バケットは満タンまで回復する時間が過ぎると消え、次のリクエストで満タンのバケットとして作り直す
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(bucket[1])
+local at = tonumber(bucket[2])
+if tokens == nil or at == nil then
+  tokens = capacity
+  at = now
+end
+if now > at then
+  tokens = math.min(capacity, tokens + (now - at) * rate)
+  at = now
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'at', at)
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1)
+return {allowed, math.floor(tokens), wait}
+`)
+
+// RedisLimiter Redisにバケットを保持するLimiter。同じRedisを使うサーバの間で回数を共有する
+// 現在時刻は各サーバの時計を使うため、サーバ間の時計のずれは回復の速さの誤差になる
+type RedisLimiter struct {
+	rdb *redis.Client
+}
+
+func NewRedisLimiter(rdb *redis.Client) *RedisLimiter {
+	return &RedisLimiter{rdb}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	args := []interface{}{limit.Count, strconv.FormatFloat(limit.ratePerMillisecond(), 'g', -1, 64), time.Now().UnixMilli()}
+	values, err := tokenBucketScript.Run(ctx, l.rdb, []string{"rate_limit:" + key}, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Allowed:    values[0] == 1,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
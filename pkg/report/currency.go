@@ -0,0 +1,37 @@
+// Package report 運営向けの集計レポートを作成する。
+// 管理APIとcmd/adminの両方から使う。
+package report
+
+import (
+	"errors"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrInvalidPeriod = errors.New("from must be before to")
+
+// Currency 有償コインの発行額・消費額と未使用残高を集計する
+// 期間[from, to)の発行額・消費額と、toの時点の未使用残高をcoin_ledgerから集計する。資金決済法の基準日の未使用残高の確認に使う
+// fromがnilの場合は記録の最初から、toがnilの場合は現在までを集計する
+func Currency(repos *repositories.Repositories, from, to *time.Time) (*entities.CurrencyReport, error) {
+	report := entities.CurrencyReport{From: from, To: time.Now().UTC().Truncate(time.Second)}
+	if to != nil {
+		report.To = *to
+	}
+	if from != nil && !from.Before(report.To) {
+		return nil, ErrInvalidPeriod
+	}
+
+	var err error
+	report.PaidIssued, report.PaidSpent, report.PaidOutstanding, err = repos.CoinLedgerRepository.GetPaidCurrencySummary(from, report.To)
+	if err != nil {
+		return nil, err
+	}
+	report.CurrentFreeBalance, report.CurrentPaidBalance, report.PaidHolders, err = repos.UserRepository.GetCoinBalanceTotals()
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
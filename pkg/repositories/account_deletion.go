@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrDeletionNotScheduled = errors.New("account deletion is not scheduled")
+
+type AccountDeletionRepository interface {
+	ScheduleDeletion(userID entities.UserID, scheduledAt time.Time) (time.Time, error)
+	CancelDeletion(userID entities.UserID) error
+	GetUsersDueForDeletion(at time.Time, limit int) ([]entities.UserID, error)
+	LockUserDueForDeletionTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) error
+	DeleteUserDataTransaction(tx *sql.Tx, userID entities.UserID) error
+	AnonymizeUserTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) error
+}
+
+func NewAccountDeletionRepository(db *sql.DB) AccountDeletionRepository {
+	return &accountDeletionRepository{db}
+}
+
+type accountDeletionRepository struct {
+	db *sql.DB
+}
+
+// ScheduleDeletion ユーザの削除を予約し、削除する日時を返す。予約済みの場合は予約を変えずに予約済みの日時を返す
+func (r *accountDeletionRepository) ScheduleDeletion(userID entities.UserID, scheduledAt time.Time) (time.Time, error) {
+	query := "UPDATE user SET deletion_scheduled_at = ? WHERE id = ? AND deletion_scheduled_at IS NULL AND deleted_at IS NULL"
+	if _, err := execQueryAndReturnAffectedRows(r.db, query, scheduledAt.UTC(), userID); err != nil {
+		return time.Time{}, err
+	}
+
+	var scheduled sql.NullString
+	if err := r.db.QueryRow("SELECT deletion_scheduled_at FROM user WHERE id = ? AND deleted_at IS NULL", userID).Scan(&scheduled); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrUserNotFound
+		}
+		log.Println(err)
+		return time.Time{}, err
+	}
+	at, err := parseNullDatetime(scheduled)
+	if err != nil {
+		log.Println(err)
+		return time.Time{}, err
+	}
+	if at == nil {
+		return time.Time{}, ErrDeletionNotScheduled
+	}
+	return *at, nil
+}
+
+// CancelDeletion ユーザの削除の予約を取り消す。予約していない場合はErrDeletionNotScheduledを返す
+func (r *accountDeletionRepository) CancelDeletion(userID entities.UserID) error {
+	query := "UPDATE user SET deletion_scheduled_at = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL"
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, userID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrDeletionNotScheduled
+	}
+	return nil
+}
+
+// 削除する日時を過ぎたユーザのIDを古い予約から取得する
+func (r *accountDeletionRepository) GetUsersDueForDeletion(at time.Time, limit int) ([]entities.UserID, error) {
+	query := "SELECT id FROM user WHERE deletion_scheduled_at <= ? AND deleted_at IS NULL ORDER BY deletion_scheduled_at, id LIMIT ?"
+	rows, err := r.db.Query(query, at.UTC(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []entities.UserID{}
+	for rows.Next() {
+		var userID entities.UserID
+		if err := rows.Scan(&userID); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// LockUserDueForDeletionTransaction ユーザの行をロックし、削除する日時を過ぎているか確認する
+// 取得してからロックするまでに予約が取り消された場合や、他のプロセスが削除した場合はErrDeletionNotScheduledを返す
+func (r *accountDeletionRepository) LockUserDueForDeletionTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) error {
+	query := "SELECT id FROM user WHERE id = ? AND deletion_scheduled_at <= ? AND deleted_at IS NULL FOR UPDATE"
+	var id entities.UserID
+	if err := tx.QueryRow(query, userID, at.UTC()).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeletionNotScheduled
+		}
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteUserDataTransaction ユーザの所持品・スコア・セッションなどを削除し、応答待ちのトレードと出品中の出品を終了する
+// 有償コインの会計と返金の照合に必要なコインの増減の記録・購入の記録、キャンペーンの回数の上限に使うクーポンの使用の記録は削除しない
+// トークンは削除する前にRevokeUserAuthTokensTransactionで失効させ、キャッシュから削除するハッシュを取得しておくこと
+func (r *accountDeletionRepository) DeleteUserDataTransaction(tx *sql.Tx, userID entities.UserID) error {
+	queries := []string{
+		"DELETE FROM user_items WHERE user_id = ?",
+		"DELETE FROM user_scores WHERE user_id = ?",
+		"DELETE FROM gacha_draws WHERE user_id = ?",
+		"DELETE FROM user_collection_milestones WHERE user_id = ?",
+		"DELETE FROM user_tickets WHERE user_id = ?",
+		"DELETE FROM presents WHERE user_id = ?",
+		"DELETE FROM user_shop_purchases WHERE user_id = ?",
+		"DELETE FROM transfer_codes WHERE user_id = ?",
+		"DELETE FROM auth_tokens WHERE user_id = ?",
+		"DELETE FROM sessions WHERE user_id = ?",
+		"DELETE FROM data_exports WHERE user_id = ?",
+		// 監査ログは残し、個人を特定できるIPアドレスのみ消す
+		"UPDATE transfer_audit_logs SET ip = '' WHERE user_id = ?",
+		// 相手のユーザの履歴に残すため、トレードと出品は削除せずに終了する。出品で預かっていたアイテムは返さない
+		"UPDATE trades SET status = 'canceled' WHERE proposer_id = ? AND status = 'pending'",
+		"UPDATE trades SET status = 'canceled' WHERE receiver_id = ? AND status = 'pending'",
+		"UPDATE market_listings SET status = 'canceled', closed_at = CURRENT_TIMESTAMP WHERE status = 'active' AND seller_id = ?",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, userID); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// AnonymizeUserTransaction ユーザの行から個人に関わる情報を消し、削除済みにする
+// コインの増減・購入の記録が参照するため行は残す。残高は会計の集計に使うため変更しない
+func (r *accountDeletionRepository) AnonymizeUserTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) error {
+	query := `
+		UPDATE user SET name = '', high_score = 0, age_bracket = NULL,
+			status = ?, status_reason = NULL, suspended_until = NULL, status_updated_at = ?,
+			deletion_scheduled_at = NULL, deleted_at = ?
+		WHERE id = ?`
+	if _, err := execQueryAndReturnAffectedRows(tx, query, entities.UserStatusDeleted, at.UTC(), at.UTC(), userID); err != nil {
+		return err
+	}
+	return nil
+}
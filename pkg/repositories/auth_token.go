@@ -0,0 +1,271 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"42tokyo-road-to-dojo-go/pkg/cache"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrAuthTokenNotFound = errors.New("auth token not found")
+
+type AuthTokenRepository interface {
+	AddAuthTokenTransaction(tx *sql.Tx, record *entities.AuthTokenRecord) error
+	GetAuthTokenByHash(hash string) (*entities.AuthTokenRecord, error)
+	GetAuthTokenByHashForUpdateTransaction(tx *sql.Tx, hash string) (*entities.AuthTokenRecord, error)
+	RevokeAuthTokenTransaction(tx *sql.Tx, ID entities.AuthTokenID, at time.Time) error
+	RevokeSessionAuthTokensTransaction(tx *sql.Tx, sessionID entities.SessionID, at time.Time) ([]string, error)
+	RevokeUserAuthTokensTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) ([]string, error)
+	DeleteAuthTokenCache(hashes ...string) error
+}
+
+const (
+	// Redisにトークンの記録を保持する期間。失効を反映し損ねた場合もこの期間で古い記録が消える
+	authTokenCacheTTL = time.Minute
+	// プロセス内にトークンの記録を保持する期間と件数。他のサーバで失効したトークンはこの期間だけ使え続ける
+	authTokenLocalCacheTTL  = 10 * time.Second
+	authTokenLocalCacheSize = 10000
+)
+
+func NewAuthTokenRepository(db *sql.DB, rdb *redis.Client) AuthTokenRepository {
+	return &authTokenRepository{db, rdb, cache.NewLRU[string, entities.AuthTokenRecord](authTokenLocalCacheSize, authTokenLocalCacheTTL)}
+}
+
+type authTokenRepository struct {
+	db    *sql.DB
+	rdb   *redis.Client
+	local *cache.LRU[string, entities.AuthTokenRecord]
+}
+
+const authTokenColumns = "id, user_id, session_id, token_hash, issued_at, expires_at, refresh_expires_at, revoked_at"
+
+func scanAuthToken(row rowScanner) (*entities.AuthTokenRecord, error) {
+	var record entities.AuthTokenRecord
+	var issuedAt, expiresAt, refreshExpiresAt []byte
+	var revokedAt sql.NullString
+	if err := row.Scan(&record.ID, &record.UserID, &record.SessionID, &record.TokenHash, &issuedAt, &expiresAt, &refreshExpiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if record.IssuedAt, err = parseDatetime(issuedAt); err != nil {
+		return nil, err
+	}
+	if record.ExpiresAt, err = parseDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if record.RefreshExpiresAt, err = parseDatetime(refreshExpiresAt); err != nil {
+		return nil, err
+	}
+	if record.RevokedAt, err = parseNullDatetime(revokedAt); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *authTokenRepository) AddAuthTokenTransaction(tx *sql.Tx, record *entities.AuthTokenRecord) error {
+	query := "INSERT INTO auth_tokens (user_id, session_id, token_hash, issued_at, expires_at, refresh_expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, record.UserID, record.SessionID, record.TokenHash, record.IssuedAt.UTC(), record.ExpiresAt.UTC(), record.RefreshExpiresAt.UTC())
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	record.ID = entities.AuthTokenID(id)
+	return nil
+}
+
+func authTokenCacheKey(hash string) string {
+	return "auth_token:" + hash
+}
+
+// GetAuthTokenByHash トークンのハッシュに対応する記録を取得する。失効・期限切れの判定は呼び出し側で行う
+// プロセス内のキャッシュ、Redis、DBの順に探し、見つかった記録をキャッシュに書き込む
+// Redisに接続できない場合もDBから取得する
+func (r *authTokenRepository) GetAuthTokenByHash(hash string) (*entities.AuthTokenRecord, error) {
+	if record, ok := r.local.Get(hash); ok {
+		return &record, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	recordJson, err := r.rdb.Get(ctx, authTokenCacheKey(hash)).Bytes()
+	switch {
+	case err == nil:
+		var record entities.AuthTokenRecord
+		if err := json.Unmarshal(recordJson, &record); err == nil {
+			r.local.Set(hash, record)
+			return &record, nil
+		}
+		log.Println(err)
+	case !errors.Is(err, redis.Nil):
+		log.Println(err)
+	}
+
+	query := "SELECT " + authTokenColumns + " FROM auth_tokens WHERE token_hash = ? LIMIT 1"
+	record, err := scanAuthToken(r.db.QueryRow(query, hash))
+	if err != nil {
+		// 存在しないトークンはキャッシュしない。でたらめなトークンでキャッシュが埋まらないようにするため
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAuthTokenNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+
+	r.local.Set(hash, *record)
+	if recordJson, err := json.Marshal(record); err != nil {
+		log.Println(err)
+	} else if err := r.rdb.Set(ctx, authTokenCacheKey(hash), recordJson, authTokenCacheTTL).Err(); err != nil {
+		log.Println(err)
+	}
+	if record.RevokedAt != nil {
+		return record, nil
+	}
+
+	// DBから読んでからキャッシュに書き込むまでの間に失効した場合、失効させた側がキャッシュを削除した後に
+	// 失効前の記録を書き込んでいる可能性がある。書き込んだ後に失効していないかDBで確認し直し、失効していれば消す
+	// 確認した時点で失効していなければ、失効させる側のキャッシュの削除はこの書き込みより後になる
+	revokedAt, err := r.getRevokedAt(record.ID)
+	if err != nil {
+		r.deleteCache(ctx, hash)
+		return nil, err
+	}
+	if revokedAt != nil {
+		r.deleteCache(ctx, hash)
+		record.RevokedAt = revokedAt
+	}
+	return record, nil
+}
+
+// トークンの失効日時を取得する。失効していない場合はnilを、削除された場合はErrAuthTokenNotFoundを返す
+func (r *authTokenRepository) getRevokedAt(ID entities.AuthTokenID) (*time.Time, error) {
+	var revokedAt sql.NullString
+	if err := r.db.QueryRow("SELECT revoked_at FROM auth_tokens WHERE id = ?", ID).Scan(&revokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAuthTokenNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	at, err := parseNullDatetime(revokedAt)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return at, nil
+}
+
+// プロセス内のキャッシュとRedisからトークンの記録を削除する。Redisのエラーはログに出して無視する
+func (r *authTokenRepository) deleteCache(ctx context.Context, hash string) {
+	r.local.Delete(hash)
+	if err := r.rdb.Del(ctx, authTokenCacheKey(hash)).Err(); err != nil {
+		log.Println(err)
+	}
+}
+
+// DeleteAuthTokenCache トークンの記録をキャッシュから削除する。トークンを失効させたトランザクションのコミット後に呼び出すこと
+// プロセス内のキャッシュは呼び出したサーバの分だけ削除する
+func (r *authTokenRepository) DeleteAuthTokenCache(hashes ...string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	r.local.Delete(hashes...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	keys := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		keys = append(keys, authTokenCacheKey(hash))
+	}
+	if err := r.rdb.Del(ctx, keys...).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// トランザクション内でトークンのハッシュに対応する記録の行をロックして取得する
+func (r *authTokenRepository) GetAuthTokenByHashForUpdateTransaction(tx *sql.Tx, hash string) (*entities.AuthTokenRecord, error) {
+	query := "SELECT " + authTokenColumns + " FROM auth_tokens WHERE token_hash = ? LIMIT 1 FOR UPDATE"
+	record, err := scanAuthToken(tx.QueryRow(query, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAuthTokenNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return record, nil
+}
+
+func (r *authTokenRepository) RevokeAuthTokenTransaction(tx *sql.Tx, ID entities.AuthTokenID, at time.Time) error {
+	query := "UPDATE auth_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, at.UTC(), ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 条件に一致する失効していないトークンの行をロックし、ハッシュを取得する
+func (r *authTokenRepository) getActiveAuthTokenHashesForUpdate(tx *sql.Tx, column string, value interface{}) ([]string, error) {
+	rows, err := tx.Query("SELECT token_hash FROM auth_tokens WHERE "+column+" = ? AND revoked_at IS NULL FOR UPDATE", value)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// RevokeSessionAuthTokensTransaction セッションの失効していないトークンをすべて失効させ、失効させたトークンのハッシュを返す
+func (r *authTokenRepository) RevokeSessionAuthTokensTransaction(tx *sql.Tx, sessionID entities.SessionID, at time.Time) ([]string, error) {
+	hashes, err := r.getActiveAuthTokenHashesForUpdate(tx, "session_id", sessionID)
+	if err != nil {
+		return nil, err
+	}
+	query := "UPDATE auth_tokens SET revoked_at = ? WHERE session_id = ? AND revoked_at IS NULL"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, at.UTC(), sessionID); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// RevokeUserAuthTokensTransaction ユーザの失効していないトークンをすべて失効させ、失効させたトークンのハッシュを返す
+func (r *authTokenRepository) RevokeUserAuthTokensTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) ([]string, error) {
+	hashes, err := r.getActiveAuthTokenHashesForUpdate(tx, "user_id", userID)
+	if err != nil {
+		return nil, err
+	}
+	query := "UPDATE auth_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, at.UTC(), userID); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+type CoinLedgerRepository interface {
+	AddCoinLedgerEntryTransaction(tx *sql.Tx, entry *entities.CoinLedgerEntry) error
+	GetSpentCoinsTransaction(tx *sql.Tx, userID entities.UserID, reason entities.CoinLedgerReason, from, to time.Time) (entities.Coin, error)
+	GetPaidCurrencySummary(from *time.Time, to time.Time) (issued, spent, outstanding entities.Coin, err error)
+	GetUserCoinLedgerEntriesAfter(userID entities.UserID, afterID entities.CoinLedgerID, limit int) ([]entities.CoinLedgerEntry, error)
+}
+
+func NewCoinLedgerRepository(db *sql.DB) CoinLedgerRepository {
+	return &coinLedgerRepository{db}
+}
+
+type coinLedgerRepository struct {
+	db *sql.DB
+}
+
+func (r *coinLedgerRepository) AddCoinLedgerEntryTransaction(tx *sql.Tx, entry *entities.CoinLedgerEntry) error {
+	if entry.Currency == "" {
+		entry.Currency = entities.CurrencyFree
+	}
+	query := "INSERT INTO coin_ledger (user_id, amount, currency, reason) VALUES (?, ?, ?, ?)"
+	_, err := tx.Exec(query, entry.UserID, entry.Amount, entry.Currency, entry.Reason)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// [from, to) の期間に発行・消費した有償コインの合計と、toの時点の有償コインの未使用残高を取得する
+// fromがnilの場合は記録の最初から集計する。返金による取り消しは消費ではなく発行から差し引く
+func (r *coinLedgerRepository) GetPaidCurrencySummary(from *time.Time, to time.Time) (issued, spent, outstanding entities.Coin, err error) {
+	var fromParam interface{}
+	if from != nil {
+		fromParam = from.UTC()
+	}
+	query := `
+		SELECT
+			COALESCE(SUM(CASE WHEN (amount > 0 OR reason = ?) AND (? IS NULL OR created_at >= ?) THEN amount END), 0),
+			COALESCE(-SUM(CASE WHEN amount < 0 AND reason <> ? AND (? IS NULL OR created_at >= ?) THEN amount END), 0),
+			COALESCE(SUM(amount), 0)
+		FROM coin_ledger
+		WHERE currency = ? AND created_at < ?`
+	refund := entities.CoinLedgerReasonRefund
+	err = r.db.QueryRow(query, refund, fromParam, fromParam, refund, fromParam, fromParam, entities.CurrencyPaid, to.UTC()).Scan(&issued, &spent, &outstanding)
+	if err != nil {
+		log.Println(err)
+		return 0, 0, 0, err
+	}
+	return issued, spent, outstanding, nil
+}
+
+// [from, to) の期間に指定した理由で消費したコインの合計を取得する
+func (r *coinLedgerRepository) GetSpentCoinsTransaction(tx *sql.Tx, userID entities.UserID, reason entities.CoinLedgerReason, from, to time.Time) (entities.Coin, error) {
+	query := `
+		SELECT COALESCE(-SUM(amount), 0)
+		FROM coin_ledger
+		WHERE user_id = ? AND reason = ? AND amount < 0 AND created_at >= ? AND created_at < ?`
+
+	var spent entities.Coin
+	if err := tx.QueryRow(query, userID, reason, from.UTC(), to.UTC()).Scan(&spent); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return spent, nil
+}
+
+// ユーザのコインの増減の記録を、afterIDより後のものから古い順にlimit件取得する
+func (r *coinLedgerRepository) GetUserCoinLedgerEntriesAfter(userID entities.UserID, afterID entities.CoinLedgerID, limit int) ([]entities.CoinLedgerEntry, error) {
+	query := "SELECT id, user_id, amount, currency, reason, created_at FROM coin_ledger WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []entities.CoinLedgerEntry{}
+	for rows.Next() {
+		var entry entities.CoinLedgerEntry
+		var createdAt []byte
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Amount, &entry.Currency, &entry.Reason, &createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if entry.CreatedAt, err = parseDatetime(createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type CollectionMilestoneRepository interface {
+	GetCollectionMilestones() (entities.CollectionMilestones, error)
+	CacheCollectionMilestones() error
+	GetCollectionMilestonesFromCache() (entities.CollectionMilestones, error)
+	GetAchievedMilestones(userID entities.UserID) (map[entities.CollectionMilestoneID]time.Time, error)
+	AddAchievedMilestoneTransaction(tx *sql.Tx, userID entities.UserID, milestoneID entities.CollectionMilestoneID) (bool, error)
+}
+
+func NewCollectionMilestoneRepository(db *sql.DB, rdb *redis.Client) CollectionMilestoneRepository {
+	return &collectionMilestoneRepository{db, rdb}
+}
+
+type collectionMilestoneRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func (r *collectionMilestoneRepository) GetCollectionMilestones() (entities.CollectionMilestones, error) {
+	query := "SELECT id, name, series_id, rarity, threshold_percent, reward_coin, reward_item_id, reward_item_count FROM collection_milestones ORDER BY id"
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var milestones entities.CollectionMilestones
+	for rows.Next() {
+		var milestone entities.CollectionMilestone
+		var seriesID, rarity, rewardItemID sql.NullInt64
+		if err := rows.Scan(&milestone.ID, &milestone.Name, &seriesID, &rarity, &milestone.ThresholdPercent, &milestone.RewardCoin, &rewardItemID, &milestone.RewardItemCount); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if seriesID.Valid {
+			id := entities.ItemSeriesID(seriesID.Int64)
+			milestone.SeriesID = &id
+		}
+		if rarity.Valid {
+			r := entities.Rarity(rarity.Int64)
+			milestone.Rarity = &r
+		}
+		if rewardItemID.Valid {
+			id := entities.ItemID(rewardItemID.Int64)
+			milestone.RewardItemID = &id
+		}
+		milestones = append(milestones, milestone)
+	}
+	return milestones, nil
+}
+
+func (r *collectionMilestoneRepository) CacheCollectionMilestones() error {
+	milestones, err := r.GetCollectionMilestones()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	milestonesJson, err := json.Marshal(milestones)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := r.rdb.Set(ctx, "collection_milestones", milestonesJson, 0).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *collectionMilestoneRepository) GetCollectionMilestonesFromCache() (entities.CollectionMilestones, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	milestonesJson, err := r.rdb.Get(ctx, "collection_milestones").Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var milestones entities.CollectionMilestones
+	if err := json.Unmarshal([]byte(milestonesJson), &milestones); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return milestones, nil
+}
+
+// 達成済みの報酬のIDと達成日時を取得する
+func (r *collectionMilestoneRepository) GetAchievedMilestones(userID entities.UserID) (map[entities.CollectionMilestoneID]time.Time, error) {
+	query := "SELECT milestone_id, achieved_at FROM user_collection_milestones WHERE user_id = ?"
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	achieved := make(map[entities.CollectionMilestoneID]time.Time)
+	for rows.Next() {
+		var milestoneID entities.CollectionMilestoneID
+		var achievedAt []byte
+		if err := rows.Scan(&milestoneID, &achievedAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if achieved[milestoneID], err = parseDatetime(achievedAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+	return achieved, nil
+}
+
+// 報酬の達成を記録する。既に達成済みの場合は何もせずfalseを返す。
+// 報酬を1度しか付与しないよう、trueが返った場合のみ報酬を付与すること。
+func (r *collectionMilestoneRepository) AddAchievedMilestoneTransaction(tx *sql.Tx, userID entities.UserID, milestoneID entities.CollectionMilestoneID) (bool, error) {
+	query := "INSERT IGNORE INTO user_collection_milestones (user_id, milestone_id) VALUES (?, ?)"
+	affected, err := execQueryAndReturnAffectedRows(tx, query, userID, milestoneID)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
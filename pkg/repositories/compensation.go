@@ -0,0 +1,311 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrCompensationNotFound = errors.New("compensation not found")
+
+// 対象のユーザIDを1回のクエリで扱う最大件数
+const compensationUserChunkSize = 1000
+
+type CompensationRepository interface {
+	CountTargetUsers(target *entities.CompensationTarget) (int64, error)
+	AddCompensation(compensation *entities.Compensation) error
+	GetCompensationByID(ID entities.CompensationID) (*entities.Compensation, error)
+	GetCompensations(limit int) ([]entities.Compensation, error)
+	AcquireCompensation(ID entities.CompensationID, now, leaseUntil time.Time) (bool, error)
+	GetTargetUserIDs(compensation *entities.Compensation, afterID entities.UserID, limit int) ([]entities.UserID, error)
+	AdvanceCompensationTransaction(tx *sql.Tx, ID entities.CompensationID, lastUserID entities.UserID, granted int64, leaseUntil time.Time) error
+	FinishCompensation(ID entities.CompensationID, status entities.CompensationStatus, lastError string) error
+}
+
+func NewCompensationRepository(db *sql.DB) CompensationRepository {
+	return &compensationRepository{db}
+}
+
+type compensationRepository struct {
+	db *sql.DB
+}
+
+const compensationColumns = `id, operator, reason, coin, item_id, item_count, ticket_type, ticket_count, message, present_expires_at,
+	created_before, created_after, has_user_ids, all_users, status, target_count, granted_count, last_user_id, last_error, created_at, completed_at`
+
+func scanCompensation(row rowScanner) (*entities.Compensation, error) {
+	var c entities.Compensation
+	var itemID sql.NullInt64
+	var ticketType, presentExpiresAt, createdBefore, createdAfter, completedAt sql.NullString
+	var hasUserIDs bool
+	var createdAt []byte
+	if err := row.Scan(&c.ID, &c.Operator, &c.Reason, &c.Reward.Coin, &itemID, &c.Reward.ItemCount, &ticketType, &c.Reward.TicketCount, &c.Message, &presentExpiresAt,
+		&createdBefore, &createdAfter, &hasUserIDs, &c.Target.AllUsers, &c.Status, &c.TargetCount, &c.GrantedCount, &c.LastUserID, &c.LastError, &createdAt, &completedAt); err != nil {
+		return nil, err
+	}
+	if itemID.Valid {
+		id := entities.ItemID(itemID.Int64)
+		c.Reward.ItemID = &id
+	}
+	if ticketType.Valid {
+		t := entities.TicketType(ticketType.String)
+		c.Reward.TicketType = &t
+	}
+	var err error
+	for _, v := range []struct {
+		dst **time.Time
+		src sql.NullString
+	}{
+		{&c.PresentExpiresAt, presentExpiresAt},
+		{&c.Target.CreatedBefore, createdBefore},
+		{&c.Target.CreatedAfter, createdAfter},
+		{&c.CompletedAt, completedAt},
+	} {
+		if *v.dst, err = parseNullDatetime(v.src); err != nil {
+			return nil, err
+		}
+	}
+	if c.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	// 対象のユーザIDの一覧は件数が多くなるため取得しない。指定されていたことだけを空でないスライスで示す
+	if hasUserIDs {
+		c.Target.UserIDs = []entities.UserID{}
+	}
+	return &c, nil
+}
+
+// 作成日時の条件をWHERE句にする
+func compensationTargetCondition(target *entities.CompensationTarget, userAlias string) (string, []interface{}) {
+	var cond string
+	var params []interface{}
+	if target.CreatedBefore != nil {
+		cond += " AND " + userAlias + ".created_at < ?"
+		params = append(params, target.CreatedBefore.UTC())
+	}
+	if target.CreatedAfter != nil {
+		cond += " AND " + userAlias + ".created_at >= ?"
+		params = append(params, target.CreatedAfter.UTC())
+	}
+	return cond, params
+}
+
+// 条件に合う対象者の人数を数える。存在しないユーザIDは数えない。
+func (r *compensationRepository) CountTargetUsers(target *entities.CompensationTarget) (int64, error) {
+	cond, condParams := compensationTargetCondition(target, "u")
+	if len(target.UserIDs) == 0 {
+		var count int64
+		if err := r.db.QueryRow("SELECT COUNT(*) FROM user u WHERE 1 = 1"+cond, condParams...).Scan(&count); err != nil {
+			log.Println(err)
+			return 0, err
+		}
+		return count, nil
+	}
+
+	var total int64
+	for _, chunk := range chunkUserIDs(uniqueUserIDs(target.UserIDs)) {
+		query := "SELECT COUNT(*) FROM user u WHERE u.id IN (?" + strings.Repeat(", ?", len(chunk)-1) + ")" + cond
+		params := make([]interface{}, 0, len(chunk)+len(condParams))
+		for _, id := range chunk {
+			params = append(params, id)
+		}
+		params = append(params, condParams...)
+		var count int64
+		if err := r.db.QueryRow(query, params...).Scan(&count); err != nil {
+			log.Println(err)
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func uniqueUserIDs(userIDs []entities.UserID) []entities.UserID {
+	seen := make(map[entities.UserID]bool, len(userIDs))
+	unique := make([]entities.UserID, 0, len(userIDs))
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func chunkUserIDs(userIDs []entities.UserID) [][]entities.UserID {
+	var chunks [][]entities.UserID
+	for len(userIDs) > compensationUserChunkSize {
+		chunks = append(chunks, userIDs[:compensationUserChunkSize])
+		userIDs = userIDs[compensationUserChunkSize:]
+	}
+	if len(userIDs) > 0 {
+		chunks = append(chunks, userIDs)
+	}
+	return chunks
+}
+
+// 補填と対象のユーザIDを追加し、採番されたIDと対象者の人数をcompensationに設定する。
+func (r *compensationRepository) AddCompensation(compensation *entities.Compensation) error {
+	targetCount, err := r.CountTargetUsers(&compensation.Target)
+	if err != nil {
+		return err
+	}
+	compensation.TargetCount = targetCount
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	query := `
+		INSERT INTO compensations (operator, reason, coin, item_id, item_count, ticket_type, ticket_count, message, present_expires_at,
+			created_before, created_after, has_user_ids, all_users, status, target_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, compensation.Operator, compensation.Reason, compensation.Reward.Coin, compensation.Reward.ItemID, compensation.Reward.ItemCount,
+		compensation.Reward.TicketType, compensation.Reward.TicketCount, compensation.Message, compensation.PresentExpiresAt,
+		compensation.Target.CreatedBefore, compensation.Target.CreatedAfter, len(compensation.Target.UserIDs) > 0, compensation.Target.AllUsers,
+		compensation.Status, compensation.TargetCount)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	compensation.ID = entities.CompensationID(id)
+
+	for _, chunk := range chunkUserIDs(uniqueUserIDs(compensation.Target.UserIDs)) {
+		query := "INSERT INTO compensation_users (compensation_id, user_id) VALUES " + strings.TrimSuffix(strings.Repeat("(?, ?),", len(chunk)), ",")
+		params := make([]interface{}, 0, len(chunk)*2)
+		for _, userID := range chunk {
+			params = append(params, compensation.ID, userID)
+		}
+		if _, err := tx.Exec(query, params...); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (r *compensationRepository) GetCompensationByID(ID entities.CompensationID) (*entities.Compensation, error) {
+	query := "SELECT " + compensationColumns + " FROM compensations WHERE id = ? LIMIT 1"
+	compensation, err := scanCompensation(r.db.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCompensationNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return compensation, nil
+}
+
+// 補填を新しい順にlimit件まで取得する。
+func (r *compensationRepository) GetCompensations(limit int) ([]entities.Compensation, error) {
+	query := "SELECT " + compensationColumns + " FROM compensations ORDER BY id DESC LIMIT ?"
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	compensations := []entities.Compensation{}
+	for rows.Next() {
+		compensation, err := scanCompensation(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		compensations = append(compensations, *compensation)
+	}
+	return compensations, rows.Err()
+}
+
+// AcquireCompensation 補填を実行中にして、leaseUntilまで他のプロセスが実行しないようにする。
+// 完了済みの場合や、他のプロセスが実行中でリースが切れていない場合はfalseを返す。
+func (r *compensationRepository) AcquireCompensation(ID entities.CompensationID, now, leaseUntil time.Time) (bool, error) {
+	query := `
+		UPDATE compensations SET status = ?, lease_expires_at = ?, last_error = ''
+		WHERE id = ? AND status != ? AND (status != ? OR lease_expires_at IS NULL OR lease_expires_at <= ?)`
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, entities.CompensationStatusRunning, leaseUntil.UTC(),
+		ID, entities.CompensationStatusCompleted, entities.CompensationStatusRunning, now.UTC())
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// 送付済みの最後のユーザIDより後の対象者を、ユーザID順にlimit件まで取得する。
+func (r *compensationRepository) GetTargetUserIDs(compensation *entities.Compensation, afterID entities.UserID, limit int) ([]entities.UserID, error) {
+	cond, condParams := compensationTargetCondition(&compensation.Target, "u")
+	var query string
+	var params []interface{}
+	if compensation.Target.UserIDs != nil {
+		query = "SELECT u.id FROM compensation_users cu JOIN user u ON u.id = cu.user_id WHERE cu.compensation_id = ? AND u.id > ?" + cond + " ORDER BY u.id LIMIT ?"
+		params = append(params, compensation.ID, afterID)
+	} else {
+		query = "SELECT u.id FROM user u WHERE u.id > ?" + cond + " ORDER BY u.id LIMIT ?"
+		params = append(params, afterID)
+	}
+	params = append(params, condParams...)
+	params = append(params, limit)
+
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []entities.UserID
+	for rows.Next() {
+		var userID entities.UserID
+		if err := rows.Scan(&userID); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// 送付したバッチの最後のユーザIDと送付数を記録し、リースを延長する。
+// プレゼントの追加と同じトランザクションで記録するため、再開しても同じユーザに2回送ることはない。
+func (r *compensationRepository) AdvanceCompensationTransaction(tx *sql.Tx, ID entities.CompensationID, lastUserID entities.UserID, granted int64, leaseUntil time.Time) error {
+	query := "UPDATE compensations SET last_user_id = ?, granted_count = granted_count + ?, lease_expires_at = ? WHERE id = ?"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, lastUserID, granted, leaseUntil.UTC(), ID); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// 補填の実行を終了し、リースを解放する。完了した場合は完了日時を記録する。
+func (r *compensationRepository) FinishCompensation(ID entities.CompensationID, status entities.CompensationStatus, lastError string) error {
+	query := "UPDATE compensations SET status = ?, last_error = ?, lease_expires_at = NULL, completed_at = IF(? = 'completed', CURRENT_TIMESTAMP, NULL) WHERE id = ?"
+	if _, err := execQueryAndReturnAffectedRows(r.db, query, status, lastError, status, ID); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
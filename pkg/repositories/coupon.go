@@ -0,0 +1,329 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrCouponNotFound         = errors.New("coupon not found")
+	ErrCouponCampaignNotFound = errors.New("coupon campaign not found")
+	ErrCouponCodeDuplicate    = errors.New("coupon code already exists")
+)
+
+// 発行するコードを1回のクエリで挿入する最大件数
+const couponCodeChunkSize = 1000
+
+type CouponRepository interface {
+	AddCouponCampaign(campaign *entities.CouponCampaign, codes []string) error
+	AddCouponCodes(campaignID entities.CouponCampaignID, codes []string) error
+	GetCouponCampaignByID(ID entities.CouponCampaignID) (*entities.CouponCampaign, error)
+	GetCouponCampaignByIDTransaction(tx *sql.Tx, ID entities.CouponCampaignID) (*entities.CouponCampaign, error)
+	GetCouponCodes(campaignID entities.CouponCampaignID) ([]entities.CouponCode, error)
+	GetCouponCodeForUpdateTransaction(tx *sql.Tx, code string) (*entities.CouponCode, error)
+	CountUserRedemptionsTransaction(tx *sql.Tx, campaignID entities.CouponCampaignID, userID entities.UserID) (int64, error)
+	RedeemCouponTransaction(tx *sql.Tx, code *entities.CouponCode, userID entities.UserID) error
+	GetUserRedemptionsAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedCouponRedemption, error)
+	GetRedeemFailureCount(userID entities.UserID) (int64, error)
+	AddRedeemFailure(userID entities.UserID, window time.Duration) (int64, error)
+}
+
+func NewCouponRepository(db *sql.DB, rdb *redis.Client) CouponRepository {
+	return &couponRepository{db, rdb}
+}
+
+type couponRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+const couponCampaignColumns = "id, name, kind, coin, item_id, item_count, ticket_type, ticket_count, per_user_limit, total_limit, starts_at, ends_at, created_at"
+
+func scanCouponCampaign(row rowScanner) (*entities.CouponCampaign, error) {
+	var campaign entities.CouponCampaign
+	var itemID sql.NullInt64
+	var ticketType, startsAt, endsAt sql.NullString
+	var createdAt []byte
+	if err := row.Scan(&campaign.ID, &campaign.Name, &campaign.Kind, &campaign.Reward.Coin, &itemID, &campaign.Reward.ItemCount, &ticketType, &campaign.Reward.TicketCount,
+		&campaign.PerUserLimit, &campaign.TotalLimit, &startsAt, &endsAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if itemID.Valid {
+		id := entities.ItemID(itemID.Int64)
+		campaign.Reward.ItemID = &id
+	}
+	if ticketType.Valid {
+		t := entities.TicketType(ticketType.String)
+		campaign.Reward.TicketType = &t
+	}
+	var err error
+	if campaign.StartsAt, err = parseNullDatetime(startsAt); err != nil {
+		return nil, err
+	}
+	if campaign.EndsAt, err = parseNullDatetime(endsAt); err != nil {
+		return nil, err
+	}
+	if campaign.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// コードをまとめて挿入する。既存のコードと重複した場合はErrCouponCodeDuplicateを返す
+func addCouponCodesTransaction(tx *sql.Tx, campaignID entities.CouponCampaignID, codes []string) error {
+	for start := 0; start < len(codes); start += couponCodeChunkSize {
+		chunk := codes[start:]
+		if len(chunk) > couponCodeChunkSize {
+			chunk = chunk[:couponCodeChunkSize]
+		}
+		query := "INSERT INTO coupon_codes (code, campaign_id) VALUES " + strings.TrimSuffix(strings.Repeat("(?, ?),", len(chunk)), ",")
+		params := make([]interface{}, 0, len(chunk)*2)
+		for _, code := range chunk {
+			params = append(params, code, campaignID)
+		}
+		if _, err := tx.Exec(query, params...); err != nil {
+			if isDuplicateEntry(err) {
+				return ErrCouponCodeDuplicate
+			}
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// AddCouponCampaign キャンペーンと発行したコードを1つのトランザクションで登録する。
+func (r *couponRepository) AddCouponCampaign(campaign *entities.CouponCampaign, codes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	query := `
+		INSERT INTO coupon_campaigns (name, kind, coin, item_id, item_count, ticket_type, ticket_count, per_user_limit, total_limit, starts_at, ends_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, campaign.Name, campaign.Kind, campaign.Reward.Coin, campaign.Reward.ItemID, campaign.Reward.ItemCount,
+		campaign.Reward.TicketType, campaign.Reward.TicketCount, campaign.PerUserLimit, campaign.TotalLimit, campaign.StartsAt, campaign.EndsAt)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := addCouponCodesTransaction(tx, entities.CouponCampaignID(id), codes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	campaign.ID = entities.CouponCampaignID(id)
+	return nil
+}
+
+// AddCouponCodes 既存のキャンペーンにコードを追加で発行する。
+func (r *couponRepository) AddCouponCodes(campaignID entities.CouponCampaignID, codes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	if err := addCouponCodesTransaction(tx, campaignID, codes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (r *couponRepository) GetCouponCampaignByID(ID entities.CouponCampaignID) (*entities.CouponCampaign, error) {
+	query := "SELECT " + couponCampaignColumns + " FROM coupon_campaigns WHERE id = ? LIMIT 1"
+	campaign, err := scanCouponCampaign(r.db.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCouponCampaignNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func (r *couponRepository) GetCouponCampaignByIDTransaction(tx *sql.Tx, ID entities.CouponCampaignID) (*entities.CouponCampaign, error) {
+	query := "SELECT " + couponCampaignColumns + " FROM coupon_campaigns WHERE id = ? LIMIT 1"
+	campaign, err := scanCouponCampaign(tx.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCouponCampaignNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// キャンペーンで発行したコードを発行順に取得する。CSVへの書き出しに使う
+func (r *couponRepository) GetCouponCodes(campaignID entities.CouponCampaignID) ([]entities.CouponCode, error) {
+	query := "SELECT code, campaign_id, redeemed_count FROM coupon_codes WHERE campaign_id = ? ORDER BY created_at, code"
+	rows, err := r.db.Query(query, campaignID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []entities.CouponCode{}
+	for rows.Next() {
+		var code entities.CouponCode
+		if err := rows.Scan(&code.Code, &code.CampaignID, &code.RedeemedCount); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return codes, nil
+}
+
+// トランザクション内でコードの行をロックして取得する。
+// 共有コードの使用回数の確認と更新の間に他のリクエストが割り込まないようにするために使う。
+func (r *couponRepository) GetCouponCodeForUpdateTransaction(tx *sql.Tx, code string) (*entities.CouponCode, error) {
+	query := "SELECT code, campaign_id, redeemed_count FROM coupon_codes WHERE code = ? LIMIT 1 FOR UPDATE"
+	var couponCode entities.CouponCode
+	if err := tx.QueryRow(query, code).Scan(&couponCode.Code, &couponCode.CampaignID, &couponCode.RedeemedCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCouponNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return &couponCode, nil
+}
+
+// ユーザがキャンペーン内でクーポンを使った回数を数える。
+func (r *couponRepository) CountUserRedemptionsTransaction(tx *sql.Tx, campaignID entities.CouponCampaignID, userID entities.UserID) (int64, error) {
+	query := "SELECT COUNT(*) FROM coupon_redemptions WHERE campaign_id = ? AND user_id = ?"
+	var count int64
+	if err := tx.QueryRow(query, campaignID, userID).Scan(&count); err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// RedeemCouponTransaction コードの使用回数を増やし、使用履歴を記録する。
+func (r *couponRepository) RedeemCouponTransaction(tx *sql.Tx, code *entities.CouponCode, userID entities.UserID) error {
+	if _, err := tx.Exec("UPDATE coupon_codes SET redeemed_count = redeemed_count + 1 WHERE code = ?", code.Code); err != nil {
+		log.Println(err)
+		return err
+	}
+	query := "INSERT INTO coupon_redemptions (campaign_id, code, user_id) VALUES (?, ?, ?)"
+	if _, err := tx.Exec(query, code.CampaignID, code.Code, userID); err != nil {
+		log.Println(err)
+		return err
+	}
+	code.RedeemedCount++
+	return nil
+}
+
+// ユーザのクーポンの使用履歴を、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *couponRepository) GetUserRedemptionsAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedCouponRedemption, error) {
+	query := "SELECT id, campaign_id, code, created_at FROM coupon_redemptions WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []entities.ExportedCouponRedemption{}
+	for rows.Next() {
+		var redemption entities.ExportedCouponRedemption
+		var createdAt []byte
+		if err := rows.Scan(&redemption.ID, &redemption.CampaignID, &redemption.Code, &createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if redemption.CreatedAt, err = parseDatetime(createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return redemptions, nil
+}
+
+func couponFailureKey(userID entities.UserID) string {
+	return fmt.Sprintf("coupon_failures:%d", userID)
+}
+
+// GetRedeemFailureCount 現在の期間内に存在しないコードを入力した回数を取得する。
+func (r *couponRepository) GetRedeemFailureCount(userID entities.UserID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	count, err := r.rdb.Get(ctx, couponFailureKey(userID)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// AddRedeemFailure 存在しないコードを入力した回数を増やし、増やした後の回数を返す。
+// 回数は最初の失敗からwindowの間だけ保持する。
+func (r *couponRepository) AddRedeemFailure(userID entities.UserID, window time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	key := couponFailureKey(userID)
+	count, err := r.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.rdb.Expire(ctx, key, window).Err(); err != nil {
+			log.Println(err)
+			return 0, err
+		}
+	}
+	return count, nil
+}
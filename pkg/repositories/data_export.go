@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrDataExportNotFound = errors.New("data export not found")
+	// ErrDataExportUnavailable 作成中・作成失敗・ダウンロード済み・期限切れのためダウンロードできない
+	ErrDataExportUnavailable = errors.New("data export is not available for download")
+)
+
+type DataExportRepository interface {
+	AddDataExportTransaction(tx *sql.Tx, userID entities.UserID, format entities.DataExportFormat, at time.Time) (*entities.DataExport, error)
+	GetDataExport(ID entities.DataExportID) (*entities.DataExport, error)
+	GetUserDataExport(userID entities.UserID, ID entities.DataExportID) (*entities.DataExport, error)
+	GetPendingDataExportTransaction(tx *sql.Tx, userID entities.UserID, createdAfter time.Time) (*entities.DataExport, error)
+	CompleteDataExport(ID entities.DataExportID, filePath string, completedAt, expiresAt time.Time) error
+	FailDataExport(ID entities.DataExportID, reason string, at time.Time) error
+	ClaimDataExportDownload(ID entities.DataExportID, at time.Time) error
+	ExpireDataExports(at time.Time) ([]string, error)
+	GetUserDataExportFilePathsTransaction(tx *sql.Tx, userID entities.UserID) ([]string, error)
+}
+
+func NewDataExportRepository(db *sql.DB) DataExportRepository {
+	return &dataExportRepository{db}
+}
+
+type dataExportRepository struct {
+	db *sql.DB
+}
+
+const dataExportColumns = "id, user_id, format, status, file_path, error, created_at, completed_at, expires_at, downloaded_at"
+
+func scanDataExport(row rowScanner) (*entities.DataExport, error) {
+	var export entities.DataExport
+	var filePath, exportError, completedAt, expiresAt, downloadedAt sql.NullString
+	var createdAt []byte
+	if err := row.Scan(&export.ID, &export.UserID, &export.Format, &export.Status, &filePath, &exportError, &createdAt, &completedAt, &expiresAt, &downloadedAt); err != nil {
+		return nil, err
+	}
+	export.FilePath = filePath.String
+	export.Error = exportError.String
+	var err error
+	if export.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	if export.CompletedAt, err = parseNullDatetime(completedAt); err != nil {
+		return nil, err
+	}
+	if export.ExpiresAt, err = parseNullDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if export.DownloadedAt, err = parseNullDatetime(downloadedAt); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// トランザクション内でエクスポートの依頼を作成中の状態で追加する
+func (r *dataExportRepository) AddDataExportTransaction(tx *sql.Tx, userID entities.UserID, format entities.DataExportFormat, at time.Time) (*entities.DataExport, error) {
+	at = at.UTC().Truncate(time.Second)
+	result, err := tx.Exec("INSERT INTO data_exports (user_id, format, status, created_at) VALUES (?, ?, ?, ?)",
+		userID, format, entities.DataExportStatusPending, at)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &entities.DataExport{
+		ID:        entities.DataExportID(id),
+		UserID:    userID,
+		Format:    format,
+		Status:    entities.DataExportStatusPending,
+		CreatedAt: at,
+	}, nil
+}
+
+func (r *dataExportRepository) GetDataExport(ID entities.DataExportID) (*entities.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE id = ? LIMIT 1"
+	export, err := scanDataExport(r.db.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDataExportNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return export, nil
+}
+
+// ユーザのエクスポートを取得する。他のユーザのエクスポートの場合もErrDataExportNotFoundを返す
+func (r *dataExportRepository) GetUserDataExport(userID entities.UserID, ID entities.DataExportID) (*entities.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE id = ? AND user_id = ? LIMIT 1"
+	export, err := scanDataExport(r.db.QueryRow(query, ID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrDataExportNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return export, nil
+}
+
+// トランザクション内でcreatedAfterより後に依頼した作成中のエクスポートを取得する。ない場合はnilを返す
+func (r *dataExportRepository) GetPendingDataExportTransaction(tx *sql.Tx, userID entities.UserID, createdAfter time.Time) (*entities.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE user_id = ? AND status = ? AND created_at > ? ORDER BY created_at DESC LIMIT 1"
+	export, err := scanDataExport(tx.QueryRow(query, userID, entities.DataExportStatusPending, createdAfter.UTC()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return export, nil
+}
+
+// 作成したファイルのパスを保存し、期限までダウンロードできる状態にする
+func (r *dataExportRepository) CompleteDataExport(ID entities.DataExportID, filePath string, completedAt, expiresAt time.Time) error {
+	query := "UPDATE data_exports SET status = ?, file_path = ?, completed_at = ?, expires_at = ? WHERE id = ? AND status = ?"
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, entities.DataExportStatusReady, filePath, completedAt.UTC(), expiresAt.UTC(),
+		ID, entities.DataExportStatusPending)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrDataExportNotFound
+	}
+	return nil
+}
+
+// 作成に失敗したことを記録する
+func (r *dataExportRepository) FailDataExport(ID entities.DataExportID, reason string, at time.Time) error {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	query := "UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ? AND status = ?"
+	if _, err := execQueryAndReturnAffectedRows(r.db, query, entities.DataExportStatusFailed, reason, at.UTC(), ID, entities.DataExportStatusPending); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClaimDataExportDownload ダウンロードできるエクスポートをダウンロード済みにする
+// 同時にダウンロードしても1回しか成功しないように、状態を確認して更新する
+// ダウンロードできない場合はErrDataExportUnavailableを返す
+func (r *dataExportRepository) ClaimDataExportDownload(ID entities.DataExportID, at time.Time) error {
+	query := "UPDATE data_exports SET status = ?, file_path = NULL, downloaded_at = ? WHERE id = ? AND status = ? AND expires_at > ?"
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, entities.DataExportStatusDownloaded, at.UTC(), ID, entities.DataExportStatusReady, at.UTC())
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrDataExportUnavailable
+	}
+	return nil
+}
+
+// ExpireDataExports ダウンロードされないまま期限を過ぎたエクスポートを期限切れにし、消すファイルのパスを返す
+func (r *dataExportRepository) ExpireDataExports(at time.Time) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	query := "SELECT file_path FROM data_exports WHERE status = ? AND expires_at <= ? FOR UPDATE"
+	filePaths, err := queryFilePaths(tx, query, entities.DataExportStatusReady, at.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if len(filePaths) == 0 {
+		return filePaths, nil
+	}
+	query = "UPDATE data_exports SET status = ?, file_path = NULL WHERE status = ? AND expires_at <= ?"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, entities.DataExportStatusExpired, entities.DataExportStatusReady, at.UTC()); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return filePaths, nil
+}
+
+// トランザクション内でユーザのエクスポートのファイルのパスを取得する。ユーザの削除で行を消す前に、消すファイルを確認するために使う
+func (r *dataExportRepository) GetUserDataExportFilePathsTransaction(tx *sql.Tx, userID entities.UserID) ([]string, error) {
+	return queryFilePaths(tx, "SELECT file_path FROM data_exports WHERE user_id = ? AND file_path IS NOT NULL FOR UPDATE", userID)
+}
+
+func queryFilePaths(tx *sql.Tx, query string, params ...interface{}) ([]string, error) {
+	rows, err := tx.Query(query, params...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	filePaths := []string{}
+	for rows.Next() {
+		var filePath sql.NullString
+		if err := rows.Scan(&filePath); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if filePath.Valid {
+			filePaths = append(filePaths, filePath.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return filePaths, nil
+}
@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrGachaNonceAlreadyUsed = errors.New("nonce is already used in this seed period")
+
+type GachaSeedRepository interface {
+	GetOrCreateSeed(candidate *entities.GachaSeed) (*entities.GachaSeed, error)
+	GetSeedByID(ID entities.GachaSeedID) (*entities.GachaSeed, error)
+}
+
+func NewGachaSeedRepository(db *sql.DB) GachaSeedRepository {
+	return &gachaSeedRepository{db}
+}
+
+type gachaSeedRepository struct {
+	db *sql.DB
+}
+
+// 期間開始日時が同じシードが既にあればそれを返し、なければcandidateを登録して返す。
+// 複数のリクエストが同時に登録しようとしても、starts_atのUNIQUE制約により1つに定まる。
+func (r *gachaSeedRepository) GetOrCreateSeed(candidate *entities.GachaSeed) (*entities.GachaSeed, error) {
+	query := "INSERT IGNORE INTO gacha_seeds (seed, seed_hash, starts_at, ends_at) VALUES (?, ?, ?, ?)"
+	if _, err := r.db.Exec(query, candidate.Seed, candidate.SeedHash, candidate.StartsAt, candidate.EndsAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	query = "SELECT id, seed, seed_hash, starts_at, ends_at FROM gacha_seeds WHERE starts_at = ? LIMIT 1"
+	return r.scanSeed(r.db.QueryRow(query, candidate.StartsAt))
+}
+
+func (r *gachaSeedRepository) GetSeedByID(ID entities.GachaSeedID) (*entities.GachaSeed, error) {
+	query := "SELECT id, seed, seed_hash, starts_at, ends_at FROM gacha_seeds WHERE id = ? LIMIT 1"
+	return r.scanSeed(r.db.QueryRow(query, ID))
+}
+
+func (r *gachaSeedRepository) scanSeed(row *sql.Row) (*entities.GachaSeed, error) {
+	var seed entities.GachaSeed
+	var startsAt, endsAt []byte
+	if err := row.Scan(&seed.ID, &seed.Seed, &seed.SeedHash, &startsAt, &endsAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	var err error
+	if seed.StartsAt, err = parseDatetime(startsAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if seed.EndsAt, err = parseDatetime(endsAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &seed, nil
+}
+
+type GachaDrawRepository interface {
+	AddGachaDrawTransaction(tx *sql.Tx, draw *entities.GachaDraw) error
+	GetGachaDrawByID(ID entities.GachaDrawID) (*entities.GachaDraw, error)
+	GetUserGachaDrawsAfter(userID entities.UserID, afterID entities.GachaDrawID, limit int) ([]entities.GachaDraw, error)
+}
+
+func NewGachaDrawRepository(db *sql.DB) GachaDrawRepository {
+	return &gachaDrawRepository{db}
+}
+
+type gachaDrawRepository struct {
+	db *sql.DB
+}
+
+// ガチャの実行記録を追加し、採番されたIDをdrawに設定する。排出対象は公平モードの検証のために保存する。
+// 公平モードで同じシード・ノンスを使い回した場合はErrGachaNonceAlreadyUsedを返す。
+func (r *gachaDrawRepository) AddGachaDrawTransaction(tx *sql.Tx, draw *entities.GachaDraw) error {
+	itemIDsJson, err := json.Marshal(draw.ItemIDs)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	var nonce, poolJson, poolAt interface{}
+	if draw.SeedID != nil {
+		nonce = draw.Nonce
+	}
+	if len(draw.Pool) > 0 {
+		b, err := json.Marshal(draw.Pool)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		poolJson = b
+	}
+	if draw.PoolAt != nil {
+		poolAt = draw.PoolAt.UTC()
+	}
+
+	query := "INSERT INTO gacha_draws (user_id, seed_id, nonce, game_setting_id, times, item_ids, pool, pool_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, draw.UserID, draw.SeedID, nonce, draw.GameSettingID, draw.Times, itemIDsJson, poolJson, poolAt)
+	if err != nil {
+		log.Println(err)
+		if isDuplicateEntry(err) {
+			return ErrGachaNonceAlreadyUsed
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	draw.ID = entities.GachaDrawID(id)
+
+	return nil
+}
+
+const gachaDrawColumns = "id, user_id, seed_id, nonce, game_setting_id, times, item_ids, pool, pool_at, created_at"
+
+func scanGachaDraw(row rowScanner) (*entities.GachaDraw, error) {
+	var draw entities.GachaDraw
+	var seedID sql.NullInt64
+	var nonce, poolJson, poolAt sql.NullString
+	var itemIDsJson, createdAt []byte
+	if err := row.Scan(&draw.ID, &draw.UserID, &seedID, &nonce, &draw.GameSettingID, &draw.Times, &itemIDsJson, &poolJson, &poolAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if poolJson.Valid {
+		if err := json.Unmarshal([]byte(poolJson.String), &draw.Pool); err != nil {
+			return nil, err
+		}
+	}
+	if seedID.Valid {
+		id := entities.GachaSeedID(seedID.Int64)
+		draw.SeedID = &id
+		draw.Nonce = nonce.String
+	}
+	if err := json.Unmarshal(itemIDsJson, &draw.ItemIDs); err != nil {
+		return nil, err
+	}
+	var err error
+	if draw.PoolAt, err = parseNullDatetime(poolAt); err != nil {
+		return nil, err
+	}
+	if draw.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	return &draw, nil
+}
+
+func (r *gachaDrawRepository) GetGachaDrawByID(ID entities.GachaDrawID) (*entities.GachaDraw, error) {
+	query := "SELECT " + gachaDrawColumns + " FROM gacha_draws WHERE id = ? LIMIT 1"
+	draw, err := scanGachaDraw(r.db.QueryRow(query, ID))
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return draw, nil
+}
+
+// ユーザのガチャの実行記録を、afterIDより後のものから古い順にlimit件取得する
+func (r *gachaDrawRepository) GetUserGachaDrawsAfter(userID entities.UserID, afterID entities.GachaDrawID, limit int) ([]entities.GachaDraw, error) {
+	query := "SELECT " + gachaDrawColumns + " FROM gacha_draws WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	draws := []entities.GachaDraw{}
+	for rows.Next() {
+		draw, err := scanGachaDraw(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		draws = append(draws, *draw)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return draws, nil
+}
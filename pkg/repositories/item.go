@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -13,11 +14,21 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+var (
+	ErrItemNotFound = errors.New("item not found")
+	// ErrItemOwned 所持しているユーザがいるアイテムは削除できない
+	ErrItemOwned = errors.New("item is owned by users")
+	// ErrItemInUse 達成報酬などから参照されているアイテムは削除できない
+	ErrItemInUse = errors.New("item is referenced by other master data")
+)
+
 type ItemRepository interface {
 	GetItems() (*entities.Items, error)
+	GetItemByID(ID entities.ItemID) (*entities.Item, error)
 	CacheItems() error
 	GetItemsFromCache() (*entities.Items, error)
 	AddItem(item *entities.Item) error
+	UpdateItemStatusByID(ID entities.ItemID, status entities.ItemStatus) (*entities.Item, error)
 	DeleteItemByID(ID entities.ItemID) error
 }
 
@@ -30,8 +41,37 @@ type itemRepository struct {
 	rdb *redis.Client
 }
 
+const itemColumns = `id, name, rarity, series_id, category_id, description, icon_asset_key, illustration_asset_key,
+	flavor_text, released_at, retired_at, hidden_until_released, status`
+
+func scanItem(row rowScanner) (*entities.Item, error) {
+	item := new(entities.Item)
+	var seriesID, categoryID sql.NullInt64
+	var releasedAt, retiredAt sql.NullString
+	if err := row.Scan(&item.ID, &item.Name, &item.Rarity, &seriesID, &categoryID, &item.Description, &item.IconAssetKey, &item.IllustrationAssetKey,
+		&item.FlavorText, &releasedAt, &retiredAt, &item.HiddenUntilReleased, &item.Status); err != nil {
+		return nil, err
+	}
+	var err error
+	if item.ReleasedAt, err = parseNullDatetime(releasedAt); err != nil {
+		return nil, err
+	}
+	if item.RetiredAt, err = parseNullDatetime(retiredAt); err != nil {
+		return nil, err
+	}
+	if seriesID.Valid {
+		id := entities.ItemSeriesID(seriesID.Int64)
+		item.SeriesID = &id
+	}
+	if categoryID.Valid {
+		id := entities.ItemCategoryID(categoryID.Int64)
+		item.CategoryID = &id
+	}
+	return item, nil
+}
+
 func (r *itemRepository) GetItems() (*entities.Items, error) {
-	query := "SELECT id, name, rarity FROM item"
+	query := "SELECT " + itemColumns + " FROM item"
 	rows, err := r.db.Query(query)
 	if err != nil {
 		log.Println(err)
@@ -48,8 +88,8 @@ func (r *itemRepository) GetItems() (*entities.Items, error) {
 
 	var items entities.Items
 	for rows.Next() {
-		item := new(entities.Item)
-		if err := rows.Scan(&item.ID, &item.Name, &item.Rarity); err != nil {
+		item, err := scanItem(rows)
+		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
@@ -59,6 +99,33 @@ func (r *itemRepository) GetItems() (*entities.Items, error) {
 	return &items, nil
 }
 
+func (r *itemRepository) GetItemByID(ID entities.ItemID) (*entities.Item, error) {
+	query := "SELECT " + itemColumns + " FROM item WHERE id = ?"
+	item, err := scanItem(r.db.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrItemNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return item, nil
+}
+
+// アイテム1件をキャッシュに書き込む
+func (r *itemRepository) cacheItem(ctx context.Context, item *entities.Item) error {
+	itemJson, err := json.Marshal(item)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := r.rdb.Set(ctx, fmt.Sprintf("item:%d", item.ID), itemJson, 0).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
 func (r *itemRepository) CacheItems() error {
 	items, err := r.GetItems()
 	if err != nil {
@@ -68,14 +135,8 @@ func (r *itemRepository) CacheItems() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
 
-	for _, item := range *items {
-		itemJson, err := json.Marshal(item)
-		if err != nil {
-			log.Println(err)
-			return err
-		}
-		if err := r.rdb.Set(ctx, fmt.Sprintf("item:%d", item.ID), itemJson, 0).Err(); err != nil {
-			log.Println(err)
+	for i := range *items {
+		if err := r.cacheItem(ctx, &(*items)[i]); err != nil {
 			return err
 		}
 	}
@@ -93,15 +154,26 @@ func (r *itemRepository) GetItemsFromCache() (*entities.Items, error) {
 		return nil, err
 	}
 
-	var items entities.Items
-	for _, key := range keys {
-		itemJson, err := r.rdb.Get(ctx, key).Result()
-		if err != nil {
-			log.Println(err)
-			return nil, err
+	if len(keys) == 0 {
+		return &entities.Items{}, nil
+	}
+
+	// アイテム数が増えてもRedisへの問い合わせが1回で済むようにMGETでまとめて取得する
+	itemJsons, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	items := make(entities.Items, 0, len(itemJsons))
+	for _, itemJson := range itemJsons {
+		// KEYSとMGETの間に削除されたアイテムはnilになる
+		itemJsonStr, ok := itemJson.(string)
+		if !ok {
+			continue
 		}
 		var item entities.Item
-		if err := json.Unmarshal([]byte(itemJson), &item); err != nil {
+		if err := json.Unmarshal([]byte(itemJsonStr), &item); err != nil {
 			log.Println(err)
 			return nil, err
 		}
@@ -112,8 +184,15 @@ func (r *itemRepository) GetItemsFromCache() (*entities.Items, error) {
 }
 
 func (r *itemRepository) AddItem(item *entities.Item) error {
-	query := "INSERT INTO item (name, rarity) VALUES (?, ?)"
-	result, err := r.db.Exec(query, item.Name, item.Rarity)
+	if item.Status == "" {
+		item.Status = entities.ItemStatusActive
+	}
+	query := `
+		INSERT INTO item (name, rarity, series_id, category_id, description, icon_asset_key, illustration_asset_key,
+			flavor_text, released_at, retired_at, hidden_until_released, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.Exec(query, item.Name, item.Rarity, item.SeriesID, item.CategoryID, item.Description, item.IconAssetKey, item.IllustrationAssetKey,
+		item.FlavorText, item.ReleasedAt, item.RetiredAt, item.HiddenUntilReleased, item.Status)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -130,23 +209,74 @@ func (r *itemRepository) AddItem(item *entities.Item) error {
 	// redisにも追加
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-	itemJson, err := json.Marshal(item)
+	return r.cacheItem(ctx, item)
+}
+
+// UpdateItemStatusByID アイテムの状態を変更し、キャッシュにも反映する
+// 変更前の状態は残さない。過去の公平ガチャは実行記録に保存した排出対象で検証するため影響しない
+func (r *itemRepository) UpdateItemStatusByID(ID entities.ItemID, status entities.ItemStatus) (*entities.Item, error) {
+	query := "UPDATE item SET status = ? WHERE id = ?"
+	if _, err := r.db.Exec(query, status, ID); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// 変更前と同じ状態の場合は更新行数が0になるため、存在確認を兼ねて取得し直す
+	item, err := r.GetItemByID(ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	if err := r.cacheItem(ctx, item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// DeleteItemByID アイテムを削除する。所持しているユーザがいる場合はErrItemOwnedを返し、削除しない。
+// ガチャの排出から外す場合は削除せずにUpdateItemStatusByIDで状態を変更すること。
+func (r *itemRepository) DeleteItemByID(ID entities.ItemID) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	if err := r.rdb.Set(ctx, fmt.Sprint(int64(item.ID)), itemJson, 0).Err(); err != nil {
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	// アイテムの行をロックして、確認から削除までの間に所持アイテムが追加されないようにする
+	var id entities.ItemID
+	if err := tx.QueryRow("SELECT id FROM item WHERE id = ? FOR UPDATE", ID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrItemNotFound
+		}
 		log.Println(err)
 		return err
 	}
 
-	return nil
-}
+	var owned bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM user_items WHERE item_id = ?)", ID).Scan(&owned); err != nil {
+		log.Println(err)
+		return err
+	}
+	if owned {
+		return ErrItemOwned
+	}
 
-func (r *itemRepository) DeleteItemByID(ID entities.ItemID) error {
-	query := "DELETE FROM item WHERE id = ?"
-	_, err := r.db.Exec(query, ID)
-	if err != nil {
+	if _, err := tx.Exec("DELETE FROM item WHERE id = ?", ID); err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrItemInUse
+		}
+		log.Println(err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
 		log.Println(err)
 		return err
 	}
@@ -154,7 +284,7 @@ func (r *itemRepository) DeleteItemByID(ID entities.ItemID) error {
 	// redisからも削除
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
-	if err := r.rdb.Del(ctx, fmt.Sprintf("%d", int64(ID))).Err(); err != nil {
+	if err := r.rdb.Del(ctx, fmt.Sprintf("item:%d", ID)).Err(); err != nil {
 		log.Println(err)
 		return err
 	}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type ItemEnhanceSettingsRepository interface {
+	GetItemEnhanceSettings() (entities.ItemEnhanceSettings, error)
+	CacheItemEnhanceSettings() error
+	GetItemEnhanceSettingsFromCache() (entities.ItemEnhanceSettings, error)
+}
+
+func NewItemEnhanceSettingsRepository(db *sql.DB, rdb *redis.Client) ItemEnhanceSettingsRepository {
+	return &itemEnhanceSettingsRepository{db, rdb}
+}
+
+type itemEnhanceSettingsRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func (r *itemEnhanceSettingsRepository) GetItemEnhanceSettings() (entities.ItemEnhanceSettings, error) {
+	query := "SELECT rarity, max_level, copies_per_level, coin_per_level FROM item_enhance_settings"
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings entities.ItemEnhanceSettings
+	for rows.Next() {
+		var setting entities.ItemEnhanceSetting
+		if err := rows.Scan(&setting.Rarity, &setting.MaxLevel, &setting.CopiesPerLevel, &setting.CoinPerLevel); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+func (r *itemEnhanceSettingsRepository) CacheItemEnhanceSettings() error {
+	settings, err := r.GetItemEnhanceSettings()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	settingsJson, err := json.Marshal(settings)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := r.rdb.Set(ctx, "item_enhance_settings", settingsJson, 0).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *itemEnhanceSettingsRepository) GetItemEnhanceSettingsFromCache() (entities.ItemEnhanceSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	settingsJson, err := r.rdb.Get(ctx, "item_enhance_settings").Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var settings entities.ItemEnhanceSettings
+	if err := json.Unmarshal([]byte(settingsJson), &settings); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return settings, nil
+}
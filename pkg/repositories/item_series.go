@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type ItemSeriesRepository interface {
+	GetItemSeriesList() (*entities.ItemSeriesList, error)
+	CacheItemSeriesList() error
+	GetItemSeriesListFromCache() (*entities.ItemSeriesList, error)
+}
+
+func NewItemSeriesRepository(db *sql.DB, rdb *redis.Client) ItemSeriesRepository {
+	return &itemSeriesRepository{db, rdb}
+}
+
+type itemSeriesRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+// シリーズとカテゴリのマスターデータを取得する
+func (r *itemSeriesRepository) GetItemSeriesList() (*entities.ItemSeriesList, error) {
+	list := entities.ItemSeriesList{
+		Series:     []entities.ItemSeries{},
+		Categories: []entities.ItemCategory{},
+	}
+
+	rows, err := r.db.Query("SELECT id, name FROM item_series ORDER BY id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var series entities.ItemSeries
+		if err := rows.Scan(&series.ID, &series.Name); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		list.Series = append(list.Series, series)
+	}
+
+	categoryRows, err := r.db.Query("SELECT id, name FROM item_categories ORDER BY id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer categoryRows.Close()
+	for categoryRows.Next() {
+		var category entities.ItemCategory
+		if err := categoryRows.Scan(&category.ID, &category.Name); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		list.Categories = append(list.Categories, category)
+	}
+
+	return &list, nil
+}
+
+func (r *itemSeriesRepository) CacheItemSeriesList() error {
+	list, err := r.GetItemSeriesList()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	listJson, err := json.Marshal(list)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := r.rdb.Set(ctx, "item_series", listJson, 0).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *itemSeriesRepository) GetItemSeriesListFromCache() (*entities.ItemSeriesList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	listJson, err := r.rdb.Get(ctx, "item_series").Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var list entities.ItemSeriesList
+	if err := json.Unmarshal([]byte(listJson), &list); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return &list, nil
+}
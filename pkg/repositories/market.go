@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrMarketListingNotFound = errors.New("listing not found")
+
+type MarketListingRepository interface {
+	AddMarketListingTransaction(tx *sql.Tx, listing *entities.MarketListing) error
+	GetMarketListingByIDForUpdateTransaction(tx *sql.Tx, ID entities.MarketListingID) (*entities.MarketListing, error)
+	GetMarketListings(filter *entities.MarketListingFilter, at time.Time) ([]entities.MarketListing, error)
+	GetExpiredMarketListingsForUpdateTransaction(tx *sql.Tx, at time.Time, limit int) ([]entities.MarketListing, error)
+	CloseMarketListingTransaction(tx *sql.Tx, ID entities.MarketListingID, status entities.MarketListingStatus, buyerID *entities.UserID) error
+	GetUserMarketListingsAfter(userID entities.UserID, afterID entities.MarketListingID, limit int) ([]entities.MarketListing, error)
+}
+
+func NewMarketListingRepository(db *sql.DB) MarketListingRepository {
+	return &marketListingRepository{db}
+}
+
+type marketListingRepository struct {
+	db *sql.DB
+}
+
+const marketListingColumns = "l.id, l.seller_id, l.item_id, l.count, l.price, l.fee, l.status, l.buyer_id, l.expires_at, l.created_at"
+
+func scanMarketListing(row rowScanner) (*entities.MarketListing, error) {
+	var listing entities.MarketListing
+	var buyerID sql.NullInt64
+	var expiresAt, createdAt []byte
+	if err := row.Scan(&listing.ID, &listing.SellerID, &listing.ItemID, &listing.Count, &listing.Price, &listing.Fee, &listing.Status, &buyerID, &expiresAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if buyerID.Valid {
+		id := entities.UserID(buyerID.Int64)
+		listing.BuyerID = &id
+	}
+	var err error
+	if listing.ExpiresAt, err = parseDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if listing.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// 出品を追加し、採番されたIDをlistingに設定する。
+func (r *marketListingRepository) AddMarketListingTransaction(tx *sql.Tx, listing *entities.MarketListing) error {
+	query := "INSERT INTO market_listings (seller_id, item_id, count, price, fee, status, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, listing.SellerID, listing.ItemID, listing.Count, listing.Price, listing.Fee, listing.Status, listing.ExpiresAt)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	listing.ID = entities.MarketListingID(id)
+
+	return nil
+}
+
+// トランザクション内で出品の行をロックして取得する。
+func (r *marketListingRepository) GetMarketListingByIDForUpdateTransaction(tx *sql.Tx, ID entities.MarketListingID) (*entities.MarketListing, error) {
+	query := "SELECT " + marketListingColumns + " FROM market_listings l WHERE l.id = ? LIMIT 1 FOR UPDATE"
+	listing, err := scanMarketListing(tx.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMarketListingNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return listing, nil
+}
+
+// 条件に合う出品の一覧を取得する。出品中(active)を指定した場合は期限切れの出品を含めない。
+func (r *marketListingRepository) GetMarketListings(filter *entities.MarketListingFilter, at time.Time) ([]entities.MarketListing, error) {
+	query := "SELECT " + marketListingColumns + " FROM market_listings l JOIN item i ON i.id = l.item_id WHERE 1 = 1"
+	var params []interface{}
+	if filter.ItemID != nil {
+		query += " AND l.item_id = ?"
+		params = append(params, *filter.ItemID)
+	}
+	if filter.Rarity != nil {
+		query += " AND i.rarity = ?"
+		params = append(params, *filter.Rarity)
+	}
+	if filter.SellerID != nil {
+		query += " AND l.seller_id = ?"
+		params = append(params, *filter.SellerID)
+	}
+	if filter.Status != nil {
+		query += " AND l.status = ?"
+		params = append(params, *filter.Status)
+		if *filter.Status == entities.MarketListingStatusActive {
+			query += " AND l.expires_at > ?"
+			params = append(params, at.UTC())
+		}
+	}
+	// Item.IsReleasedと同じ条件で、未公開のアイテムを除く
+	if filter.ReleasedOnly {
+		query += " AND i.status <> ? AND (i.released_at IS NULL AND i.hidden_until_released = FALSE OR i.released_at <= ?)"
+		params = append(params, entities.ItemStatusHidden, at.UTC())
+	}
+	if filter.SortBy == "price" {
+		query += " ORDER BY l.price, l.id"
+	} else {
+		query += " ORDER BY l.id DESC"
+	}
+	query += " LIMIT ? OFFSET ?"
+	params = append(params, filter.Limit, filter.Offset)
+	return r.queryMarketListings(query, params...)
+}
+
+// 出品者・購入者としてユーザが関わる出品を、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *marketListingRepository) GetUserMarketListingsAfter(userID entities.UserID, afterID entities.MarketListingID, limit int) ([]entities.MarketListing, error) {
+	query := "SELECT " + marketListingColumns + " FROM market_listings l WHERE (l.seller_id = ? OR l.buyer_id = ?) AND l.id > ? ORDER BY l.id LIMIT ?"
+	return r.queryMarketListings(query, userID, userID, afterID, limit)
+}
+
+func (r *marketListingRepository) queryMarketListings(query string, params ...interface{}) ([]entities.MarketListing, error) {
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	listings := []entities.MarketListing{}
+	for rows.Next() {
+		listing, err := scanMarketListing(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		listings = append(listings, *listing)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return listings, nil
+}
+
+// 出品中のまま期限を過ぎた出品を古い順にlimit件までロックして取得する。
+func (r *marketListingRepository) GetExpiredMarketListingsForUpdateTransaction(tx *sql.Tx, at time.Time, limit int) ([]entities.MarketListing, error) {
+	query := "SELECT " + marketListingColumns + " FROM market_listings l WHERE l.status = ? AND l.expires_at <= ? ORDER BY l.id LIMIT ? FOR UPDATE"
+	rows, err := tx.Query(query, entities.MarketListingStatusActive, at.UTC(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []entities.MarketListing
+	for rows.Next() {
+		listing, err := scanMarketListing(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		listings = append(listings, *listing)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return listings, nil
+}
+
+// 出品を終了する。購入された場合はbuyerIDを指定する。
+func (r *marketListingRepository) CloseMarketListingTransaction(tx *sql.Tx, ID entities.MarketListingID, status entities.MarketListingStatus, buyerID *entities.UserID) error {
+	query := "UPDATE market_listings SET status = ?, buyer_id = ?, closed_at = CURRENT_TIMESTAMP WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, status, buyerID, ID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
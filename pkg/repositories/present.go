@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrPresentNotFound = errors.New("present not found")
+
+type PresentRepository interface {
+	AddPresents(presents []entities.Present) error
+	AddPresentsTransaction(tx *sql.Tx, presents []entities.Present) error
+	GetUnclaimedPresents(userID entities.UserID, at time.Time, limit int) ([]entities.Present, error)
+	GetPresentByIDForUpdateTransaction(tx *sql.Tx, ID entities.PresentID) (*entities.Present, error)
+	GetUnclaimedPresentsForUpdateTransaction(tx *sql.Tx, userID entities.UserID, at time.Time, limit int) ([]entities.Present, error)
+	ClaimPresentsTransaction(tx *sql.Tx, IDs []entities.PresentID, at time.Time) error
+	GetUserPresentsAfter(userID entities.UserID, afterID entities.PresentID, limit int) ([]entities.Present, error)
+}
+
+func NewPresentRepository(db *sql.DB) PresentRepository {
+	return &presentRepository{db}
+}
+
+type presentRepository struct {
+	db *sql.DB
+}
+
+const presentColumns = "id, user_id, coin, item_id, item_count, ticket_type, ticket_count, message, source, expires_at, claimed_at, created_at"
+
+func scanPresent(row rowScanner) (*entities.Present, error) {
+	var present entities.Present
+	var itemID sql.NullInt64
+	var ticketType, expiresAt, claimedAt sql.NullString
+	var createdAt []byte
+	if err := row.Scan(&present.ID, &present.UserID, &present.Reward.Coin, &itemID, &present.Reward.ItemCount, &ticketType, &present.Reward.TicketCount,
+		&present.Message, &present.Source, &expiresAt, &claimedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if itemID.Valid {
+		id := entities.ItemID(itemID.Int64)
+		present.Reward.ItemID = &id
+	}
+	if ticketType.Valid {
+		t := entities.TicketType(ticketType.String)
+		present.Reward.TicketType = &t
+	}
+	var err error
+	if present.ExpiresAt, err = parseNullDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if present.ClaimedAt, err = parseNullDatetime(claimedAt); err != nil {
+		return nil, err
+	}
+	if present.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	return &present, nil
+}
+
+func scanPresents(rows *sql.Rows) ([]entities.Present, error) {
+	defer rows.Close()
+
+	presents := []entities.Present{}
+	for rows.Next() {
+		present, err := scanPresent(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		presents = append(presents, *present)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return presents, nil
+}
+
+// AddPresents プレゼントボックスにプレゼントを追加する。
+// 報酬を直接付与する代わりに、他の機能からプレゼントとして送る場合に使う。
+func (r *presentRepository) AddPresents(presents []entities.Present) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if err := r.AddPresentsTransaction(tx, presents); err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Println(err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// AddPresentsTransaction トランザクション内でプレゼントボックスにプレゼントをまとめて追加する。
+func (r *presentRepository) AddPresentsTransaction(tx *sql.Tx, presents []entities.Present) error {
+	if len(presents) == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO presents (user_id, coin, item_id, item_count, ticket_type, ticket_count, message, source, expires_at) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?, ?, ?, ?),", len(presents)), ",")
+	params := make([]interface{}, 0, len(presents)*9)
+	for _, present := range presents {
+		params = append(params, present.UserID, present.Reward.Coin, present.Reward.ItemID, present.Reward.ItemCount,
+			present.Reward.TicketType, present.Reward.TicketCount, present.Message, present.Source, present.ExpiresAt)
+	}
+	if _, err := tx.Exec(query, params...); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// 受け取っておらず期限も過ぎていないプレゼントを新しい順にlimit件まで取得する。
+func (r *presentRepository) GetUnclaimedPresents(userID entities.UserID, at time.Time, limit int) ([]entities.Present, error) {
+	query := "SELECT " + presentColumns + " FROM presents WHERE user_id = ? AND claimed_at IS NULL AND (expires_at IS NULL OR expires_at > ?) ORDER BY id DESC LIMIT ?"
+	rows, err := r.db.Query(query, userID, at.UTC(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scanPresents(rows)
+}
+
+// トランザクション内でプレゼントの行をロックして取得する。
+func (r *presentRepository) GetPresentByIDForUpdateTransaction(tx *sql.Tx, ID entities.PresentID) (*entities.Present, error) {
+	query := "SELECT " + presentColumns + " FROM presents WHERE id = ? LIMIT 1 FOR UPDATE"
+	present, err := scanPresent(tx.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPresentNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return present, nil
+}
+
+// トランザクション内で受け取っておらず期限も過ぎていないプレゼントを古い順にlimit件までロックして取得する。
+func (r *presentRepository) GetUnclaimedPresentsForUpdateTransaction(tx *sql.Tx, userID entities.UserID, at time.Time, limit int) ([]entities.Present, error) {
+	query := "SELECT " + presentColumns + " FROM presents WHERE user_id = ? AND claimed_at IS NULL AND (expires_at IS NULL OR expires_at > ?) ORDER BY id LIMIT ? FOR UPDATE"
+	rows, err := tx.Query(query, userID, at.UTC(), limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scanPresents(rows)
+}
+
+// プレゼントを受け取り済みにする。
+func (r *presentRepository) ClaimPresentsTransaction(tx *sql.Tx, IDs []entities.PresentID, at time.Time) error {
+	if len(IDs) == 0 {
+		return nil
+	}
+
+	query := "UPDATE presents SET claimed_at = ? WHERE claimed_at IS NULL AND id IN (?" + strings.Repeat(", ?", len(IDs)-1) + ")"
+	params := make([]interface{}, 0, len(IDs)+1)
+	params = append(params, at.UTC())
+	for _, ID := range IDs {
+		params = append(params, ID)
+	}
+	if _, err := execQueryAndReturnAffectedRows(tx, query, params...); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// 受け取り済み・期限切れを含むユーザのプレゼントを、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *presentRepository) GetUserPresentsAfter(userID entities.UserID, afterID entities.PresentID, limit int) ([]entities.Present, error) {
+	query := "SELECT " + presentColumns + " FROM presents WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scanPresents(rows)
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrPurchaseNotFound        = errors.New("purchase not found")
+	ErrPurchaseProductNotFound = errors.New("purchase product not found")
+	ErrPurchaseDuplicate       = errors.New("purchase already exists")
+)
+
+type PurchaseRepository interface {
+	GetActiveProducts() ([]entities.PurchaseProduct, error)
+	GetActiveProductByID(productID string) (*entities.PurchaseProduct, error)
+	AddPurchaseTransaction(tx *sql.Tx, purchase *entities.Purchase) error
+	GetPurchaseByTransactionIDTransaction(tx *sql.Tx, store entities.PurchaseStore, transactionID string) (*entities.Purchase, error)
+	GetPurchaseByTransactionIDForUpdateTransaction(tx *sql.Tx, store entities.PurchaseStore, transactionID string) (*entities.Purchase, error)
+	RefundPurchaseTransaction(tx *sql.Tx, ID entities.PurchaseID, at time.Time, needsSupport bool) error
+	GetPurchasesNeedingSupport(limit int) ([]entities.Purchase, error)
+	ResolvePurchaseSupport(ID entities.PurchaseID) error
+	GetUserPurchasesAfter(userID entities.UserID, afterID entities.PurchaseID, limit int) ([]entities.Purchase, error)
+}
+
+func NewPurchaseRepository(db *sql.DB) PurchaseRepository {
+	return &purchaseRepository{db}
+}
+
+type purchaseRepository struct {
+	db *sql.DB
+}
+
+const purchaseColumns = "id, user_id, store, transaction_id, product_id, paid_coin, bonus_coin, status, needs_support, purchased_at, refunded_at, created_at"
+
+func scanPurchase(row rowScanner) (*entities.Purchase, error) {
+	var purchase entities.Purchase
+	var refundedAt sql.NullString
+	var purchasedAt, createdAt []byte
+	if err := row.Scan(&purchase.ID, &purchase.UserID, &purchase.Store, &purchase.TransactionID, &purchase.ProductID, &purchase.PaidCoin, &purchase.BonusCoin,
+		&purchase.Status, &purchase.NeedsSupport, &purchasedAt, &refundedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if purchase.PurchasedAt, err = parseDatetime(purchasedAt); err != nil {
+		return nil, err
+	}
+	if purchase.RefundedAt, err = parseNullDatetime(refundedAt); err != nil {
+		return nil, err
+	}
+	if purchase.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	return &purchase, nil
+}
+
+// 販売中の商品を取得する
+func (r *purchaseRepository) GetActiveProducts() ([]entities.PurchaseProduct, error) {
+	rows, err := r.db.Query("SELECT product_id, name, paid_coin, bonus_coin FROM purchase_products WHERE is_active ORDER BY paid_coin, product_id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []entities.PurchaseProduct{}
+	for rows.Next() {
+		var product entities.PurchaseProduct
+		if err := rows.Scan(&product.ProductID, &product.Name, &product.PaidCoin, &product.BonusCoin); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return products, nil
+}
+
+// ストアの商品IDに対応する販売中の商品を取得する
+func (r *purchaseRepository) GetActiveProductByID(productID string) (*entities.PurchaseProduct, error) {
+	query := "SELECT product_id, name, paid_coin, bonus_coin FROM purchase_products WHERE product_id = ? AND is_active LIMIT 1"
+	var product entities.PurchaseProduct
+	if err := r.db.QueryRow(query, productID).Scan(&product.ProductID, &product.Name, &product.PaidCoin, &product.BonusCoin); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPurchaseProductNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return &product, nil
+}
+
+// AddPurchaseTransaction 購入を記録する。同じストアの取引IDが記録済みの場合はErrPurchaseDuplicateを返す
+func (r *purchaseRepository) AddPurchaseTransaction(tx *sql.Tx, purchase *entities.Purchase) error {
+	query := `
+		INSERT INTO purchases (user_id, store, transaction_id, product_id, paid_coin, bonus_coin, status, purchased_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.Exec(query, purchase.UserID, purchase.Store, purchase.TransactionID, purchase.ProductID, purchase.PaidCoin, purchase.BonusCoin,
+		purchase.Status, purchase.PurchasedAt.UTC())
+	if err != nil {
+		if isDuplicateEntry(err) {
+			return ErrPurchaseDuplicate
+		}
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	purchase.ID = entities.PurchaseID(id)
+	return nil
+}
+
+func (r *purchaseRepository) getPurchaseByTransactionID(tx *sql.Tx, store entities.PurchaseStore, transactionID string, forUpdate bool) (*entities.Purchase, error) {
+	query := "SELECT " + purchaseColumns + " FROM purchases WHERE store = ? AND transaction_id = ? LIMIT 1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	purchase, err := scanPurchase(tx.QueryRow(query, store, transactionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// トランザクション内でストアの取引IDに対応する購入を取得する。行はロックしない
+func (r *purchaseRepository) GetPurchaseByTransactionIDTransaction(tx *sql.Tx, store entities.PurchaseStore, transactionID string) (*entities.Purchase, error) {
+	return r.getPurchaseByTransactionID(tx, store, transactionID, false)
+}
+
+// トランザクション内でストアの取引IDに対応する購入の行をロックして取得する
+func (r *purchaseRepository) GetPurchaseByTransactionIDForUpdateTransaction(tx *sql.Tx, store entities.PurchaseStore, transactionID string) (*entities.Purchase, error) {
+	return r.getPurchaseByTransactionID(tx, store, transactionID, true)
+}
+
+// RefundPurchaseTransaction 購入を返金済みにする。残高が負になった場合はneedsSupportをtrueにする
+func (r *purchaseRepository) RefundPurchaseTransaction(tx *sql.Tx, ID entities.PurchaseID, at time.Time, needsSupport bool) error {
+	query := "UPDATE purchases SET status = ?, refunded_at = ?, needs_support = ? WHERE id = ?"
+	if _, err := tx.Exec(query, entities.PurchaseStatusRefunded, at.UTC(), needsSupport, ID); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// 返金で残高が負になり、サポートの対応が必要な購入を古い順に取得する
+func (r *purchaseRepository) GetPurchasesNeedingSupport(limit int) ([]entities.Purchase, error) {
+	query := "SELECT " + purchaseColumns + " FROM purchases WHERE needs_support ORDER BY refunded_at, id LIMIT ?"
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []entities.Purchase{}
+	for rows.Next() {
+		purchase, err := scanPurchase(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		purchases = append(purchases, *purchase)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return purchases, nil
+}
+
+// ResolvePurchaseSupport サポートの対応が済んだ購入のフラグを外す
+func (r *purchaseRepository) ResolvePurchaseSupport(ID entities.PurchaseID) error {
+	rows, err := execQueryAndReturnAffectedRows(r.db, "UPDATE purchases SET needs_support = FALSE WHERE id = ? AND needs_support", ID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrPurchaseNotFound
+	}
+	return nil
+}
+
+// ユーザの購入の記録を、afterIDより後のものから古い順にlimit件取得する
+func (r *purchaseRepository) GetUserPurchasesAfter(userID entities.UserID, afterID entities.PurchaseID, limit int) ([]entities.Purchase, error) {
+	query := "SELECT " + purchaseColumns + " FROM purchases WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	purchases := []entities.Purchase{}
+	for rows.Next() {
+		purchase, err := scanPurchase(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		purchases = append(purchases, *purchase)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return purchases, nil
+}
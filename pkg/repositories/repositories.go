@@ -7,23 +7,65 @@ import (
 )
 
 type Repositories struct {
-	DB                       *sql.DB
-	RDB                      *redis.Client
-	GameSettingsRepository   GameSettingsRepository
-	UserRepository           UserRepository
-	ItemRepository           ItemRepository
-	CollectionItemRepository CollectionItemRepository
-	UserScoresRepository     UserScoresRepository
+	DB                            *sql.DB
+	RDB                           *redis.Client
+	GameSettingsRepository        GameSettingsRepository
+	UserRepository                UserRepository
+	ItemRepository                ItemRepository
+	CollectionItemRepository      CollectionItemRepository
+	UserScoresRepository          UserScoresRepository
+	GachaSeedRepository           GachaSeedRepository
+	GachaDrawRepository           GachaDrawRepository
+	CoinLedgerRepository          CoinLedgerRepository
+	SpendingCapRepository         SpendingCapRepository
+	ItemEnhanceSettingsRepository ItemEnhanceSettingsRepository
+	CollectionMilestoneRepository CollectionMilestoneRepository
+	ItemSeriesRepository          ItemSeriesRepository
+	TradeRepository               TradeRepository
+	MarketListingRepository       MarketListingRepository
+	TicketRepository              TicketRepository
+	PresentRepository             PresentRepository
+	CompensationRepository        CompensationRepository
+	CouponRepository              CouponRepository
+	ShopOfferRepository           ShopOfferRepository
+	PurchaseRepository            PurchaseRepository
+	AuthTokenRepository           AuthTokenRepository
+	SessionRepository             SessionRepository
+	TransferRepository            TransferRepository
+	UserStatusRepository          UserStatusRepository
+	AccountDeletionRepository     AccountDeletionRepository
+	DataExportRepository          DataExportRepository
 }
 
 func NewRepositories(db *sql.DB, rdb *redis.Client) *Repositories {
 	return &Repositories{
-		DB:                       db,
-		RDB:                      rdb,
-		GameSettingsRepository:   NewGameSettingsRepository(db, rdb),
-		UserRepository:           NewUserRepository(db),
-		ItemRepository:           NewItemRepository(db, rdb),
-		CollectionItemRepository: NewCollectionItemRepository(db),
-		UserScoresRepository:     NewUserScoresRepository(db),
+		DB:                            db,
+		RDB:                           rdb,
+		GameSettingsRepository:        NewGameSettingsRepository(db, rdb),
+		UserRepository:                NewUserRepository(db),
+		ItemRepository:                NewItemRepository(db, rdb),
+		CollectionItemRepository:      NewCollectionItemRepository(db),
+		UserScoresRepository:          NewUserScoresRepository(db),
+		GachaSeedRepository:           NewGachaSeedRepository(db),
+		GachaDrawRepository:           NewGachaDrawRepository(db),
+		CoinLedgerRepository:          NewCoinLedgerRepository(db),
+		SpendingCapRepository:         NewSpendingCapRepository(db),
+		ItemEnhanceSettingsRepository: NewItemEnhanceSettingsRepository(db, rdb),
+		CollectionMilestoneRepository: NewCollectionMilestoneRepository(db, rdb),
+		ItemSeriesRepository:          NewItemSeriesRepository(db, rdb),
+		TradeRepository:               NewTradeRepository(db),
+		MarketListingRepository:       NewMarketListingRepository(db),
+		TicketRepository:              NewTicketRepository(db),
+		PresentRepository:             NewPresentRepository(db),
+		CompensationRepository:        NewCompensationRepository(db),
+		CouponRepository:              NewCouponRepository(db, rdb),
+		ShopOfferRepository:           NewShopOfferRepository(db, rdb),
+		PurchaseRepository:            NewPurchaseRepository(db),
+		AuthTokenRepository:           NewAuthTokenRepository(db, rdb),
+		SessionRepository:             NewSessionRepository(db),
+		TransferRepository:            NewTransferRepository(db, rdb),
+		UserStatusRepository:          NewUserStatusRepository(db, rdb),
+		AccountDeletionRepository:     NewAccountDeletionRepository(db),
+		DataExportRepository:          NewDataExportRepository(db),
 	}
 }
@@ -0,0 +1,160 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	AddSessionTransaction(tx *sql.Tx, session *entities.Session) error
+	GetActiveSessions(userID entities.UserID, at time.Time) ([]entities.Session, error)
+	GetSessionForUpdateTransaction(tx *sql.Tx, userID entities.UserID, ID entities.SessionID) (*entities.Session, error)
+	ExtendSessionTransaction(tx *sql.Tx, ID entities.SessionID, expiresAt time.Time) error
+	TouchSession(ID entities.SessionID, at time.Time, interval time.Duration) error
+	RevokeSessionTransaction(tx *sql.Tx, ID entities.SessionID, at time.Time) error
+	RevokeUserSessionsTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) (int64, error)
+	GetUserSessionsAfter(userID entities.UserID, afterID entities.SessionID, limit int) ([]entities.Session, error)
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db}
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+const sessionColumns = "id, user_id, device_name, created_at, last_seen_at, expires_at, revoked_at"
+
+func scanSession(row rowScanner) (*entities.Session, error) {
+	var session entities.Session
+	var createdAt, lastSeenAt, expiresAt []byte
+	var revokedAt sql.NullString
+	if err := row.Scan(&session.ID, &session.UserID, &session.DeviceName, &createdAt, &lastSeenAt, &expiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if session.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	if session.LastSeenAt, err = parseDatetime(lastSeenAt); err != nil {
+		return nil, err
+	}
+	if session.ExpiresAt, err = parseDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if session.RevokedAt, err = parseNullDatetime(revokedAt); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) AddSessionTransaction(tx *sql.Tx, session *entities.Session) error {
+	query := "INSERT INTO sessions (user_id, device_name, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, session.UserID, session.DeviceName, session.CreatedAt.UTC(), session.LastSeenAt.UTC(), session.ExpiresAt.UTC())
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	session.ID = entities.SessionID(id)
+	return nil
+}
+
+// ユーザの失効・期限切れでないセッションを最後に使われた順に取得する
+func (r *sessionRepository) GetActiveSessions(userID entities.UserID, at time.Time) ([]entities.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC, id DESC"
+	rows, err := r.db.Query(query, userID, at.UTC())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// 失効・期限切れを含むユーザのセッションを、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *sessionRepository) GetUserSessionsAfter(userID entities.UserID, afterID entities.SessionID, limit int) ([]entities.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+func scanSessions(rows *sql.Rows) ([]entities.Session, error) {
+	defer rows.Close()
+
+	sessions := []entities.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// トランザクション内でユーザのセッションの行をロックして取得する。他のユーザのセッションはErrSessionNotFoundを返す
+func (r *sessionRepository) GetSessionForUpdateTransaction(tx *sql.Tx, userID entities.UserID, ID entities.SessionID) (*entities.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id = ? AND user_id = ? LIMIT 1 FOR UPDATE"
+	session, err := scanSession(tx.QueryRow(query, ID, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	return session, nil
+}
+
+// トークンを交換した際に、セッションの期限を新しいトークンの交換期限まで延長する
+func (r *sessionRepository) ExtendSessionTransaction(tx *sql.Tx, ID entities.SessionID, expiresAt time.Time) error {
+	query := "UPDATE sessions SET expires_at = ? WHERE id = ?"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, expiresAt.UTC(), ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TouchSession セッションの最終利用日時を更新する
+// リクエストのたびに行を書き換えないよう、前回の更新からintervalが経っていない場合は更新しない
+func (r *sessionRepository) TouchSession(ID entities.SessionID, at time.Time, interval time.Duration) error {
+	query := "UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?"
+	if _, err := execQueryAndReturnAffectedRows(r.db, query, at.UTC(), ID, at.Add(-interval).UTC()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeSessionTransaction(tx *sql.Tx, ID entities.SessionID, at time.Time) error {
+	query := "UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	if _, err := execQueryAndReturnAffectedRows(tx, query, at.UTC(), ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ユーザの失効していないセッションをすべて失効させ、失効させた件数を返す
+func (r *sessionRepository) RevokeUserSessionsTransaction(tx *sql.Tx, userID entities.UserID, at time.Time) (int64, error) {
+	query := "UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"
+	return execQueryAndReturnAffectedRows(tx, query, at.UTC(), userID)
+}
@@ -0,0 +1,232 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrShopOfferNotFound = errors.New("shop offer not found")
+
+type ShopOfferRepository interface {
+	GetShopOffers() (entities.ShopOffers, error)
+	CacheShopOffers() error
+	GetShopOffersFromCache() (entities.ShopOffers, error)
+	GetShopOfferFromCache(ID entities.ShopOfferID) (*entities.ShopOffer, error)
+	GetUserPurchaseCounts(userID entities.UserID) (map[entities.ShopOfferID]int64, error)
+	GetUserPurchaseCountTransaction(tx *sql.Tx, userID entities.UserID, offerID entities.ShopOfferID) (int64, error)
+	AddUserPurchaseTransaction(tx *sql.Tx, userID entities.UserID, offerID entities.ShopOfferID, count int64) error
+}
+
+func NewShopOfferRepository(db *sql.DB, rdb *redis.Client) ShopOfferRepository {
+	return &shopOfferRepository{db, rdb}
+}
+
+type shopOfferRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+const shopOfferColumns = "id, name, description, price, item_id, item_count, ticket_type, ticket_count, per_user_limit, starts_at, ends_at, sort_order"
+
+func scanShopOffer(row rowScanner) (*entities.ShopOffer, error) {
+	var offer entities.ShopOffer
+	var itemID sql.NullInt64
+	var ticketType, startsAt, endsAt sql.NullString
+	if err := row.Scan(&offer.ID, &offer.Name, &offer.Description, &offer.Price, &itemID, &offer.Reward.ItemCount, &ticketType, &offer.Reward.TicketCount,
+		&offer.PerUserLimit, &startsAt, &endsAt, &offer.SortOrder); err != nil {
+		return nil, err
+	}
+	if itemID.Valid {
+		id := entities.ItemID(itemID.Int64)
+		offer.Reward.ItemID = &id
+	}
+	if ticketType.Valid {
+		t := entities.TicketType(ticketType.String)
+		offer.Reward.TicketType = &t
+	}
+	var err error
+	if offer.StartsAt, err = parseNullDatetime(startsAt); err != nil {
+		return nil, err
+	}
+	if offer.EndsAt, err = parseNullDatetime(endsAt); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+func shopOfferKey(ID entities.ShopOfferID) string {
+	return fmt.Sprintf("shop_offer:%d", ID)
+}
+
+// 商品のマスターデータを表示順に取得する
+func (r *shopOfferRepository) GetShopOffers() (entities.ShopOffers, error) {
+	rows, err := r.db.Query("SELECT " + shopOfferColumns + " FROM shop_offers ORDER BY sort_order, id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	offers := entities.ShopOffers{}
+	for rows.Next() {
+		offer, err := scanShopOffer(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		offers = append(offers, *offer)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return offers, nil
+}
+
+// 商品を1件ずつキャッシュに書き込む。アイテムと同様に商品ごとのキーで保持する
+func (r *shopOfferRepository) CacheShopOffers() error {
+	offers, err := r.GetShopOffers()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for i := range offers {
+		offerJson, err := json.Marshal(&offers[i])
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if err := r.rdb.Set(ctx, shopOfferKey(offers[i].ID), offerJson, 0).Err(); err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *shopOfferRepository) GetShopOffersFromCache() (entities.ShopOffers, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	keys, err := r.rdb.Keys(ctx, "shop_offer:*").Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return entities.ShopOffers{}, nil
+	}
+
+	// 商品数が増えてもRedisへの問い合わせが1回で済むようにMGETでまとめて取得する
+	offerJsons, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	offers := make(entities.ShopOffers, 0, len(offerJsons))
+	for _, offerJson := range offerJsons {
+		// KEYSとMGETの間に削除された商品はnilになる
+		offerJsonStr, ok := offerJson.(string)
+		if !ok {
+			continue
+		}
+		var offer entities.ShopOffer
+		if err := json.Unmarshal([]byte(offerJsonStr), &offer); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	offers.Sort()
+
+	return offers, nil
+}
+
+func (r *shopOfferRepository) GetShopOfferFromCache(ID entities.ShopOfferID) (*entities.ShopOffer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	offerJson, err := r.rdb.Get(ctx, shopOfferKey(ID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrShopOfferNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+
+	var offer entities.ShopOffer
+	if err := json.Unmarshal([]byte(offerJson), &offer); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// ユーザが商品ごとに購入した個数を取得する
+func (r *shopOfferRepository) GetUserPurchaseCounts(userID entities.UserID) (map[entities.ShopOfferID]int64, error) {
+	rows, err := r.db.Query("SELECT offer_id, count FROM user_shop_purchases WHERE user_id = ?", userID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[entities.ShopOfferID]int64{}
+	for rows.Next() {
+		var offerID entities.ShopOfferID
+		var count int64
+		if err := rows.Scan(&offerID, &count); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		counts[offerID] = count
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// トランザクション内でユーザが商品を購入した個数を取得する。
+// 同時に購入されても上限を超えないよう、ユーザの行をロックしたトランザクション内で呼び出すこと。
+func (r *shopOfferRepository) GetUserPurchaseCountTransaction(tx *sql.Tx, userID entities.UserID, offerID entities.ShopOfferID) (int64, error) {
+	var count int64
+	err := tx.QueryRow("SELECT count FROM user_shop_purchases WHERE user_id = ? AND offer_id = ?", userID, offerID).Scan(&count)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		log.Println(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// AddUserPurchaseTransaction ユーザが商品を購入した個数を加算する。
+func (r *shopOfferRepository) AddUserPurchaseTransaction(tx *sql.Tx, userID entities.UserID, offerID entities.ShopOfferID, count int64) error {
+	query := `
+		INSERT INTO user_shop_purchases (user_id, offer_id, count) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE count = count + VALUES(count)`
+	if _, err := tx.Exec(query, userID, offerID, count); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
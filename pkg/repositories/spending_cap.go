@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+type SpendingCapRepository interface {
+	GetSpendingCaps() ([]entities.SpendingCap, error)
+	GetSpendingCapByAgeBracketTransaction(tx *sql.Tx, ageBracket entities.AgeBracket) (*entities.SpendingCap, error)
+}
+
+func NewSpendingCapRepository(db *sql.DB) SpendingCapRepository {
+	return &spendingCapRepository{db}
+}
+
+type spendingCapRepository struct {
+	db *sql.DB
+}
+
+func (r *spendingCapRepository) GetSpendingCaps() ([]entities.SpendingCap, error) {
+	query := "SELECT age_bracket, monthly_cap FROM spending_caps"
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var caps []entities.SpendingCap
+	for rows.Next() {
+		var spendingCap entities.SpendingCap
+		if err := rows.Scan(&spendingCap.AgeBracket, &spendingCap.MonthlyCap); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		caps = append(caps, spendingCap)
+	}
+	return caps, nil
+}
+
+func (r *spendingCapRepository) GetSpendingCapByAgeBracketTransaction(tx *sql.Tx, ageBracket entities.AgeBracket) (*entities.SpendingCap, error) {
+	query := "SELECT age_bracket, monthly_cap FROM spending_caps WHERE age_bracket = ? LIMIT 1"
+
+	var spendingCap entities.SpendingCap
+	if err := tx.QueryRow(query, ageBracket).Scan(&spendingCap.AgeBracket, &spendingCap.MonthlyCap); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &spendingCap, nil
+}
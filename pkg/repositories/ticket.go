@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"database/sql"
+	"log"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+type TicketRepository interface {
+	GetUserTickets(userID entities.UserID) ([]entities.UserTicket, error)
+	AddUserTicketsTransaction(tx *sql.Tx, userID entities.UserID, ticketType entities.TicketType, count entities.TicketCount) error
+}
+
+func NewTicketRepository(db *sql.DB) TicketRepository {
+	return &ticketRepository{db}
+}
+
+type ticketRepository struct {
+	db *sql.DB
+}
+
+// 所持しているチケットの種類と枚数を取得する。
+func (r *ticketRepository) GetUserTickets(userID entities.UserID) ([]entities.UserTicket, error) {
+	query := "SELECT ticket_type, count FROM user_tickets WHERE user_id = ? AND count > 0 ORDER BY ticket_type"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []entities.UserTicket{}
+	for rows.Next() {
+		var ticket entities.UserTicket
+		if err := rows.Scan(&ticket.TicketType, &ticket.Count); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	return tickets, nil
+}
+
+// チケットを追加する。既に所持している場合は枚数を加算する。
+func (r *ticketRepository) AddUserTicketsTransaction(tx *sql.Tx, userID entities.UserID, ticketType entities.TicketType, count entities.TicketCount) error {
+	query := "INSERT INTO user_tickets (user_id, ticket_type, count) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE count = count + VALUES(count)"
+	if _, err := tx.Exec(query, userID, ticketType, count); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
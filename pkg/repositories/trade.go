@@ -0,0 +1,234 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrTradeNotFound = errors.New("trade not found")
+
+type TradeRepository interface {
+	AddTradeTransaction(tx *sql.Tx, trade *entities.Trade) error
+	GetTradeByIDForUpdateTransaction(tx *sql.Tx, ID entities.TradeID) (*entities.Trade, error)
+	UpdateTradeStatusTransaction(tx *sql.Tx, ID entities.TradeID, status entities.TradeStatus) error
+	GetTradesByProposerID(userID entities.UserID, status *entities.TradeStatus) ([]entities.Trade, error)
+	GetTradesByReceiverID(userID entities.UserID, status *entities.TradeStatus) ([]entities.Trade, error)
+	ExpireTrades(userID entities.UserID, at time.Time) error
+	GetUserTradesAfter(userID entities.UserID, afterID entities.TradeID, limit int) ([]entities.Trade, error)
+}
+
+func NewTradeRepository(db *sql.DB) TradeRepository {
+	return &tradeRepository{db}
+}
+
+type tradeRepository struct {
+	db *sql.DB
+}
+
+const tradeColumns = "id, proposer_id, receiver_id, offer_coin, request_coin, status, expires_at, created_at"
+
+func scanTrade(row rowScanner) (*entities.Trade, error) {
+	var trade entities.Trade
+	var expiresAt, createdAt []byte
+	if err := row.Scan(&trade.ID, &trade.ProposerID, &trade.ReceiverID, &trade.OfferCoin, &trade.RequestCoin, &trade.Status, &expiresAt, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if trade.ExpiresAt, err = parseDatetime(expiresAt); err != nil {
+		return nil, err
+	}
+	if trade.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		return nil, err
+	}
+	trade.OfferItems = []entities.TradeItem{}
+	trade.RequestItems = []entities.TradeItem{}
+	return &trade, nil
+}
+
+// トレードと渡すアイテムを追加し、採番されたIDをtradeに設定する。
+func (r *tradeRepository) AddTradeTransaction(tx *sql.Tx, trade *entities.Trade) error {
+	query := "INSERT INTO trades (proposer_id, receiver_id, offer_coin, request_coin, status, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, trade.ProposerID, trade.ReceiverID, trade.OfferCoin, trade.RequestCoin, trade.Status, trade.ExpiresAt)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	trade.ID = entities.TradeID(id)
+
+	if len(trade.OfferItems)+len(trade.RequestItems) == 0 {
+		return nil
+	}
+
+	// 両者のアイテムを1回のINSERTで追加する
+	query = "INSERT INTO trade_items (trade_id, side, item_id, count) VALUES "
+	params := make([]interface{}, 0, (len(trade.OfferItems)+len(trade.RequestItems))*4)
+	for _, item := range trade.OfferItems {
+		query += "(?, ?, ?, ?),"
+		params = append(params, trade.ID, entities.TradeSideProposer, item.ItemID, item.Count)
+	}
+	for _, item := range trade.RequestItems {
+		query += "(?, ?, ?, ?),"
+		params = append(params, trade.ID, entities.TradeSideReceiver, item.ItemID, item.Count)
+	}
+	if _, err := tx.Exec(query[:len(query)-1], params...); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// トランザクション内でトレードの行をロックして取得する。
+func (r *tradeRepository) GetTradeByIDForUpdateTransaction(tx *sql.Tx, ID entities.TradeID) (*entities.Trade, error) {
+	query := "SELECT " + tradeColumns + " FROM trades WHERE id = ? LIMIT 1 FOR UPDATE"
+	trade, err := scanTrade(tx.QueryRow(query, ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTradeNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+
+	trades := []*entities.Trade{trade}
+	if err := r.fillTradeItems(tx, trades); err != nil {
+		return nil, err
+	}
+	return trade, nil
+}
+
+func (r *tradeRepository) UpdateTradeStatusTransaction(tx *sql.Tx, ID entities.TradeID, status entities.TradeStatus) error {
+	query := "UPDATE trades SET status = ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, status, ID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// 自身が提案したトレードを新しい順に取得する。statusがnilの場合は全ての状態を取得する。
+func (r *tradeRepository) GetTradesByProposerID(userID entities.UserID, status *entities.TradeStatus) ([]entities.Trade, error) {
+	return r.getTrades("proposer_id", userID, status)
+}
+
+// 自身に届いたトレードを新しい順に取得する。statusがnilの場合は全ての状態を取得する。
+func (r *tradeRepository) GetTradesByReceiverID(userID entities.UserID, status *entities.TradeStatus) ([]entities.Trade, error) {
+	return r.getTrades("receiver_id", userID, status)
+}
+
+func (r *tradeRepository) getTrades(userColumn string, userID entities.UserID, status *entities.TradeStatus) ([]entities.Trade, error) {
+	query := "SELECT " + tradeColumns + " FROM trades WHERE " + userColumn + " = ?"
+	params := []interface{}{userID}
+	if status != nil {
+		query += " AND status = ?"
+		params = append(params, *status)
+	}
+	query += " ORDER BY id DESC"
+	return r.queryTrades(query, params...)
+}
+
+// 提案者・受取人としてユーザが関わるトレードを、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *tradeRepository) GetUserTradesAfter(userID entities.UserID, afterID entities.TradeID, limit int) ([]entities.Trade, error) {
+	query := "SELECT " + tradeColumns + " FROM trades WHERE (proposer_id = ? OR receiver_id = ?) AND id > ? ORDER BY id LIMIT ?"
+	return r.queryTrades(query, userID, userID, afterID, limit)
+}
+
+// トレードを取得し、渡すアイテムを設定する
+func (r *tradeRepository) queryTrades(query string, params ...interface{}) ([]entities.Trade, error) {
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []*entities.Trade
+	for rows.Next() {
+		trade, err := scanTrade(rows)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		trades = append(trades, trade)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if err := r.fillTradeItems(r.db, trades); err != nil {
+		return nil, err
+	}
+
+	result := make([]entities.Trade, 0, len(trades))
+	for _, trade := range trades {
+		result = append(result, *trade)
+	}
+	return result, nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// トレードで渡すアイテムをまとめて取得し、各トレードに設定する
+func (r *tradeRepository) fillTradeItems(db queryer, trades []*entities.Trade) error {
+	if len(trades) == 0 {
+		return nil
+	}
+
+	tradeMap := make(map[entities.TradeID]*entities.Trade, len(trades))
+	params := make([]interface{}, 0, len(trades))
+	for _, trade := range trades {
+		tradeMap[trade.ID] = trade
+		params = append(params, trade.ID)
+	}
+
+	query := "SELECT trade_id, side, item_id, count FROM trade_items WHERE trade_id IN (?" + strings.Repeat(", ?", len(trades)-1) + ") ORDER BY trade_id, item_id"
+	rows, err := db.Query(query, params...)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tradeID entities.TradeID
+		var side entities.TradeSide
+		var item entities.TradeItem
+		if err := rows.Scan(&tradeID, &side, &item.ItemID, &item.Count); err != nil {
+			log.Println(err)
+			return err
+		}
+		trade := tradeMap[tradeID]
+		if side == entities.TradeSideProposer {
+			trade.OfferItems = append(trade.OfferItems, item)
+		} else {
+			trade.RequestItems = append(trade.RequestItems, item)
+		}
+	}
+	return rows.Err()
+}
+
+// 応答待ちのまま期限を過ぎた、ユーザが関わるトレードを期限切れにする
+func (r *tradeRepository) ExpireTrades(userID entities.UserID, at time.Time) error {
+	query := "UPDATE trades SET status = ? WHERE (proposer_id = ? OR receiver_id = ?) AND status = ? AND expires_at <= ?"
+	_, err := execQueryAndReturnAffectedRows(r.db, query, entities.TradeStatusExpired, userID, userID, entities.TradeStatusPending, at.UTC())
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
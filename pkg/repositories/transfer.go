@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var (
+	ErrTransferCodeNotFound  = errors.New("transfer code not found")
+	ErrTransferCodeDuplicate = errors.New("transfer id already exists")
+)
+
+type TransferRepository interface {
+	ReplaceTransferCode(code *entities.TransferCode) error
+	GetTransferCodeForUpdateTransaction(tx *sql.Tx, transferID string) (*entities.TransferCode, error)
+	DeleteTransferCodeTransaction(tx *sql.Tx, userID entities.UserID) error
+	AddTransferAuditLog(entry *entities.TransferAuditLog) error
+	AddTransferAuditLogTransaction(tx *sql.Tx, entry *entities.TransferAuditLog) error
+	GetUserTransferAuditLogsAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedTransferAuditLog, error)
+	GetTransferFailureCounts(transferID, ip string) (byTransferID, byIP int64, err error)
+	AddTransferFailure(transferID, ip string, window time.Duration) error
+}
+
+func NewTransferRepository(db *sql.DB, rdb *redis.Client) TransferRepository {
+	return &transferRepository{db, rdb}
+}
+
+type transferRepository struct {
+	db  *sql.DB
+	rdb *redis.Client
+}
+
+func transferIDFailureKey(transferID string) string {
+	return fmt.Sprintf("transfer_failures:id:%s", transferID)
+}
+
+func transferIPFailureKey(ip string) string {
+	return fmt.Sprintf("transfer_failures:ip:%s", ip)
+}
+
+// ReplaceTransferCode ユーザの引き継ぎコードを発行し直す。以前のコードは使えなくなる
+// 引き継ぎIDが他のユーザのコードと重複した場合はErrTransferCodeDuplicateを返す
+func (r *transferRepository) ReplaceTransferCode(code *entities.TransferCode) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	// ON DUPLICATE KEY UPDATEでは引き継ぎIDが重複した他のユーザの行を書き換えてしまうため、削除してから登録する
+	if _, err := tx.Exec("DELETE FROM transfer_codes WHERE user_id = ?", code.UserID); err != nil {
+		log.Println(err)
+		return err
+	}
+	query := "INSERT INTO transfer_codes (user_id, transfer_id, password_salt, password_hash, created_at) VALUES (?, ?, ?, ?, ?)"
+	if _, err := tx.Exec(query, code.UserID, code.TransferID, code.PasswordSalt, code.PasswordHash, code.CreatedAt.UTC()); err != nil {
+		if isDuplicateEntry(err) {
+			return ErrTransferCodeDuplicate
+		}
+		log.Println(err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// トランザクション内で引き継ぎIDに対応するコードの行をロックして取得する
+func (r *transferRepository) GetTransferCodeForUpdateTransaction(tx *sql.Tx, transferID string) (*entities.TransferCode, error) {
+	query := "SELECT user_id, transfer_id, password_salt, password_hash, created_at FROM transfer_codes WHERE transfer_id = ? LIMIT 1 FOR UPDATE"
+	var code entities.TransferCode
+	var createdAt []byte
+	if err := tx.QueryRow(query, transferID).Scan(&code.UserID, &code.TransferID, &code.PasswordSalt, &code.PasswordHash, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferCodeNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	var err error
+	if code.CreatedAt, err = parseDatetime(createdAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *transferRepository) DeleteTransferCodeTransaction(tx *sql.Tx, userID entities.UserID) error {
+	if _, err := execQueryAndReturnAffectedRows(tx, "DELETE FROM transfer_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func addTransferAuditLog(db queryExecuter, entry *entities.TransferAuditLog) error {
+	query := "INSERT INTO transfer_audit_logs (user_id, transfer_id, action, ip) VALUES (?, ?, ?, ?)"
+	if _, err := db.Exec(query, entry.UserID, entry.TransferID, entry.Action, entry.IP); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+func (r *transferRepository) AddTransferAuditLog(entry *entities.TransferAuditLog) error {
+	return addTransferAuditLog(r.db, entry)
+}
+
+func (r *transferRepository) AddTransferAuditLogTransaction(tx *sql.Tx, entry *entities.TransferAuditLog) error {
+	return addTransferAuditLog(tx, entry)
+}
+
+// ユーザの引き継ぎの操作の記録を、afterIDより後から古い順にlimit件まで取得する。エクスポートに使う
+func (r *transferRepository) GetUserTransferAuditLogsAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedTransferAuditLog, error) {
+	query := "SELECT id, transfer_id, action, ip, created_at FROM transfer_audit_logs WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []entities.ExportedTransferAuditLog{}
+	for rows.Next() {
+		var entry entities.ExportedTransferAuditLog
+		var createdAt []byte
+		if err := rows.Scan(&entry.ID, &entry.TransferID, &entry.Action, &entry.IP, &createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if entry.CreatedAt, err = parseDatetime(createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// 引き継ぎIDごと・IPアドレスごとの引き継ぎに失敗した回数を取得する
+func (r *transferRepository) GetTransferFailureCounts(transferID, ip string) (byTransferID, byIP int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	values, err := r.rdb.MGet(ctx, transferIDFailureKey(transferID), transferIPFailureKey(ip)).Result()
+	if err != nil {
+		log.Println(err)
+		return 0, 0, err
+	}
+	counts := make([]int64, len(values))
+	for i, value := range values {
+		// 失敗していない場合はnilになる
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if counts[i], err = strconv.ParseInt(str, 10, 64); err != nil {
+			log.Println(err)
+			return 0, 0, err
+		}
+	}
+	return counts[0], counts[1], nil
+}
+
+// AddTransferFailure 引き継ぎIDごと・IPアドレスごとの引き継ぎに失敗した回数を増やす。
+// 回数は最初の失敗からwindowの間だけ保持する。
+func (r *transferRepository) AddTransferFailure(transferID, ip string, window time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	for _, key := range []string{transferIDFailureKey(transferID), transferIPFailureKey(ip)} {
+		count, err := r.rdb.Incr(ctx, key).Result()
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if count == 1 {
+			if err := r.rdb.Expire(ctx, key, window).Err(); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+	}
+	return nil
+}
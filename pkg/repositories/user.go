@@ -2,23 +2,30 @@ package repositories
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
 	"log"
 
 	"42tokyo-road-to-dojo-go/pkg/server/entities"
 )
 
+// ErrAgeBracketAlreadySet 年齢区分が設定済みで、別の区分に変更しようとした
+var ErrAgeBracketAlreadySet = errors.New("age bracket is already set")
+
 type UserRepository interface {
 	GetUsers() ([]*entities.User, error)
 	GetUserByID(ID entities.UserID) (*entities.User, error)
-	GetUserIDAuthToken(token entities.AuthToken) (entities.UserID, error)
-	CreateUser(user *entities.User) error
+	GetUserByIDForUpdateTransaction(tx *sql.Tx, ID entities.UserID) (*entities.User, error)
+	CreateUserTransaction(tx *sql.Tx, user *entities.User) error
 	UpdateUserNameByID(ID entities.UserID, name entities.UserName) error
+	SetUserAgeBracketByID(ID entities.UserID, ageBracket entities.AgeBracket) error
 	UpdateUserCoinsByID(ID entities.UserID, coin entities.Coin) error
 	UpdateUserCoinsByIDTransaction(tx *sql.Tx, ID entities.UserID, coin entities.Coin) error
+	AddUserCoinsByIDTransaction(tx *sql.Tx, ID entities.UserID, delta entities.Coin) error
+	SpendUserCoinsByIDTransaction(tx *sql.Tx, ID entities.UserID, spend entities.CoinSpend) error
+	AddUserCoinBalancesByIDTransaction(tx *sql.Tx, ID entities.UserID, delta entities.CoinSpend) error
+	GetCoinBalanceTotals() (free, paid entities.Coin, paidHolders int64, err error)
 	UpdateUserHighScoreByID(ID entities.UserID, score entities.Score) error
 	UpdateUserHighScoreByIDTransaction(tx *sql.Tx, ID entities.UserID, score entities.Score) error
-	DeleteUserByID(ID entities.UserID) error
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
@@ -29,8 +36,31 @@ type userRepository struct {
 	db *sql.DB
 }
 
+const userColumns = "id, name, high_score, coin, paid_coin, age_bracket, deletion_scheduled_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*entities.User, error) {
+	var user entities.User
+	var ageBracket, deletionScheduledAt sql.NullString
+	if err := row.Scan(&user.ID, &user.Name, &user.HighScore, &user.Coin, &user.PaidCoin, &ageBracket, &deletionScheduledAt); err != nil {
+		return nil, err
+	}
+	if ageBracket.Valid {
+		bracket := entities.AgeBracket(ageBracket.String)
+		user.AgeBracket = &bracket
+	}
+	var err error
+	if user.DeletionScheduledAt, err = parseNullDatetime(deletionScheduledAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) GetUsers() ([]*entities.User, error) {
-	query := "SELECT * FROM user"
+	query := "SELECT " + userColumns + " FROM user"
 	rows, err := r.db.Query(query)
 	if err != nil {
 		log.Println(err)
@@ -47,19 +77,19 @@ func (r *userRepository) GetUsers() ([]*entities.User, error) {
 
 	var users []*entities.User
 	for rows.Next() {
-		var user entities.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.HighScore, &user.Coin, &user.AuthToken); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	return users, nil
 }
 
 func (r *userRepository) GetUserByID(ID entities.UserID) (*entities.User, error) {
-	query := "SELECT * FROM user WHERE id = ? LIMIT 1"
+	query := "SELECT " + userColumns + " FROM user WHERE id = ? LIMIT 1"
 	row := r.db.QueryRow(query, ID)
 	defer func() {
 		if r := recover(); r != nil {
@@ -67,45 +97,43 @@ func (r *userRepository) GetUserByID(ID entities.UserID) (*entities.User, error)
 		}
 	}()
 
-	var user entities.User
-	if err := row.Scan(&user.ID, &user.Name, &user.HighScore, &user.Coin, &user.AuthToken); err != nil {
+	user, err := scanUser(row)
+	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (r *userRepository) GetUserIDAuthToken(token entities.AuthToken) (entities.UserID, error) {
-	query := "SELECT id FROM user WHERE auth_token = ? LIMIT 1"
-	row := r.db.QueryRow(query, token)
-	defer func() {
-		if r := recover(); r != nil {
-			log.Println(r)
-		}
-	}()
-
-	var ID entities.UserID
-	if err := row.Scan(&ID); err != nil {
-		if err == sql.ErrNoRows {
-			err = fmt.Errorf("user not found with provided auth token")
-			log.Println(err)
-			return 0, err
-		}
+// トランザクション内でユーザの行をロックして取得する。
+// コインの残高確認と更新の間に他のリクエストが割り込まないようにするために使う。
+func (r *userRepository) GetUserByIDForUpdateTransaction(tx *sql.Tx, ID entities.UserID) (*entities.User, error) {
+	query := "SELECT " + userColumns + " FROM user WHERE id = ? LIMIT 1 FOR UPDATE"
+	user, err := scanUser(tx.QueryRow(query, ID))
+	if err != nil {
 		log.Println(err)
-		return 0, err
+		return nil, err
 	}
 
-	return ID, nil
+	return user, nil
 }
 
-func (r *userRepository) CreateUser(user *entities.User) error {
-	query := "INSERT INTO user (name, auth_token) VALUES (?, ?)"
-	_, err := r.db.Exec(query, user.Name, user.AuthToken)
+// トランザクション内でユーザを作成し、userに採番したIDを設定する。
+// 認証用トークンは同じトランザクション内でauth_tokensに発行する。
+func (r *userRepository) CreateUserTransaction(tx *sql.Tx, user *entities.User) error {
+	query := "INSERT INTO user (name, age_bracket) VALUES (?, ?)"
+	result, err := tx.Exec(query, user.Name, user.AgeBracket)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
+	user.ID = entities.UserID(id)
 
 	return nil
 }
@@ -121,6 +149,32 @@ func (r *userRepository) UpdateUserNameByID(ID entities.UserID, name entities.Us
 	return nil
 }
 
+// SetUserAgeBracketByID 年齢区分が未設定の場合のみ設定する。月間コイン消費上限を外せないように、設定後は変更できない
+// 設定済みの区分と同じ場合は何もせず、異なる場合はErrAgeBracketAlreadySetを返す
+func (r *userRepository) SetUserAgeBracketByID(ID entities.UserID, ageBracket entities.AgeBracket) error {
+	query := "UPDATE user SET age_bracket = ? WHERE id = ? AND age_bracket IS NULL"
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, ageBracket, ID)
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var current sql.NullString
+	if err := r.db.QueryRow("SELECT age_bracket FROM user WHERE id = ?", ID).Scan(&current); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		log.Println(err)
+		return err
+	}
+	if !current.Valid || entities.AgeBracket(current.String) != ageBracket {
+		return ErrAgeBracketAlreadySet
+	}
+	return nil
+}
+
 func (r *userRepository) UpdateUserCoinsByID(ID entities.UserID, coin entities.Coin) error {
 	query := "UPDATE user SET coin = ? WHERE id = ?"
 	_, err := execQueryAndReturnAffectedRows(r.db, query, coin, ID)
@@ -143,9 +197,10 @@ func (r *userRepository) UpdateUserCoinsByIDTransaction(tx *sql.Tx, ID entities.
 	return nil
 }
 
-func (r *userRepository) UpdateUserHighScoreByID(ID entities.UserID, highScore entities.Score) error {
-	query := "UPDATE user SET high_score = ? WHERE id = ?"
-	_, err := execQueryAndReturnAffectedRows(r.db, query, highScore, ID)
+// 所持コインにdeltaを加算する。消費する場合はdeltaを負にする。
+func (r *userRepository) AddUserCoinsByIDTransaction(tx *sql.Tx, ID entities.UserID, delta entities.Coin) error {
+	query := "UPDATE user SET coin = coin + ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, delta, ID)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -154,9 +209,10 @@ func (r *userRepository) UpdateUserHighScoreByID(ID entities.UserID, highScore e
 	return nil
 }
 
-func (r *userRepository) UpdateUserHighScoreByIDTransaction(tx *sql.Tx, ID entities.UserID, highScore entities.Score) error {
-	query := "UPDATE user SET high_score = ? WHERE id = ?"
-	_, err := execQueryAndReturnAffectedRows(tx, query, highScore, ID)
+// 有償コインと無償コインをそれぞれの内訳の分だけ消費する。
+func (r *userRepository) SpendUserCoinsByIDTransaction(tx *sql.Tx, ID entities.UserID, spend entities.CoinSpend) error {
+	query := "UPDATE user SET coin = coin - ?, paid_coin = paid_coin - ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, spend.Free, spend.Paid, ID)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -165,15 +221,45 @@ func (r *userRepository) UpdateUserHighScoreByIDTransaction(tx *sql.Tx, ID entit
 	return nil
 }
 
-func (r *userRepository) DeleteUserByID(ID entities.UserID) error {
-	query := "DELETE FROM users WHERE id = ?"
-	result, err := execQueryAndReturnAffectedRows(r.db, query, ID)
+// 有償コインと無償コインにそれぞれの内訳の分だけ加算する。返金の取り消しでは負の値を渡し、残高が負になることもある。
+func (r *userRepository) AddUserCoinBalancesByIDTransaction(tx *sql.Tx, ID entities.UserID, delta entities.CoinSpend) error {
+	query := "UPDATE user SET coin = coin + ?, paid_coin = paid_coin + ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, delta.Free, delta.Paid, ID)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	if result == 0 {
-		return fmt.Errorf("user id %d not found", ID)
+
+	return nil
+}
+
+// 全ユーザの無償コインと有償コインの残高の合計、有償コインを所持するユーザ数を取得する。
+func (r *userRepository) GetCoinBalanceTotals() (free, paid entities.Coin, paidHolders int64, err error) {
+	query := "SELECT COALESCE(SUM(coin), 0), COALESCE(SUM(paid_coin), 0), COUNT(CASE WHEN paid_coin > 0 THEN 1 END) FROM user"
+	if err := r.db.QueryRow(query).Scan(&free, &paid, &paidHolders); err != nil {
+		log.Println(err)
+		return 0, 0, 0, err
+	}
+	return free, paid, paidHolders, nil
+}
+
+func (r *userRepository) UpdateUserHighScoreByID(ID entities.UserID, highScore entities.Score) error {
+	query := "UPDATE user SET high_score = ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(r.db, query, highScore, ID)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdateUserHighScoreByIDTransaction(tx *sql.Tx, ID entities.UserID, highScore entities.Score) error {
+	query := "UPDATE user SET high_score = ? WHERE id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, highScore, ID)
+	if err != nil {
+		log.Println(err)
+		return err
 	}
 
 	return nil
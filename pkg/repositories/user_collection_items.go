@@ -9,6 +9,9 @@ import (
 
 type CollectionItemRepository interface {
 	GetCollectionItems(userID entities.UserID) (*[]entities.ItemID, error)
+	GetUserItems(userID entities.UserID) ([]entities.UserItem, error)
+	GetUserItemForUpdateTransaction(tx *sql.Tx, userID entities.UserID, itemID entities.ItemID) (*entities.UserItem, error)
+	UpdateUserItemTransaction(tx *sql.Tx, userID entities.UserID, userItem *entities.UserItem) error
 	AddCollectionItems(userID entities.UserID, itemIDs []entities.ItemID) error
 	AddCollectionItemsTransaction(tx *sql.Tx, userID entities.UserID, itemIDs []entities.ItemID) error
 }
@@ -52,29 +55,46 @@ func (r *collectionItemRepository) GetCollectionItems(userID entities.UserID) (*
 	return &itemIDs, nil
 }
 
-// 所持アイテムを追加する。重複追加は発生しない。
-func (r *collectionItemRepository) AddCollectionItems(userID entities.UserID, itemIDs []entities.ItemID) error {
-	if len(itemIDs) == 0 {
-		return nil // 追加するアイテムがない場合は、何もしない
+// 自身の所持するアイテムのIDと所持数、強化レベルを取得する。
+func (r *collectionItemRepository) GetUserItems(userID entities.UserID) ([]entities.UserItem, error) {
+	query := "SELECT item_id, count, level FROM user_items WHERE user_id = ?"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	// クエリのベースを作成
-	query := "INSERT INTO user_items (user_id, item_id) VALUES "
+	var userItems []entities.UserItem
+	for rows.Next() {
+		var userItem entities.UserItem
+		if err := rows.Scan(&userItem.ItemID, &userItem.Count, &userItem.Level); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		userItems = append(userItems, userItem)
+	}
 
-	// パラメータのスライスを作成
-	var params []interface{}
+	return userItems, nil
+}
 
-	// 各アイテムIDについて、クエリを構築
-	for _, itemID := range itemIDs {
-		query += "(?, ?),"
-		params = append(params, userID, itemID)
-	}
+// トランザクション内で所持アイテムの行をロックして取得する。
+func (r *collectionItemRepository) GetUserItemForUpdateTransaction(tx *sql.Tx, userID entities.UserID, itemID entities.ItemID) (*entities.UserItem, error) {
+	query := "SELECT item_id, count, level FROM user_items WHERE user_id = ? AND item_id = ? LIMIT 1 FOR UPDATE"
 
-	// 最後のカンマを削除
-	query = query[:len(query)-1]
+	var userItem entities.UserItem
+	if err := tx.QueryRow(query, userID, itemID).Scan(&userItem.ItemID, &userItem.Count, &userItem.Level); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return &userItem, nil
+}
 
-	// クエリを実行
-	_, err := r.db.Exec(query, params...)
+// 所持アイテムの所持数と強化レベルを更新する。
+func (r *collectionItemRepository) UpdateUserItemTransaction(tx *sql.Tx, userID entities.UserID, userItem *entities.UserItem) error {
+	query := "UPDATE user_items SET count = ?, level = ? WHERE user_id = ? AND item_id = ?"
+	_, err := execQueryAndReturnAffectedRows(tx, query, userItem.Count, userItem.Level, userID, userItem.ItemID)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -82,26 +102,32 @@ func (r *collectionItemRepository) AddCollectionItems(userID entities.UserID, it
 	return nil
 }
 
-// 複数の所持アイテムを追加する。重複追加は発生しない。
-func (r *collectionItemRepository) AddCollectionItemsTransaction(tx *sql.Tx, userID entities.UserID, itemIDs []entities.ItemID) error {
+// 所持アイテムを追加する。
+// 同じアイテムが複数含まれる場合や既に所持している場合は、所持数を加算する。
+func (r *collectionItemRepository) AddCollectionItems(userID entities.UserID, itemIDs []entities.ItemID) error {
 	if len(itemIDs) == 0 {
 		return nil // 追加するアイテムがない場合は、何もしない
 	}
 
-	// クエリのベースを作成
-	query := "INSERT INTO user_items (user_id, item_id) VALUES "
+	query, params := buildAddCollectionItemsQuery(userID, itemIDs)
 
-	// パラメータのスライスを作成
-	var params []interface{}
+	// クエリを実行
+	_, err := r.db.Exec(query, params...)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
 
-	// 各アイテムIDについて、クエリを構築
-	for _, itemID := range itemIDs {
-		query += "(?, ?),"
-		params = append(params, userID, itemID)
+// 複数の所持アイテムを追加する。
+// 同じアイテムが複数含まれる場合や既に所持している場合は、所持数を加算する。
+func (r *collectionItemRepository) AddCollectionItemsTransaction(tx *sql.Tx, userID entities.UserID, itemIDs []entities.ItemID) error {
+	if len(itemIDs) == 0 {
+		return nil // 追加するアイテムがない場合は、何もしない
 	}
 
-	// 最後のカンマを削除
-	query = query[:len(query)-1]
+	query, params := buildAddCollectionItemsQuery(userID, itemIDs)
 
 	// トランザクション内でクエリを実行
 	_, err := tx.Exec(query, params...)
@@ -111,3 +137,33 @@ func (r *collectionItemRepository) AddCollectionItemsTransaction(tx *sql.Tx, use
 	}
 	return nil
 }
+
+// アイテムごとの獲得数を集計し、1回のINSERT ... ON DUPLICATE KEY UPDATEで所持数を加算するクエリを作成する
+func buildAddCollectionItemsQuery(userID entities.UserID, itemIDs []entities.ItemID) (string, []interface{}) {
+	// 獲得順を保ったままアイテムごとの獲得数を集計
+	counts := make(map[entities.ItemID]entities.ItemCount, len(itemIDs))
+	var uniqueIDs []entities.ItemID
+	for _, itemID := range itemIDs {
+		if _, ok := counts[itemID]; !ok {
+			uniqueIDs = append(uniqueIDs, itemID)
+		}
+		counts[itemID]++
+	}
+
+	// クエリのベースを作成
+	query := "INSERT INTO user_items (user_id, item_id, count) VALUES "
+
+	// パラメータのスライスを作成
+	params := make([]interface{}, 0, len(uniqueIDs)*3)
+
+	// 各アイテムIDについて、クエリを構築
+	for _, itemID := range uniqueIDs {
+		query += "(?, ?, ?),"
+		params = append(params, userID, itemID, counts[itemID])
+	}
+
+	// 最後のカンマを削除し、既に所持している場合は所持数を加算する
+	query = query[:len(query)-1] + " ON DUPLICATE KEY UPDATE count = count + VALUES(count)"
+
+	return query, params
+}
@@ -12,6 +12,7 @@ type UserScoresRepository interface {
 	AddUserScore(userID entities.UserID, score entities.Score) error
 	AddUserScoreTransaction(tx *sql.Tx, userID entities.UserID, score entities.Score) error
 	GetUserScoreWithUserName(offset int64, limit entities.RankingListLimit) (*entities.UserScoresJoinedUserName, error)
+	GetUserScoresAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedScore, error)
 }
 
 func NewUserScoresRepository(db *sql.DB) UserScoresRepository {
@@ -42,11 +43,13 @@ func (r *userScoresRepository) AddUserScoreTransaction(tx *sql.Tx, userID entiti
 	return nil
 }
 
+// GetUserScoreWithUserName スコアの高い順にランキングを取得する。凍結されたユーザのスコアは記録を残したまま除外する
 func (r *userScoresRepository) GetUserScoreWithUserName(offset int64, limit entities.RankingListLimit) (*entities.UserScoresJoinedUserName, error) {
 	query := `
 		SELECT user_scores.user_id, user.name, user_scores.score, user_scores.created_at
 		FROM user_scores
 		JOIN user ON user_scores.user_id = user.id
+		WHERE user.status <> 'banned'
 		ORDER BY user_scores.score DESC, user_scores.user_id ASC, user_scores.created_at DESC
 		LIMIT ? OFFSET ?`
 
@@ -73,3 +76,34 @@ func (r *userScoresRepository) GetUserScoreWithUserName(offset int64, limit enti
 	}
 	return &userScoresJoinedUserName, nil
 }
+
+// ユーザのスコアの記録を、afterIDより後のものから古い順にlimit件取得する
+func (r *userScoresRepository) GetUserScoresAfter(userID entities.UserID, afterID int64, limit int) ([]entities.ExportedScore, error) {
+	query := "SELECT id, score, created_at FROM user_scores WHERE user_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []entities.ExportedScore{}
+	for rows.Next() {
+		var score entities.ExportedScore
+		var createdAt []byte
+		if err := rows.Scan(&score.ID, &score.Score, &createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if score.CreatedAt, err = parseDatetime(createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		scores = append(scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	return scores, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"42tokyo-road-to-dojo-go/pkg/cache"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+type UserStatusRepository interface {
+	GetUserStatus(userID entities.UserID) (*entities.UserAccountStatus, error)
+	UpdateUserStatus(status *entities.UserAccountStatus) error
+	DeleteUserStatusCache(userID entities.UserID) error
+}
+
+const (
+	// Redisにアカウントの状態を保持する期間
+	userStatusCacheTTL = time.Minute
+	// プロセス内にアカウントの状態を保持する期間と件数。他のサーバで変更した状態はこの期間が過ぎると反映される
+	userStatusLocalCacheTTL  = 10 * time.Second
+	userStatusLocalCacheSize = 10000
+)
+
+func NewUserStatusRepository(db *sql.DB, rdb *redis.Client) UserStatusRepository {
+	return &userStatusRepository{db, rdb, cache.NewLRU[entities.UserID, entities.UserAccountStatus](userStatusLocalCacheSize, userStatusLocalCacheTTL)}
+}
+
+type userStatusRepository struct {
+	db    *sql.DB
+	rdb   *redis.Client
+	local *cache.LRU[entities.UserID, entities.UserAccountStatus]
+}
+
+func userStatusCacheKey(userID entities.UserID) string {
+	return fmt.Sprintf("user_status:%d", userID)
+}
+
+// GetUserStatus ユーザのアカウントの状態を取得する。認証のたびに呼ばれるため、プロセス内のキャッシュ、Redis、DBの順に探す
+// Redisに接続できない場合もDBから取得する
+func (r *userStatusRepository) GetUserStatus(userID entities.UserID) (*entities.UserAccountStatus, error) {
+	if status, ok := r.local.Get(userID); ok {
+		return &status, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	statusJson, err := r.rdb.Get(ctx, userStatusCacheKey(userID)).Bytes()
+	switch {
+	case err == nil:
+		var status entities.UserAccountStatus
+		if err := json.Unmarshal(statusJson, &status); err == nil {
+			r.local.Set(userID, status)
+			return &status, nil
+		}
+		log.Println(err)
+	case !errors.Is(err, redis.Nil):
+		log.Println(err)
+	}
+
+	query := "SELECT status, status_reason, suspended_until, status_updated_at FROM user WHERE id = ? LIMIT 1"
+	status := entities.UserAccountStatus{UserID: userID}
+	var reason, suspendedUntil, updatedAt sql.NullString
+	if err := r.db.QueryRow(query, userID).Scan(&status.Status, &reason, &suspendedUntil, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		log.Println(err)
+		return nil, err
+	}
+	status.Reason = reason.String
+	if status.SuspendedUntil, err = parseNullDatetime(suspendedUntil); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	if status.UpdatedAt, err = parseNullDatetime(updatedAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	r.local.Set(userID, status)
+	if statusJson, err := json.Marshal(&status); err != nil {
+		log.Println(err)
+	} else if err := r.rdb.Set(ctx, userStatusCacheKey(userID), statusJson, userStatusCacheTTL).Err(); err != nil {
+		log.Println(err)
+	}
+	return &status, nil
+}
+
+// UpdateUserStatus ユーザのアカウントの状態を更新し、キャッシュから削除する
+// 他のサーバのプロセス内のキャッシュはuserStatusLocalCacheTTLが過ぎるまで古い状態を返す
+func (r *userStatusRepository) UpdateUserStatus(status *entities.UserAccountStatus) error {
+	var reason *string
+	if status.Reason != "" {
+		reason = &status.Reason
+	}
+	var suspendedUntil *time.Time
+	if status.SuspendedUntil != nil {
+		until := status.SuspendedUntil.UTC()
+		suspendedUntil = &until
+	}
+	updatedAt := time.Now()
+	query := "UPDATE user SET status = ?, status_reason = ?, suspended_until = ?, status_updated_at = ? WHERE id = ? AND deleted_at IS NULL"
+	rows, err := execQueryAndReturnAffectedRows(r.db, query, status.Status, reason, suspendedUntil, updatedAt.UTC(), status.UserID)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// 同じ秒に同じ内容で更新した場合も0件になるため、ユーザが存在するか確認する
+		var exists bool
+		if err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM user WHERE id = ? AND deleted_at IS NULL)", status.UserID).Scan(&exists); err != nil {
+			log.Println(err)
+			return err
+		}
+		if !exists {
+			return ErrUserNotFound
+		}
+	}
+	status.UpdatedAt = &updatedAt
+
+	// 削除に失敗してもuserStatusCacheTTLが過ぎれば反映されるため、更新は成功として扱う
+	_ = r.DeleteUserStatusCache(status.UserID)
+	return nil
+}
+
+// DeleteUserStatusCache アカウントの状態をキャッシュから削除する。状態を変更したトランザクションのコミット後に呼び出すこと
+func (r *userStatusRepository) DeleteUserStatusCache(userID entities.UserID) error {
+	r.local.Delete(userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+	if err := r.rdb.Del(ctx, userStatusCacheKey(userID)).Err(); err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
@@ -2,7 +2,11 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 type queryExecuter interface {
@@ -28,3 +32,32 @@ func execQueryAndReturnAffectedRows(db queryExecuter, query string, args ...inte
 
 	return rows, nil
 }
+
+// MySQLのDATETIME/TIMESTAMP型の文字列をtime.Timeに変換する
+func parseDatetime(b []byte) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05", string(b))
+}
+
+// NULLを許容するDATETIME/TIMESTAMP型の文字列を*time.Timeに変換する
+func parseNullDatetime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// 重複キーエラー(ER_DUP_ENTRY)かどうかを判定する
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// 外部キー制約により削除できないエラー(ER_ROW_IS_REFERENCED_2)かどうかを判定する
+func isForeignKeyViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1451
+}
@@ -0,0 +1,41 @@
+package entities
+
+import "time"
+
+type (
+	AuthTokenID int64
+
+	// AuthTokenRecord 発行した認証用トークンの記録。トークンそのものは保存せず、ハッシュで照合する
+	// ExpiresAtを過ぎたトークンも、RefreshExpiresAtまでは新しいトークンに交換できる
+	AuthTokenRecord struct {
+		ID               AuthTokenID
+		UserID           UserID
+		SessionID        SessionID
+		TokenHash        string
+		IssuedAt         time.Time
+		ExpiresAt        time.Time
+		RefreshExpiresAt time.Time
+		RevokedAt        *time.Time
+	}
+
+	// IssuedAuthToken クライアントに返す新しいトークン
+	IssuedAuthToken struct {
+		SessionID        SessionID `json:"sessionId"`
+		Token            AuthToken `json:"token"`
+		ExpiresAt        time.Time `json:"expiresAt"`
+		RefreshExpiresAt time.Time `json:"refreshExpiresAt"`
+	}
+)
+
+func (t *AuthTokenRecord) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t *AuthTokenRecord) IsExpired(at time.Time) bool {
+	return !at.Before(t.ExpiresAt)
+}
+
+// IsRefreshable 新しいトークンに交換できるか判定する
+func (t *AuthTokenRecord) IsRefreshable(at time.Time) bool {
+	return !t.IsRevoked() && at.Before(t.RefreshExpiresAt)
+}
@@ -0,0 +1,34 @@
+package entities
+
+import "time"
+
+const (
+	CoinLedgerReasonGameFinish CoinLedgerReason = "game_finish"
+	CoinLedgerReasonGacha      CoinLedgerReason = "gacha"
+	CoinLedgerReasonEnhance    CoinLedgerReason = "enhance"
+	CoinLedgerReasonCollection CoinLedgerReason = "collection_reward"
+	CoinLedgerReasonTrade      CoinLedgerReason = "trade"
+	CoinLedgerReasonMarketBuy  CoinLedgerReason = "market_purchase"
+	CoinLedgerReasonMarketSell CoinLedgerReason = "market_sale"
+	CoinLedgerReasonPresent    CoinLedgerReason = "present"
+	CoinLedgerReasonCoupon     CoinLedgerReason = "coupon"
+	CoinLedgerReasonShop       CoinLedgerReason = "shop"
+	CoinLedgerReasonPurchase   CoinLedgerReason = "purchase"
+	CoinLedgerReasonRefund     CoinLedgerReason = "refund"
+)
+
+type (
+	CoinLedgerID     int64
+	CoinLedgerReason string
+
+	// CoinLedgerEntry コインの増減の記録。消費はAmountが負になる
+	// 有償コインと無償コインの両方を消費した場合は通貨ごとに記録する。Currencyが空の場合は無償コイン
+	CoinLedgerEntry struct {
+		ID        CoinLedgerID     `json:"id"`
+		UserID    UserID           `json:"userId"`
+		Amount    Coin             `json:"amount"`
+		Currency  Currency         `json:"currency"`
+		Reason    CoinLedgerReason `json:"reason"`
+		CreatedAt time.Time        `json:"createdAt"`
+	}
+)
@@ -0,0 +1,118 @@
+package entities
+
+import "time"
+
+type (
+	CollectionMilestoneID int64
+
+	// CollectionMilestone コレクションの達成報酬
+	// SeriesIDとRarityで対象のアイテムを絞り込み(未指定の場合は全アイテム)、
+	// そのうちThresholdPercent%以上を所持すると達成となる
+	CollectionMilestone struct {
+		ID               CollectionMilestoneID `json:"id"`
+		Name             string                `json:"name"`
+		SeriesID         *ItemSeriesID         `json:"seriesId,omitempty"`
+		Rarity           *Rarity               `json:"rarity,omitempty"`
+		ThresholdPercent int64                 `json:"thresholdPercent"`
+		RewardCoin       Coin                  `json:"rewardCoin"`
+		RewardItemID     *ItemID               `json:"rewardItemId,omitempty"`
+		RewardItemCount  ItemCount             `json:"rewardItemCount"`
+	}
+
+	CollectionMilestones []CollectionMilestone
+
+	// CollectionCount 所持しているアイテムの種類数と全種類数
+	CollectionCount struct {
+		Owned int64 `json:"owned"`
+		Total int64 `json:"total"`
+	}
+
+	CollectionRarityProgress struct {
+		Rarity Rarity `json:"rarity"`
+		CollectionCount
+	}
+
+	CollectionSeriesProgress struct {
+		SeriesID ItemSeriesID `json:"seriesId"`
+		CollectionCount
+	}
+
+	CollectionMilestoneProgress struct {
+		CollectionMilestone
+		Achieved   bool       `json:"achieved"`
+		AchievedAt *time.Time `json:"achievedAt,omitempty"`
+	}
+
+	// CollectionProgress コレクションの達成状況
+	CollectionProgress struct {
+		CollectionCount
+		ByRarity   []CollectionRarityProgress    `json:"byRarity"`
+		BySeries   []CollectionSeriesProgress    `json:"bySeries"`
+		Milestones []CollectionMilestoneProgress `json:"milestones"`
+	}
+
+	// collectionKey 達成判定の集計単位
+	collectionKey struct {
+		seriesID ItemSeriesID // 0の場合は全シリーズ
+		rarity   Rarity       // 0の場合は全レアリティ
+	}
+
+	// CollectionSummary 達成判定のためのアイテムの所持状況の集計
+	CollectionSummary struct {
+		All      CollectionCount
+		ByRarity map[Rarity]CollectionCount
+		BySeries map[ItemSeriesID]CollectionCount
+		counts   map[collectionKey]CollectionCount
+	}
+)
+
+// NewCollectionSummary アイテム一覧と所持アイテムから所持状況を集計する
+func NewCollectionSummary(items Items, owned map[ItemID]bool) *CollectionSummary {
+	summary := &CollectionSummary{
+		ByRarity: make(map[Rarity]CollectionCount),
+		BySeries: make(map[ItemSeriesID]CollectionCount),
+		counts:   make(map[collectionKey]CollectionCount),
+	}
+	for _, item := range items {
+		keys := []collectionKey{{}, {rarity: item.Rarity}}
+		if item.SeriesID != nil {
+			keys = append(keys, collectionKey{seriesID: *item.SeriesID}, collectionKey{seriesID: *item.SeriesID, rarity: item.Rarity})
+		}
+		for _, key := range keys {
+			count := summary.counts[key]
+			count.Total++
+			if owned[item.ID] {
+				count.Owned++
+			}
+			summary.counts[key] = count
+		}
+	}
+
+	for key, count := range summary.counts {
+		switch {
+		case key.seriesID == 0 && key.rarity == 0:
+			summary.All = count
+		case key.seriesID == 0:
+			summary.ByRarity[key.rarity] = count
+		case key.rarity == 0:
+			summary.BySeries[key.seriesID] = count
+		}
+	}
+	return summary
+}
+
+// IsAchieved 所持状況が達成条件を満たしているか判定する
+func (m *CollectionMilestone) IsAchieved(summary *CollectionSummary) bool {
+	var key collectionKey
+	if m.SeriesID != nil {
+		key.seriesID = *m.SeriesID
+	}
+	if m.Rarity != nil {
+		key.rarity = *m.Rarity
+	}
+	count := summary.counts[key]
+	if count.Total == 0 {
+		return false
+	}
+	return count.Owned*100 >= m.ThresholdPercent*count.Total
+}
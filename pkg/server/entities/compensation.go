@@ -0,0 +1,51 @@
+package entities
+
+import "time"
+
+// 補填の状態
+const (
+	CompensationStatusPending   CompensationStatus = "pending"   // 作成済みで未実行
+	CompensationStatusRunning   CompensationStatus = "running"   // 実行中
+	CompensationStatusCompleted CompensationStatus = "completed" // 全対象者に送付済み
+	CompensationStatusFailed    CompensationStatus = "failed"    // 途中で失敗。再開できる
+)
+
+type (
+	CompensationID     int64
+	CompensationStatus string
+
+	// CompensationTarget 補填の対象者の条件
+	// UserIDsを指定した場合はそのユーザのみ、指定しない場合は作成日時で絞り込んだ全ユーザを対象とする
+	// 誤って全ユーザに送らないよう、条件を何も指定しない場合はAllUsersをtrueにする必要がある
+	CompensationTarget struct {
+		CreatedBefore *time.Time `json:"createdBefore,omitempty"`
+		CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+		UserIDs       []UserID   `json:"userIds,omitempty"`
+		AllUsers      bool       `json:"allUsers,omitempty"`
+	}
+
+	// Compensation 運営からの一括補填。対象者のプレゼントボックスにプレゼントとして送る
+	// ユーザID順にバッチで送付し、送付済みの最後のユーザIDを記録して途中から再開できるようにする
+	// 誰がいつ何を送ったかの監査記録も兼ねる
+	Compensation struct {
+		ID               CompensationID     `json:"compensationId"`
+		Operator         string             `json:"operator"`
+		Reason           string             `json:"reason"`
+		Reward           Reward             `json:"reward"`
+		Message          string             `json:"message"`
+		PresentExpiresAt *time.Time         `json:"presentExpiresAt,omitempty"`
+		Target           CompensationTarget `json:"target"`
+		Status           CompensationStatus `json:"status"`
+		TargetCount      int64              `json:"targetCount"`
+		GrantedCount     int64              `json:"grantedCount"`
+		LastUserID       UserID             `json:"lastUserId"`
+		LastError        string             `json:"lastError,omitempty"`
+		CreatedAt        time.Time          `json:"createdAt"`
+		CompletedAt      *time.Time         `json:"completedAt,omitempty"`
+	}
+)
+
+// IsEmpty 対象者の条件が何も指定されていないか判定する
+func (target *CompensationTarget) IsEmpty() bool {
+	return target.CreatedBefore == nil && target.CreatedAfter == nil && len(target.UserIDs) == 0
+}
@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// クーポンの種類
+const (
+	CouponKindSingle CouponKind = "single" // コードごとに1回だけ使える。キャンペーンごとに大量のコードを発行する
+	CouponKindShared CouponKind = "shared" // 1つのコードを複数のユーザが使える
+)
+
+type (
+	CouponCampaignID int64
+	CouponKind       string
+
+	// CouponCampaign シリアルコードのキャンペーン。同じキャンペーンのコードは同じ報酬を付与する
+	// PerUserLimitは1ユーザがキャンペーン内で使える回数、TotalLimitは共有コードの合計の使用回数の上限(0の場合は上限なし)
+	// StartsAt, EndsAtがnilの場合は期間の制限なし
+	CouponCampaign struct {
+		ID           CouponCampaignID `json:"campaignId"`
+		Name         string           `json:"name"`
+		Kind         CouponKind       `json:"kind"`
+		Reward       Reward           `json:"reward"`
+		PerUserLimit int64            `json:"perUserLimit"`
+		TotalLimit   int64            `json:"totalLimit"`
+		StartsAt     *time.Time       `json:"startsAt,omitempty"`
+		EndsAt       *time.Time       `json:"endsAt,omitempty"`
+		CreatedAt    time.Time        `json:"createdAt"`
+	}
+
+	// CouponCode 発行したシリアルコード。Codeは正規化した値で保存する
+	CouponCode struct {
+		Code          string           `json:"code"`
+		CampaignID    CouponCampaignID `json:"campaignId"`
+		RedeemedCount int64            `json:"redeemedCount"`
+	}
+
+	// CouponRedeemResult クーポンで付与した報酬と、受け取ったアイテムで達成したコレクションの達成報酬
+	CouponRedeemResult struct {
+		CampaignName       string               `json:"campaignName"`
+		Reward             Reward               `json:"reward"`
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+	}
+)
+
+func (kind CouponKind) IsValid() bool {
+	return kind == CouponKindSingle || kind == CouponKindShared
+}
+
+// IsStarted 指定した日時に開始しているか判定する
+func (campaign *CouponCampaign) IsStarted(at time.Time) bool {
+	return campaign.StartsAt == nil || !at.Before(*campaign.StartsAt)
+}
+
+// IsEnded 指定した日時に終了しているか判定する
+func (campaign *CouponCampaign) IsEnded(at time.Time) bool {
+	return campaign.EndsAt != nil && !at.Before(*campaign.EndsAt)
+}
+
+// NormalizeCouponCode 入力されたコードから区切りの"-"と空白を取り除き、大文字に揃える
+func NormalizeCouponCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
@@ -0,0 +1,66 @@
+package entities
+
+import "time"
+
+// コインの通貨の種類。資金決済法に基づき、購入した有償コインと無償コインを区別して管理する
+const (
+	CurrencyFree Currency = "free" // ゲームプレイや報酬で獲得した無償コイン
+	CurrencyPaid Currency = "paid" // 購入した有償コイン
+)
+
+// 有償コインと無償コインのどちらから先に消費するか
+const (
+	SpendOrderFreeFirst SpendOrder = "free-first"
+	SpendOrderPaidFirst SpendOrder = "paid-first"
+)
+
+type (
+	Currency   string
+	SpendOrder string
+
+	// CoinSpend 消費するコインの通貨ごとの内訳
+	CoinSpend struct {
+		Free Coin `json:"free"`
+		Paid Coin `json:"paid"`
+	}
+
+	// CurrencyReport 有償コインの発行・消費と未使用残高の集計
+	// 期間[From, To)の発行額・消費額と、Toの時点の未使用残高をcoin_ledgerから集計する
+	// Current*は集計時点のuserテーブルの残高
+	CurrencyReport struct {
+		From               *time.Time `json:"from,omitempty"`
+		To                 time.Time  `json:"to"`
+		PaidIssued         Coin       `json:"paidIssued"`
+		PaidSpent          Coin       `json:"paidSpent"`
+		PaidOutstanding    Coin       `json:"paidOutstanding"`
+		CurrentPaidBalance Coin       `json:"currentPaidBalance"`
+		CurrentFreeBalance Coin       `json:"currentFreeBalance"`
+		PaidHolders        int64      `json:"paidHolders"`
+	}
+)
+
+func (order SpendOrder) IsValid() bool {
+	return order == SpendOrderFreeFirst || order == SpendOrderPaidFirst
+}
+
+// Total 消費するコインの合計
+func (spend CoinSpend) Total() Coin {
+	return spend.Free + spend.Paid
+}
+
+// SplitCoinSpend 所持コインからamountを消費する場合の通貨ごとの内訳を求める
+// 残高が足りない場合はokがfalseになる
+// 返金で一方の残高が負になっている場合は、その通貨からは消費せず、不足分は合計の残高から差し引いて扱う
+func (user *User) SplitCoinSpend(amount Coin, order SpendOrder) (spend CoinSpend, ok bool) {
+	if amount > user.TotalCoin() {
+		return CoinSpend{}, false
+	}
+	if order == SpendOrderPaidFirst {
+		spend.Paid = min(amount, max(user.PaidCoin, 0))
+		spend.Free = amount - spend.Paid
+	} else {
+		spend.Free = min(amount, max(user.Coin, 0))
+		spend.Paid = amount - spend.Free
+	}
+	return spend, true
+}
@@ -0,0 +1,42 @@
+package entities
+
+import "testing"
+
+func TestSplitCoinSpend(t *testing.T) {
+	tests := []struct {
+		name   string
+		free   Coin
+		paid   Coin
+		amount Coin
+		order  SpendOrder
+		want   CoinSpend
+		wantOk bool
+	}{
+		{name: "無償コインから消費", free: 100, paid: 100, amount: 30, order: SpendOrderFreeFirst, want: CoinSpend{Free: 30}, wantOk: true},
+		{name: "有償コインから消費", free: 100, paid: 100, amount: 30, order: SpendOrderPaidFirst, want: CoinSpend{Paid: 30}, wantOk: true},
+		{name: "無償コインの不足分を有償コインから消費", free: 20, paid: 100, amount: 50, order: SpendOrderFreeFirst, want: CoinSpend{Free: 20, Paid: 30}, wantOk: true},
+		{name: "有償コインの不足分を無償コインから消費", free: 100, paid: 20, amount: 50, order: SpendOrderPaidFirst, want: CoinSpend{Free: 30, Paid: 20}, wantOk: true},
+		{name: "残高ちょうど", free: 20, paid: 30, amount: 50, order: SpendOrderFreeFirst, want: CoinSpend{Free: 20, Paid: 30}, wantOk: true},
+		{name: "残高が足りない", free: 20, paid: 30, amount: 51, order: SpendOrderFreeFirst, wantOk: false},
+		{name: "0コイン", free: 0, paid: 0, amount: 0, order: SpendOrderPaidFirst, want: CoinSpend{}, wantOk: true},
+		// 返金で負になった通貨からは消費せず、不足分は合計の残高から差し引く
+		{name: "有償コインが負で有償コインから消費", free: 100, paid: -30, amount: 50, order: SpendOrderPaidFirst, want: CoinSpend{Free: 50}, wantOk: true},
+		{name: "無償コインが負で無償コインから消費", free: -30, paid: 100, amount: 50, order: SpendOrderFreeFirst, want: CoinSpend{Paid: 50}, wantOk: true},
+		{name: "負の残高を差し引くと足りない", free: 100, paid: -30, amount: 80, order: SpendOrderFreeFirst, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Coin: tt.free, PaidCoin: tt.paid}
+			got, ok := user.SplitCoinSpend(tt.amount, tt.order)
+			if ok != tt.wantOk {
+				t.Fatalf("SplitCoinSpend() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("SplitCoinSpend() = %+v, want %+v", got, tt.want)
+			}
+			if ok && got.Total() != tt.amount {
+				t.Errorf("Total() = %d, want %d", got.Total(), tt.amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package entities
+
+import "time"
+
+const (
+	// DataExportFormatJSON 全てのデータを1つのJSONにまとめる
+	DataExportFormatJSON DataExportFormat = "json"
+	// DataExportFormatZip 種類ごとのJSON Linesのファイルをzipにまとめる
+	DataExportFormatZip DataExportFormat = "zip"
+
+	DataExportStatusPending    DataExportStatus = "pending"
+	DataExportStatusReady      DataExportStatus = "ready"
+	DataExportStatusFailed     DataExportStatus = "failed"
+	DataExportStatusDownloaded DataExportStatus = "downloaded"
+	DataExportStatusExpired    DataExportStatus = "expired"
+)
+
+type (
+	DataExportID     int64
+	DataExportFormat string
+	DataExportStatus string
+
+	// DataExport ユーザの個人データのエクスポート。作成したファイルは1回だけダウンロードできる
+	DataExport struct {
+		ID     DataExportID     `json:"exportId"`
+		UserID UserID           `json:"userId"`
+		Format DataExportFormat `json:"format"`
+		Status DataExportStatus `json:"status"`
+		// 作成したファイルのパス。ダウンロードできる場合のみ設定する
+		FilePath string `json:"-"`
+		// 作成に失敗した理由。失敗した場合のみ設定する
+		Error       string     `json:"error,omitempty"`
+		CreatedAt   time.Time  `json:"createdAt"`
+		CompletedAt *time.Time `json:"completedAt,omitempty"`
+		// ダウンロードできる期限。作成が完了した場合のみ設定する
+		ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+		DownloadedAt *time.Time `json:"downloadedAt,omitempty"`
+	}
+
+	// ExportedScore エクスポートするスコアの記録
+	ExportedScore struct {
+		ID        int64     `json:"id"`
+		Score     Score     `json:"score"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	// ExportedItem エクスポートする所持アイテム
+	ExportedItem struct {
+		ItemID ItemID    `json:"itemId"`
+		Count  ItemCount `json:"count"`
+		Level  ItemLevel `json:"level"`
+	}
+
+	// ExportedShopPurchase エクスポートするショップの商品ごとの購入数
+	ExportedShopPurchase struct {
+		OfferID ShopOfferID `json:"offerId"`
+		Count   int64       `json:"count"`
+	}
+
+	// ExportedCouponRedemption エクスポートするクーポンの使用履歴
+	ExportedCouponRedemption struct {
+		ID         int64            `json:"id"`
+		CampaignID CouponCampaignID `json:"campaignId"`
+		Code       string           `json:"code"`
+		CreatedAt  time.Time        `json:"createdAt"`
+	}
+
+	// ExportedSession エクスポートするログイン中の端末。失効したセッションも含める
+	ExportedSession struct {
+		ID         SessionID  `json:"sessionId"`
+		DeviceName string     `json:"deviceName"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		LastSeenAt time.Time  `json:"lastSeenAt"`
+		ExpiresAt  time.Time  `json:"expiresAt"`
+		RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	}
+
+	// ExportedTransferAuditLog エクスポートする引き継ぎの操作の記録
+	ExportedTransferAuditLog struct {
+		ID         int64               `json:"id"`
+		TransferID string              `json:"transferId"`
+		Action     TransferAuditAction `json:"action"`
+		IP         string              `json:"ip"`
+		CreatedAt  time.Time           `json:"createdAt"`
+	}
+
+	// ExportedCollectionMilestone エクスポートする達成したコレクションの達成報酬
+	ExportedCollectionMilestone struct {
+		MilestoneID CollectionMilestoneID `json:"milestoneId"`
+		AchievedAt  time.Time             `json:"achievedAt"`
+	}
+)
+
+// IsValid エクスポートの形式として指定できる値か判定する
+func (f DataExportFormat) IsValid() bool {
+	switch f {
+	case DataExportFormatJSON, DataExportFormatZip:
+		return true
+	default:
+		return false
+	}
+}
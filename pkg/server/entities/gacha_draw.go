@@ -0,0 +1,53 @@
+package entities
+
+import "time"
+
+type (
+	GachaSeedID int64
+	GachaDrawID int64
+
+	// GachaSeed 公平ガチャの期間ごとの秘密シード
+	// Seedは期間終了後にのみ公開する
+	GachaSeed struct {
+		ID       GachaSeedID `json:"seedId"`
+		SeedHash string      `json:"seedHash"`
+		Seed     string      `json:"seed,omitempty"`
+		StartsAt time.Time   `json:"startsAt"`
+		EndsAt   time.Time   `json:"endsAt"`
+	}
+
+	// GachaPoolEntry 抽選に使った排出対象のアイテム1件と重み
+	GachaPoolEntry struct {
+		ItemID ItemID `json:"itemId"`
+		Weight Weight `json:"weight"`
+	}
+
+	// GachaDraw ガチャ1回の実行記録
+	// 公平モードでない場合、SeedID・Nonce・Pool・PoolAtは空
+	GachaDraw struct {
+		ID            GachaDrawID   `json:"drawId"`
+		UserID        UserID        `json:"userId"`
+		SeedID        *GachaSeedID  `json:"seedId,omitempty"`
+		Nonce         string        `json:"nonce,omitempty"`
+		GameSettingID GameSettingID `json:"gameSettingId"`
+		Times         int64         `json:"times"`
+		ItemIDs       []ItemID      `json:"itemIds"`
+		// 抽選に使った排出対象(抽選順)と、排出対象を作った日時。マスターデータを変更しても検証できるように保存する
+		Pool      []GachaPoolEntry `json:"pool,omitempty"`
+		PoolAt    *time.Time       `json:"poolAt,omitempty"`
+		CreatedAt time.Time        `json:"createdAt"`
+	}
+
+	GachaVerifyResult struct {
+		DrawID   GachaDrawID `json:"drawId"`
+		SeedID   GachaSeedID `json:"seedId"`
+		Seed     string      `json:"seed"`
+		SeedHash string      `json:"seedHash"`
+		Nonce    string      `json:"nonce"`
+		// 再計算に使った抽選時の排出対象
+		Pool     []GachaPoolEntry `json:"pool"`
+		Expected []ItemID         `json:"expected"`
+		Actual   []ItemID         `json:"actual"`
+		Verified bool             `json:"verified"`
+	}
+)
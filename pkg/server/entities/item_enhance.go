@@ -0,0 +1,34 @@
+package entities
+
+type (
+	ItemLevel int64
+
+	// ItemEnhanceSetting レアリティごとのアイテム強化の設定
+	ItemEnhanceSetting struct {
+		Rarity         Rarity    `json:"rarity"`
+		MaxLevel       ItemLevel `json:"maxLevel"`
+		CopiesPerLevel ItemCount `json:"copiesPerLevel"` // 1レベル上げるのに消費する重複アイテム数
+		CoinPerLevel   Coin      `json:"coinPerLevel"`   // 1レベル上げるのに消費するコイン数
+	}
+
+	ItemEnhanceSettings []ItemEnhanceSetting
+
+	ItemEnhanceResult struct {
+		ID             ItemID    `json:"collectionID"`
+		Level          ItemLevel `json:"level"`
+		MaxLevel       ItemLevel `json:"maxLevel"`
+		Count          ItemCount `json:"count"`
+		ConsumedCopies ItemCount `json:"consumedCopies"`
+		ConsumedCoin   Coin      `json:"consumedCoin"`
+	}
+)
+
+// FindByRarity 指定したレアリティの強化設定を返す
+func (s ItemEnhanceSettings) FindByRarity(rarity Rarity) (*ItemEnhanceSetting, bool) {
+	for i := range s {
+		if s[i].Rarity == rarity {
+			return &s[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,23 @@
+package entities
+
+type (
+	ItemSeriesID   int64
+	ItemCategoryID int64
+
+	// ItemSeries アイテムのシリーズ(セット)
+	ItemSeries struct {
+		ID   ItemSeriesID `json:"id"`
+		Name string       `json:"name"`
+	}
+
+	// ItemCategory アイテムのカテゴリ
+	ItemCategory struct {
+		ID   ItemCategoryID `json:"id"`
+		Name string         `json:"name"`
+	}
+
+	ItemSeriesList struct {
+		Series     []ItemSeries   `json:"series"`
+		Categories []ItemCategory `json:"categories"`
+	}
+)
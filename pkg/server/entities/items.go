@@ -1,21 +1,43 @@
 package entities
 
+import "time"
+
 const (
 	N  Rarity = 1
 	R  Rarity = 2
 	SR Rarity = 3
 )
 
+// アイテムの状態
+const (
+	ItemStatusActive  ItemStatus = "active"  // ガチャから排出する
+	ItemStatusRetired ItemStatus = "retired" // ガチャから排出しないが、一覧には表示し、所持・強化できる
+	ItemStatusHidden  ItemStatus = "hidden"  // 一覧に表示せず、ガチャからも排出しない
+)
+
 type (
 	ItemID   int64
 	ItemName string
 	Rarity   int64 // 1: N, 2: R, 3: SR
 	HasItem  bool
 
+	ItemStatus string
+
 	Item struct {
-		ID     ItemID   `json:"collectionID"`
-		Name   ItemName `json:"name"`
-		Rarity Rarity   `json:"rarity"` // 1: N, 2: R, 3: SR
+		ID                   ItemID          `json:"collectionID"`
+		Name                 ItemName        `json:"name"`
+		Rarity               Rarity          `json:"rarity"` // 1: N, 2: R, 3: SR
+		SeriesID             *ItemSeriesID   `json:"seriesId,omitempty"`
+		CategoryID           *ItemCategoryID `json:"categoryId,omitempty"`
+		Description          string          `json:"description"`
+		IconAssetKey         string          `json:"iconAssetKey"`
+		IllustrationAssetKey string          `json:"illustrationAssetKey"`
+		FlavorText           string          `json:"flavorText"`
+		ReleasedAt           *time.Time      `json:"releasedAt,omitempty"`
+		RetiredAt            *time.Time      `json:"retiredAt,omitempty"` // この日時以降はガチャから排出しない
+		// trueの場合、ReleasedAtが設定されるまで未公開とする
+		HiddenUntilReleased bool       `json:"hiddenUntilReleased"`
+		Status              ItemStatus `json:"status"`
 	}
 
 	ItemWithWeight struct {
@@ -25,3 +47,57 @@ type (
 
 	Items []Item
 )
+
+// IsReleased 指定した日時に公開されているか判定する。未公開のアイテムは一覧に表示せず、ガチャからも排出しない
+// ReleasedAtが未設定の場合は、HiddenUntilReleasedでなければ公開済みとして扱う
+func (item *Item) IsReleased(at time.Time) bool {
+	if item.Status == ItemStatusHidden {
+		return false
+	}
+	if item.ReleasedAt == nil {
+		return !item.HiddenUntilReleased
+	}
+	return !at.Before(*item.ReleasedAt)
+}
+
+// IsDrawable 指定した日時にガチャから排出できるか判定する
+// 状態がactive以外のアイテムは排出しない。状態を持たない古いキャッシュはactiveとして扱う
+// 状態は履歴を持たず現在の値で判定するため、過去の抽選の再現には使えない。公平ガチャの検証には実行記録に保存した排出対象を使う
+func (item *Item) IsDrawable(at time.Time) bool {
+	if item.Status != ItemStatusActive && item.Status != "" {
+		return false
+	}
+	if !item.IsReleased(at) {
+		return false
+	}
+	return item.RetiredAt == nil || at.Before(*item.RetiredAt)
+}
+
+// Released 指定した日時に公開されているアイテムのみを返す
+func (items Items) Released(at time.Time) Items {
+	released := make(Items, 0, len(items))
+	for i := range items {
+		if items[i].IsReleased(at) {
+			released = append(released, items[i])
+		}
+	}
+	return released
+}
+
+// IsValid 定義済みの状態か判定する
+func (status ItemStatus) IsValid() bool {
+	switch status {
+	case ItemStatusActive, ItemStatusRetired, ItemStatusHidden:
+		return true
+	}
+	return false
+}
+
+// ByID アイテムをIDで引けるようにする
+func (items Items) ByID() map[ItemID]Item {
+	itemMap := make(map[ItemID]Item, len(items))
+	for _, item := range items {
+		itemMap[item.ID] = item
+	}
+	return itemMap
+}
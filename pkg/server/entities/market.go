@@ -0,0 +1,64 @@
+package entities
+
+import "time"
+
+// 出品の状態
+const (
+	MarketListingStatusActive   MarketListingStatus = "active"   // 出品中
+	MarketListingStatusSold     MarketListingStatus = "sold"     // 購入された
+	MarketListingStatusCanceled MarketListingStatus = "canceled" // 出品者が取り消した
+	MarketListingStatusExpired  MarketListingStatus = "expired"  // 期限切れ
+)
+
+type (
+	MarketListingID     int64
+	MarketListingStatus string
+
+	// MarketListing マーケットへの出品
+	// 出品したアイテムは出品者の所持数から引いて預かり、取り消し・期限切れの場合は出品者に返す
+	MarketListing struct {
+		ID        MarketListingID     `json:"listingId"`
+		SellerID  UserID              `json:"sellerId"`
+		ItemID    ItemID              `json:"collectionID"`
+		Count     ItemCount           `json:"count"`
+		Price     Coin                `json:"price"`
+		Fee       Coin                `json:"fee"` // 購入時に売上から差し引く手数料
+		Status    MarketListingStatus `json:"status"`
+		BuyerID   *UserID             `json:"buyerId,omitempty"`
+		ExpiresAt time.Time           `json:"expiresAt"`
+		CreatedAt time.Time           `json:"createdAt"`
+	}
+
+	// MarketListingFilter 出品一覧の絞り込み条件
+	MarketListingFilter struct {
+		ItemID   *ItemID
+		Rarity   *Rarity
+		SellerID *UserID
+		Status   *MarketListingStatus
+		// trueの場合、未公開のアイテムの出品を含めない
+		ReleasedOnly bool
+		SortBy       string // price(価格の安い順) または new(新しい順)
+		Limit        int
+		Offset       int
+	}
+
+	MarketListingList struct {
+		Listings []MarketListing `json:"listings"`
+	}
+
+	// MarketPurchaseResult 購入した出品と、購入したアイテムで達成したコレクションの達成報酬
+	MarketPurchaseResult struct {
+		Listing            MarketListing        `json:"listing"`
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+	}
+)
+
+// IsExpired 指定した日時に出品中のまま期限を過ぎているか判定する
+func (listing *MarketListing) IsExpired(at time.Time) bool {
+	return listing.Status == MarketListingStatusActive && !at.Before(listing.ExpiresAt)
+}
+
+// MarketFee 価格と手数料率(%)から手数料を計算する。1コイン未満は切り捨てる
+func MarketFee(price Coin, feePercent int64) Coin {
+	return price * Coin(feePercent) / 100
+}
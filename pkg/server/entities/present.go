@@ -0,0 +1,43 @@
+package entities
+
+import "time"
+
+// プレゼントの送り元
+const (
+	PresentSourceAdmin        PresentSource = "admin"
+	PresentSourceCompensation PresentSource = "compensation"
+	PresentSourceEvent        PresentSource = "event"
+)
+
+type (
+	PresentID     int64
+	PresentSource string
+
+	// Present プレゼントボックスに届いた報酬。受け取るまではユーザに付与しない
+	// ExpiresAtがnilの場合は期限なし
+	Present struct {
+		ID        PresentID     `json:"presentId"`
+		UserID    UserID        `json:"userId"`
+		Reward    Reward        `json:"reward"`
+		Message   string        `json:"message"`
+		Source    PresentSource `json:"source"`
+		ExpiresAt *time.Time    `json:"expiresAt,omitempty"`
+		ClaimedAt *time.Time    `json:"claimedAt,omitempty"`
+		CreatedAt time.Time     `json:"createdAt"`
+	}
+
+	PresentList struct {
+		Presents []Present `json:"presents"`
+	}
+
+	// PresentClaimResult 受け取ったプレゼントと、受け取ったアイテムで達成したコレクションの達成報酬
+	PresentClaimResult struct {
+		Presents           []Present            `json:"presents"`
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+	}
+)
+
+// IsExpired 指定した日時に期限を過ぎているか判定する
+func (present *Present) IsExpired(at time.Time) bool {
+	return present.ExpiresAt != nil && !at.Before(*present.ExpiresAt)
+}
@@ -0,0 +1,67 @@
+package entities
+
+import "time"
+
+// アプリ内課金のストア
+const (
+	PurchaseStoreApple  PurchaseStore = "apple"
+	PurchaseStoreGoogle PurchaseStore = "google"
+)
+
+// 購入の状態
+const (
+	PurchaseStatusCompleted PurchaseStatus = "completed" // コインを付与済み
+	PurchaseStatusRefunded  PurchaseStatus = "refunded"  // 返金され、付与したコインを取り消した
+)
+
+type (
+	PurchaseID     int64
+	PurchaseStore  string
+	PurchaseStatus string
+
+	// PurchaseProduct ストアで販売する商品。購入すると有償コインと、おまけの無償コインを付与する
+	PurchaseProduct struct {
+		ProductID string `json:"productId"`
+		Name      string `json:"name"`
+		PaidCoin  Coin   `json:"paidCoin"`
+		BonusCoin Coin   `json:"bonusCoin"`
+	}
+
+	PurchaseProductList struct {
+		Products []PurchaseProduct `json:"products"`
+	}
+
+	// Purchase ストアでの購入の記録。ストアの取引IDごとに1件だけ作成し、同じ取引で二重に付与しないようにする
+	// 返金時に残高が足りず負になった場合はNeedsSupportをtrueにして、サポートで対応する
+	Purchase struct {
+		ID            PurchaseID     `json:"purchaseId"`
+		UserID        UserID         `json:"userId"`
+		Store         PurchaseStore  `json:"store"`
+		TransactionID string         `json:"transactionId"`
+		ProductID     string         `json:"productId"`
+		PaidCoin      Coin           `json:"paidCoin"`
+		BonusCoin     Coin           `json:"bonusCoin"`
+		Status        PurchaseStatus `json:"status"`
+		NeedsSupport  bool           `json:"needsSupport"`
+		PurchasedAt   time.Time      `json:"purchasedAt"`
+		RefundedAt    *time.Time     `json:"refundedAt,omitempty"`
+		CreatedAt     time.Time      `json:"createdAt"`
+	}
+
+	PurchaseList struct {
+		Purchases []Purchase `json:"purchases"`
+	}
+
+	// PurchaseResult 購入の記録と付与後の所持コイン
+	// 同じ取引のレシートが再送された場合はAlreadyGrantedをtrueにして、付与済みの記録を返す
+	PurchaseResult struct {
+		Purchase       Purchase `json:"purchase"`
+		AlreadyGranted bool     `json:"alreadyGranted"`
+		Coin           Coin     `json:"coin"`
+		PaidCoin       Coin     `json:"paidCoin"`
+	}
+)
+
+func (store PurchaseStore) IsValid() bool {
+	return store == PurchaseStoreApple || store == PurchaseStoreGoogle
+}
@@ -0,0 +1,66 @@
+package entities
+
+import "errors"
+
+type (
+	// Reward ユーザに付与する報酬。コイン・アイテム・チケットを組み合わせられる
+	Reward struct {
+		Coin        Coin        `json:"coin"`
+		ItemID      *ItemID     `json:"collectionID,omitempty"`
+		ItemCount   ItemCount   `json:"itemCount"`
+		TicketType  *TicketType `json:"ticketType,omitempty"`
+		TicketCount TicketCount `json:"ticketCount"`
+	}
+)
+
+// IsEmpty 付与するものがないか判定する
+func (reward *Reward) IsEmpty() bool {
+	return reward.Coin == 0 && (reward.ItemID == nil || reward.ItemCount == 0) && (reward.TicketType == nil || reward.TicketCount == 0)
+}
+
+// ItemIDs 付与するアイテムのIDを個数分並べる
+func (reward *Reward) ItemIDs() []ItemID {
+	if reward.ItemID == nil {
+		return nil
+	}
+	itemIDs := make([]ItemID, reward.ItemCount)
+	for i := range itemIDs {
+		itemIDs[i] = *reward.ItemID
+	}
+	return itemIDs
+}
+
+// Validate 報酬の内容を検証する。アイテムはマスターデータに存在し、非公開でないものに限る
+func (reward *Reward) Validate(itemMap map[ItemID]Item) error {
+	if reward.Coin < 0 || reward.ItemCount < 0 || reward.TicketCount < 0 {
+		return errors.New("reward must not be negative")
+	}
+	if reward.ItemID != nil {
+		item, ok := itemMap[*reward.ItemID]
+		if !ok || item.Status == ItemStatusHidden {
+			return errors.New("item not found")
+		}
+	} else if reward.ItemCount > 0 {
+		return errors.New("collectionID is required for itemCount")
+	}
+	if reward.TicketType != nil {
+		if !reward.TicketType.IsValid() {
+			return errors.New("invalid ticket type")
+		}
+	} else if reward.TicketCount > 0 {
+		return errors.New("ticketType is required for ticketCount")
+	}
+	if reward.IsEmpty() {
+		return errors.New("reward is empty")
+	}
+	return nil
+}
+
+// Times 報酬をn回分まとめた報酬を返す
+func (reward *Reward) Times(n int64) Reward {
+	times := *reward
+	times.Coin *= Coin(n)
+	times.ItemCount *= ItemCount(n)
+	times.TicketCount *= TicketCount(n)
+	return times
+}
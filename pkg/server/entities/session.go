@@ -0,0 +1,25 @@
+package entities
+
+import "time"
+
+type (
+	SessionID int64
+
+	// Session 端末ごとのログイン。トークンを交換しても同じセッションが続き、失効させると端末からログアウトする
+	// ExpiresAtはトークンを交換できる期限で、交換するたびに延長する
+	Session struct {
+		ID         SessionID  `json:"sessionId"`
+		UserID     UserID     `json:"-"`
+		DeviceName string     `json:"deviceName"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		LastSeenAt time.Time  `json:"lastSeenAt"`
+		ExpiresAt  time.Time  `json:"expiresAt"`
+		RevokedAt  *time.Time `json:"-"`
+		// Current リクエストに使ったトークンのセッションか
+		Current bool `json:"current"`
+	}
+
+	SessionList struct {
+		Sessions []Session `json:"sessions"`
+	}
+)
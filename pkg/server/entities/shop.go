@@ -0,0 +1,80 @@
+package entities
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	ShopOfferID int64
+
+	// ShopOffer ショップでコインと引き換えに販売する商品。Rewardのアイテムとチケットを組み合わせてセット商品にできる
+	// PerUserLimitは1ユーザが購入できる個数(0の場合は制限なし)
+	// StartsAt, EndsAtがnilの場合は期間の制限なし
+	ShopOffer struct {
+		ID           ShopOfferID `json:"offerId"`
+		Name         string      `json:"name"`
+		Description  string      `json:"description"`
+		Price        Coin        `json:"price"`
+		Reward       Reward      `json:"reward"`
+		PerUserLimit int64       `json:"perUserLimit"`
+		StartsAt     *time.Time  `json:"startsAt,omitempty"`
+		EndsAt       *time.Time  `json:"endsAt,omitempty"`
+		SortOrder    int         `json:"sortOrder"`
+	}
+
+	ShopOffers []ShopOffer
+
+	// UserShopOffer ユーザから見た商品。購入済みの個数を含む
+	UserShopOffer struct {
+		ShopOffer
+		PurchasedCount int64 `json:"purchasedCount"`
+	}
+
+	ShopOfferList struct {
+		Offers []UserShopOffer `json:"offers"`
+	}
+
+	// ShopPurchaseResult 購入した商品と付与した報酬、消費したコインの内訳と購入後の所持コイン
+	ShopPurchaseResult struct {
+		Offer              ShopOffer            `json:"offer"`
+		Count              int64                `json:"count"`
+		Reward             Reward               `json:"reward"`
+		Spent              CoinSpend            `json:"spent"`
+		Coin               Coin                 `json:"coin"`
+		PaidCoin           Coin                 `json:"paidCoin"`
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+	}
+)
+
+// IsOnSale 指定した日時に販売期間内か判定する
+func (offer *ShopOffer) IsOnSale(at time.Time) bool {
+	if offer.StartsAt != nil && at.Before(*offer.StartsAt) {
+		return false
+	}
+	return offer.EndsAt == nil || at.Before(*offer.EndsAt)
+}
+
+// IsAvailable 指定した日時に販売期間内で、報酬のアイテムが公開済みか判定する
+func (offer *ShopOffer) IsAvailable(at time.Time, itemMap map[ItemID]Item) bool {
+	if !offer.IsOnSale(at) {
+		return false
+	}
+	if offer.Reward.ItemID != nil {
+		item, ok := itemMap[*offer.Reward.ItemID]
+		if !ok || !item.IsReleased(at) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sort 表示順(SortOrder, IDの昇順)に並べ替える
+func (offers ShopOffers) Sort() {
+	sort.Slice(offers, func(i, j int) bool {
+		if offers[i].SortOrder != offers[j].SortOrder {
+			return offers[i].SortOrder < offers[j].SortOrder
+		}
+		return offers[i].ID < offers[j].ID
+	})
+}
@@ -0,0 +1,11 @@
+package entities
+
+type (
+	AgeBracket string
+
+	// SpendingCap 年齢区分ごとのガチャの月間コイン消費上限
+	SpendingCap struct {
+		AgeBracket AgeBracket `json:"ageBracket"`
+		MonthlyCap Coin       `json:"monthlyCap"`
+	}
+)
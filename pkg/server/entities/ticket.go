@@ -0,0 +1,29 @@
+package entities
+
+const (
+	TicketTypeGacha TicketType = "gacha" // ガチャチケット
+)
+
+type (
+	TicketType  string
+	TicketCount int64
+
+	// UserTicket ユーザが所持するチケットの種類と枚数
+	UserTicket struct {
+		TicketType TicketType  `json:"ticketType"`
+		Count      TicketCount `json:"count"`
+	}
+
+	UserTicketList struct {
+		Tickets []UserTicket `json:"tickets"`
+	}
+)
+
+// IsValid 定義済みのチケットの種類か判定する
+func (ticketType TicketType) IsValid() bool {
+	switch ticketType {
+	case TicketTypeGacha:
+		return true
+	}
+	return false
+}
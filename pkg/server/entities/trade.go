@@ -0,0 +1,60 @@
+package entities
+
+import "time"
+
+// トレードの状態
+const (
+	TradeStatusPending  TradeStatus = "pending"  // 相手の応答待ち
+	TradeStatusAccepted TradeStatus = "accepted" // 成立
+	TradeStatusDeclined TradeStatus = "declined" // 相手が拒否
+	TradeStatusCanceled TradeStatus = "canceled" // 提案者が取り消し
+	TradeStatusExpired  TradeStatus = "expired"  // 期限切れ
+)
+
+// トレードで渡すアイテムがどちらのユーザのものか
+const (
+	TradeSideProposer TradeSide = "proposer"
+	TradeSideReceiver TradeSide = "receiver"
+)
+
+type (
+	TradeID     int64
+	TradeStatus string
+	TradeSide   string
+
+	// TradeItem トレードで渡すアイテムと個数
+	TradeItem struct {
+		ItemID ItemID    `json:"collectionID"`
+		Count  ItemCount `json:"count"`
+	}
+
+	// Trade ユーザ間のアイテム・コインのトレード
+	// Offer は提案者が渡すもの、Request は提案者が受け取るもの
+	Trade struct {
+		ID           TradeID     `json:"tradeId"`
+		ProposerID   UserID      `json:"proposerId"`
+		ReceiverID   UserID      `json:"receiverId"`
+		OfferItems   []TradeItem `json:"offerItems"`
+		OfferCoin    Coin        `json:"offerCoin"`
+		RequestItems []TradeItem `json:"requestItems"`
+		RequestCoin  Coin        `json:"requestCoin"`
+		Status       TradeStatus `json:"status"`
+		ExpiresAt    time.Time   `json:"expiresAt"`
+		CreatedAt    time.Time   `json:"createdAt"`
+	}
+
+	TradeList struct {
+		Trades []Trade `json:"trades"`
+	}
+
+	// TradeAcceptResult 成立したトレードと、受け取ったアイテムで達成したコレクションの達成報酬
+	TradeAcceptResult struct {
+		Trade              Trade                `json:"trade"`
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+	}
+)
+
+// IsExpired 指定した日時に応答待ちのまま期限を過ぎているか判定する
+func (trade *Trade) IsExpired(at time.Time) bool {
+	return trade.Status == TradeStatusPending && !at.Before(trade.ExpiresAt)
+}
@@ -0,0 +1,49 @@
+package entities
+
+import "time"
+
+// 引き継ぎの監査ログの操作
+const (
+	TransferAuditIssue   TransferAuditAction = "issue"   // 引き継ぎコードを発行した
+	TransferAuditRedeem  TransferAuditAction = "redeem"  // 引き継ぎに成功した
+	TransferAuditFailure TransferAuditAction = "failure" // IDまたはパスワードが誤っていた
+	TransferAuditLocked  TransferAuditAction = "locked"  // 失敗が続いたため受け付けなかった
+)
+
+type (
+	TransferAuditAction string
+
+	// TransferCode 機種変更用の引き継ぎIDとパスワード。ユーザごとに1件だけ保持し、使用すると削除する
+	// パスワードはソルト付きのハッシュのみを保存する
+	TransferCode struct {
+		UserID       UserID
+		TransferID   string
+		PasswordSalt string
+		PasswordHash string
+		CreatedAt    time.Time
+	}
+
+	// IssuedTransferCode 発行した引き継ぎIDとパスワード。パスワードは発行時にのみ返す
+	IssuedTransferCode struct {
+		TransferID string    `json:"transferId"`
+		Password   string    `json:"password"`
+		CreatedAt  time.Time `json:"createdAt"`
+	}
+
+	// TransferResult 引き継ぎ先の端末に発行したトークン
+	TransferResult struct {
+		UserID UserID `json:"userId"`
+		IssuedAuthToken
+	}
+
+	// TransferAuditLog 引き継ぎコードの発行と引き継ぎの試行の記録
+	// 存在しない引き継ぎIDの試行はUserIDがnilになる
+	TransferAuditLog struct {
+		ID         int64
+		UserID     *UserID
+		TransferID string
+		Action     TransferAuditAction
+		IP         string
+		CreatedAt  time.Time
+	}
+)
@@ -1,5 +1,7 @@
 package entities
 
+import "time"
+
 type (
 	UserID    int64
 	UserName  string
@@ -7,13 +9,23 @@ type (
 	AuthToken string
 
 	User struct {
-		ID        UserID    `json:"id"`
-		Name      UserName  `json:"name"`
-		HighScore Score     `json:"highScore"`
-		Coin      Coin      `json:"coin"`
-		AuthToken AuthToken `json:"-"`
+		ID        UserID   `json:"id"`
+		Name      UserName `json:"name"`
+		HighScore Score    `json:"highScore"`
+		// 無償コインの残高。有償コインの残高はPaidCoinで別に管理する
+		Coin     Coin `json:"coin"`
+		PaidCoin Coin `json:"paidCoin"`
+		// 未成年の場合の年齢区分。ガチャの月間コイン消費上限に用いる
+		AgeBracket *AgeBracket `json:"ageBracket,omitempty"`
+		// 削除を予約した場合に削除する日時。この日時までは削除を取り消せる
+		DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
 		// and more...
 	}
 
 	Users []User
 )
+
+// TotalCoin 有償コインと無償コインの合計
+func (user *User) TotalCoin() Coin {
+	return user.Coin + user.PaidCoin
+}
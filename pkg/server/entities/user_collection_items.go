@@ -1,15 +1,40 @@
 package entities
 
+import "time"
+
 type (
+	ItemCount int64
+
+	// UserItem ユーザが所持するアイテムと所持数、強化レベル
+	UserItem struct {
+		ItemID ItemID
+		Count  ItemCount
+		Level  ItemLevel
+	}
+
 	CollectionItem struct {
-		ID      ItemID   `json:"collectionID"`
-		Name    ItemName `json:"name"`
-		Rarity  Rarity   `json:"rarity"` // 1: N, 2: R, 3: SR
-		HasItem HasItem  `json:"hasItem"`
+		ID                   ItemID          `json:"collectionID"`
+		Name                 ItemName        `json:"name"`
+		Rarity               Rarity          `json:"rarity"` // 1: N, 2: R, 3: SR
+		SeriesID             *ItemSeriesID   `json:"seriesId,omitempty"`
+		CategoryID           *ItemCategoryID `json:"categoryId,omitempty"`
+		Description          string          `json:"description"`
+		IconAssetKey         string          `json:"iconAssetKey"`
+		IllustrationAssetKey string          `json:"illustrationAssetKey"`
+		FlavorText           string          `json:"flavorText"`
+		ReleasedAt           *time.Time      `json:"releasedAt,omitempty"`
+		RetiredAt            *time.Time      `json:"retiredAt,omitempty"`
+		HasItem              HasItem         `json:"hasItem"`
+		Count                ItemCount       `json:"count"`
+		Level                ItemLevel       `json:"level"` // 未所持の場合は0
+		MaxLevel             ItemLevel       `json:"maxLevel"`
 	}
 
 	CollectionItemList struct {
-		Items []CollectionItem `json:"collections"`
+		Items    []CollectionItem    `json:"collections"`
+		Progress *CollectionProgress `json:"progress"`
+		// 続きがある場合に、次のページを取得するためのカーソル
+		NextCursor string `json:"nextCursor,omitempty"`
 	}
 
 	GachaResult struct {
@@ -20,6 +45,13 @@ type (
 	}
 
 	GachaResultList struct {
-		Items []GachaResult `json:"results"`
+		Items  []GachaResult `json:"results"`
+		DrawID GachaDrawID   `json:"drawId"`
+		// 今回のガチャで達成したコレクションの達成報酬
+		AchievedMilestones CollectionMilestones `json:"achievedMilestones,omitempty"`
+		// 以下は公平モードで引いた場合のみ設定する
+		SeedID   *GachaSeedID `json:"seedId,omitempty"`
+		SeedHash string       `json:"seedHash,omitempty"`
+		Nonce    string       `json:"nonce,omitempty"`
 	}
 )
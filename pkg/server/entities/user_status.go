@@ -0,0 +1,42 @@
+package entities
+
+import "time"
+
+// UserStatus アカウントの状態
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+	UserStatusBanned    UserStatus = "banned"
+	// UserStatusDeleted 削除済み。ユーザの削除でのみ設定し、管理APIでは設定できない
+	UserStatusDeleted UserStatus = "deleted"
+)
+
+// IsValid 管理APIで設定できる状態か判定する
+func (s UserStatus) IsValid() bool {
+	switch s {
+	case UserStatusActive, UserStatusSuspended, UserStatusBanned:
+		return true
+	default:
+		return false
+	}
+}
+
+// UserAccountStatus アカウントの状態と、運営が停止・凍結した理由
+type UserAccountStatus struct {
+	UserID UserID     `json:"userId"`
+	Status UserStatus `json:"status"`
+	Reason string     `json:"reason,omitempty"`
+	// 一時停止が解除される日時。一時停止の場合のみ設定する
+	SuspendedUntil *time.Time `json:"suspendedUntil,omitempty"`
+	UpdatedAt      *time.Time `json:"updatedAt,omitempty"`
+}
+
+// EffectiveStatus 指定した日時での状態を返す。一時停止の期限を過ぎた場合は有効として扱う
+func (s *UserAccountStatus) EffectiveStatus(at time.Time) UserStatus {
+	if s.Status == UserStatusSuspended && s.SuspendedUntil != nil && !at.Before(*s.SuspendedUntil) {
+		return UserStatusActive
+	}
+	return s.Status
+}
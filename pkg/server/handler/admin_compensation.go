@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/compensation"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 補填の一覧で返す最大件数
+const maxCompensationListLimit = 100
+
+// 補填をバックグラウンドで送付する。失敗した場合は補填に記録され、再開APIで続きから送付できる
+// Processは進捗を書き換えるため、レスポンスに使う値とは別のコピーを渡す
+func processCompensationInBackground(runner *compensation.Runner, c *entities.Compensation) {
+	processing := *c
+	go func() {
+		if err := runner.Process(context.Background(), &processing, nil); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// 一括補填を作成して送付を開始する
+// 対象者は作成日時(target.createdBefore, target.createdAfter)またはユーザID(target.userIds)で指定し、
+// 全ユーザに送る場合はtarget.allUsersをtrueにする
+// dryRunをtrueにした場合は補填を作成せず、対象者の人数のみ返す
+func HandleAdminCompensationCreate(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type createRequest struct {
+			Operator         string                      `json:"operator"`
+			Reason           string                      `json:"reason"`
+			Reward           entities.Reward             `json:"reward"`
+			Message          string                      `json:"message"`
+			PresentExpiresAt *time.Time                  `json:"presentExpiresAt"`
+			Target           entities.CompensationTarget `json:"target"`
+			DryRun           bool                        `json:"dryRun"`
+		}
+		var req createRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		c := entities.Compensation{
+			Operator:         req.Operator,
+			Reason:           req.Reason,
+			Reward:           req.Reward,
+			Message:          req.Message,
+			PresentExpiresAt: req.PresentExpiresAt,
+			Target:           req.Target,
+		}
+
+		// validation
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := compensation.Validate(&c, *items); err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		runner := compensation.NewRunner(repos, compensation.DefaultBatchSize)
+		if req.DryRun {
+			count, err := runner.Count(&c.Target)
+			if err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusOK, map[string]int64{"targetCount": count})
+			return
+		}
+
+		if err := runner.Create(&c); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		started, err := runner.Start(c.ID)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		processCompensationInBackground(runner, started)
+
+		response.SetStatusAndJson(writer, http.StatusOK, started)
+	}
+}
+
+// 失敗した補填や、実行中のプロセスが止まった補填の送付を続きから再開する
+// 対象の補填IDをJSONで"compensationId": 1のように指定
+func HandleAdminCompensationResume(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type resumeRequest struct {
+			CompensationID entities.CompensationID `json:"compensationId"`
+		}
+		var req resumeRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		runner := compensation.NewRunner(repos, compensation.DefaultBatchSize)
+		started, err := runner.Start(req.CompensationID)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, repositories.ErrCompensationNotFound):
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+			case errors.Is(err, compensation.ErrNotRunnable):
+				response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+			default:
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		processCompensationInBackground(runner, started)
+
+		response.SetStatusAndJson(writer, http.StatusOK, started)
+	}
+}
+
+// 補填の内容と進捗を取得する id はクエリパラメータ
+func HandleAdminCompensationGet(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		// 入力の受け取り
+		id, err := strconv.ParseInt(request.URL.Query().Get("id"), 10, 64)
+		if err != nil || id < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "id must be a positive integer"})
+			return
+		}
+
+		c, err := repos.CompensationRepository.GetCompensationByID(entities.CompensationID(id))
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrCompensationNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, c)
+	}
+}
+
+// 補填の一覧を新しい順に取得する。誰がいつ何を送ったかの監査に使う
+func HandleAdminCompensationList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		compensations, err := repos.CompensationRepository.GetCompensations(maxCompensationListLimit)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, map[string][]entities.Compensation{"compensations": compensations})
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// アイテムの状態を変更する
+// 対象のコレクションIDと状態をJSONで{"collectionID": 1, "status": "retired"}のように指定
+// retiredにするとガチャから排出しなくなるが、一覧には表示され、所持しているアイテムはそのまま残る
+func HandleAdminItemStatusUpdate(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type statusRequest struct {
+			ItemID entities.ItemID     `json:"collectionID"`
+			Status entities.ItemStatus `json:"status"`
+		}
+		var req statusRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// validation
+		if !req.Status.IsValid() {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "status must be one of active, retired, hidden"})
+			return
+		}
+
+		item, err := repos.ItemRepository.UpdateItemStatusByID(req.ItemID, req.Status)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrItemNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, item)
+	}
+}
+
+// アイテムを削除する 対象のコレクションIDをJSONで"collectionID": 1のように指定
+// 所持しているユーザがいるアイテムは削除できないため、状態をretiredまたはhiddenに変更すること
+func HandleAdminItemDelete(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type deleteRequest struct {
+			ItemID entities.ItemID `json:"collectionID"`
+		}
+		var req deleteRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		err := repos.ItemRepository.DeleteItemByID(req.ItemID)
+		if err != nil {
+			log.Println(err)
+			switch {
+			case errors.Is(err, repositories.ErrItemNotFound):
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+			case errors.Is(err, repositories.ErrItemOwned), errors.Is(err, repositories.ErrItemInUse):
+				response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+			default:
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+
+		writer.WriteHeader(http.StatusOK)
+	}
+}
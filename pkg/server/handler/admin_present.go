@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// 1回で送れるプレゼントの最大ユーザ数
+	maxAdminPresentUsers = 1000
+	// 送り元の最大長
+	maxPresentSourceLength = 32
+)
+
+// 指定したユーザのプレゼントボックスにプレゼントを送る
+// 例: {"userIds": [1, 2], "reward": {"coin": 100, "collectionID": 1, "itemCount": 1}, "message": "メンテナンスのお詫び", "expiresAt": "2024-01-01T00:00:00Z"}
+func HandleAdminPresentSend(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type sendRequest struct {
+			UserIDs   []entities.UserID      `json:"userIds"`
+			Reward    entities.Reward        `json:"reward"`
+			Message   string                 `json:"message"`
+			Source    entities.PresentSource `json:"source"`
+			ExpiresAt *time.Time             `json:"expiresAt"`
+		}
+		var req sendRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// validation
+		if len(req.UserIDs) == 0 || len(req.UserIDs) > maxAdminPresentUsers {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "userIds must have between 1 and 1000 users"})
+			return
+		}
+		if req.Message == "" {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "message is required"})
+			return
+		}
+		if req.Source == "" {
+			req.Source = entities.PresentSourceAdmin
+		}
+		if len(req.Source) > maxPresentSourceLength {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "source is too long"})
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "expiresAt must be in the future"})
+			return
+		}
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := req.Reward.Validate(items.ByID()); err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		presents := make([]entities.Present, 0, len(req.UserIDs))
+		for _, userID := range req.UserIDs {
+			presents = append(presents, entities.Present{
+				UserID:    userID,
+				Reward:    req.Reward,
+				Message:   req.Message,
+				Source:    req.Source,
+				ExpiresAt: req.ExpiresAt,
+			})
+		}
+		if err := repos.PresentRepository.AddPresents(presents); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, map[string]int{"sent": len(presents)})
+	}
+}
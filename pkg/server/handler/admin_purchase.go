@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// サポートの対応が必要な購入の一覧で返す最大件数
+const maxFlaggedPurchaseListLimit = 100
+
+// 返金で残高が負になり、サポートの対応が必要な購入の一覧を古い順に取得する
+func HandleAdminGetFlaggedPurchases(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		purchases, err := repos.PurchaseRepository.GetPurchasesNeedingSupport(maxFlaggedPurchaseListLimit)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PurchaseList{Purchases: purchases})
+	}
+}
+
+// サポートの対応が済んだ購入を一覧から外す 対象の購入IDをJSONで"purchaseId": 1のように指定
+// 残高は変更しないため、必要な調整は補填などで別に行うこと
+func HandleAdminPurchaseResolve(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type resolveRequest struct {
+			PurchaseID entities.PurchaseID `json:"purchaseId"`
+		}
+		var req resolveRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := repos.PurchaseRepository.ResolvePurchaseSupport(req.PurchaseID); err != nil {
+			if errors.Is(err, repositories.ErrPurchaseNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": "purchase not found or not flagged"})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		writer.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/report"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+)
+
+// クエリパラメータのRFC3339形式の日時を解釈する。指定されていない場合はnilを返す
+func parseTimeQuery(request *http.Request, name string) (*time.Time, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// 有償コインの発行額・消費額と未使用残高を取得する
+// 期間を?from=2024-01-01T00:00:00%2B09:00&to=2024-04-01T00:00:00%2B09:00のようにRFC3339形式で指定し、toの時点の未使用残高を返す
+// 省略した場合は記録の最初から現在までを集計する
+func HandleAdminCurrencyReport(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		from, err := parseTimeQuery(request, "from")
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "from must be RFC3339"})
+			return
+		}
+		to, err := parseTimeQuery(request, "to")
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "to must be RFC3339"})
+			return
+		}
+
+		currencyReport, err := report.Currency(repos, from, to)
+		if err != nil {
+			if errors.Is(err, report.ErrInvalidPeriod) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, currencyReport)
+	}
+}
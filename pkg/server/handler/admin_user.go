@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// user.status_reasonのカラムの長さ
+const maxUserStatusReasonLength = 255
+
+// ユーザのアカウントの状態を取得する 対象のユーザを?userId=1のように指定
+func HandleAdminGetUserStatus(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		// 入力の受け取り
+		userID, err := strconv.ParseInt(request.URL.Query().Get("userId"), 10, 64)
+		if err != nil || userID < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "userId must be a positive integer"})
+			return
+		}
+
+		status, err := repos.UserStatusRepository.GetUserStatus(entities.UserID(userID))
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, status)
+	}
+}
+
+// ユーザのアカウントを一時停止・凍結・解除する
+// 対象のユーザと状態をJSONで"userId": 1, "status": "suspended", "reason": "...", "suspendedUntil": "2024-01-01T00:00:00Z"のように指定
+// 一時停止・凍結の場合は理由が必須で、一時停止の場合は解除日時も必須。解除(active)の場合は理由と解除日時を消す
+// 凍結されたユーザはランキングから除外するが、スコアの記録は削除しない
+func HandleAdminUserStatusUpdate(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type updateRequest struct {
+			UserID         entities.UserID     `json:"userId"`
+			Status         entities.UserStatus `json:"status"`
+			Reason         string              `json:"reason"`
+			SuspendedUntil *time.Time          `json:"suspendedUntil"`
+		}
+		var req updateRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		status, err := newUserAccountStatus(req.UserID, req.Status, req.Reason, req.SuspendedUntil, time.Now())
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := repos.UserStatusRepository.UpdateUserStatus(status); err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("user %d status changed to %s: %s", status.UserID, status.Status, status.Reason)
+
+		response.SetStatusAndJson(writer, http.StatusOK, status)
+	}
+}
+
+// 管理APIで指定されたアカウントの状態を検証する
+func newUserAccountStatus(userID entities.UserID, s entities.UserStatus, reason string, suspendedUntil *time.Time, now time.Time) (*entities.UserAccountStatus, error) {
+	if userID < 1 {
+		return nil, errors.New("userId must be a positive integer")
+	}
+	if !s.IsValid() {
+		return nil, errors.New("status must be active, suspended or banned")
+	}
+	status := entities.UserAccountStatus{UserID: userID, Status: s}
+	if s == entities.UserStatusActive {
+		return &status, nil
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+	if utf8.RuneCountInString(reason) > maxUserStatusReasonLength {
+		return nil, fmt.Errorf("reason must be at most %d characters", maxUserStatusReasonLength)
+	}
+	status.Reason = reason
+	if s == entities.UserStatusSuspended {
+		if suspendedUntil == nil || !suspendedUntil.After(now) {
+			return nil, errors.New("suspendedUntil must be in the future")
+		}
+		status.SuspendedUntil = suspendedUntil
+	}
+	return &status, nil
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// x-tokenヘッダのトークンを同じセッションの新しいトークンに交換する。古いトークンは失効する
+// 有効期限が切れたトークンも、発行から-token-refresh-ttlの期間内であれば交換できるため、middleware.Authenticateを通さない
+func HandleAuthRefresh(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		token := request.Header.Get("x-token")
+		if token == "" {
+			response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": "x-token header is required"})
+			return
+		}
+
+		issued, err := auth.Refresh(repos, conf, entities.AuthToken(token))
+		if err != nil {
+			if code := auth.ErrorCode(err); code != "" {
+				response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": err.Error(), "code": code})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, issued)
+	}
+}
+
+// ユーザのすべてのセッションを失効させ、すべての端末からログアウトする。リクエストに使ったセッションも失効する
+func HandleAuthLogoutAll(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		revoked, err := auth.RevokeAll(repos, userID)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, map[string]int64{"revokedCount": revoked})
+	}
+}
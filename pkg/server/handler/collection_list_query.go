@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// コレクション一覧の1ページあたりの最大件数
+const maxCollectionListLimit = 200
+
+// コレクション一覧の絞り込み・並び替え・ページングの条件
+// 各条件はキャッシュしたマスターデータに対してメモリ上で適用する
+type collectionListQuery struct {
+	SeriesID   *entities.ItemSeriesID
+	CategoryID *entities.ItemCategoryID
+	Rarity     *entities.Rarity
+	Owned      *bool
+	Sort       string // id, rarity, name のいずれか
+	Desc       bool
+	Limit      int // 0の場合は全件
+	Cursor     *collectionListCursor
+}
+
+// 前のページの最後のアイテムの並び替えキー
+type collectionListCursor struct {
+	Sort   string          `json:"s"`
+	Desc   bool            `json:"d"`
+	ID     entities.ItemID `json:"i"`
+	Rarity entities.Rarity `json:"r"`
+	Name   string          `json:"n"`
+}
+
+// クエリパラメータからコレクション一覧の条件を作成する
+// 例: ?series=1&rarity=3&owned=false&sort=-rarity&limit=50&cursor=...
+func parseCollectionListQuery(values url.Values) (*collectionListQuery, error) {
+	query := &collectionListQuery{Sort: "id"}
+
+	if v := values.Get("series"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("series must be a positive integer")
+		}
+		seriesID := entities.ItemSeriesID(id)
+		query.SeriesID = &seriesID
+	}
+	if v := values.Get("category"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("category must be a positive integer")
+		}
+		categoryID := entities.ItemCategoryID(id)
+		query.CategoryID = &categoryID
+	}
+	if v := values.Get("rarity"); v != "" {
+		r, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || r < int64(entities.N) || r > int64(entities.SR) {
+			return nil, fmt.Errorf("rarity must be 1, 2 or 3")
+		}
+		rarity := entities.Rarity(r)
+		query.Rarity = &rarity
+	}
+	if v := values.Get("owned"); v != "" {
+		owned, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("owned must be true or false")
+		}
+		query.Owned = &owned
+	}
+	if v := values.Get("sort"); v != "" {
+		query.Desc = strings.HasPrefix(v, "-")
+		query.Sort = strings.TrimPrefix(v, "-")
+		if query.Sort != "id" && query.Sort != "rarity" && query.Sort != "name" {
+			return nil, fmt.Errorf("sort must be one of id, rarity, name")
+		}
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxCollectionListLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxCollectionListLimit)
+		}
+		query.Limit = limit
+	}
+	if v := values.Get("cursor"); v != "" {
+		cursorJson, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		var cursor collectionListCursor
+		if err := json.Unmarshal(cursorJson, &cursor); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+			return nil, fmt.Errorf("cursor does not match sort")
+		}
+		query.Cursor = &cursor
+	}
+
+	return query, nil
+}
+
+// 並び替えキーで比較する。同じ値の場合はIDで比較する
+func (q *collectionListQuery) less(a, b *collectionListCursor) bool {
+	var cmp int
+	switch q.Sort {
+	case "rarity":
+		cmp = int(a.Rarity - b.Rarity)
+	case "name":
+		cmp = strings.Compare(a.Name, b.Name)
+	}
+	if cmp == 0 {
+		cmp = int(a.ID - b.ID)
+	}
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (q *collectionListQuery) cursorOf(item *entities.CollectionItem) *collectionListCursor {
+	return &collectionListCursor{Sort: q.Sort, Desc: q.Desc, ID: item.ID, Rarity: item.Rarity, Name: string(item.Name)}
+}
+
+// コレクション一覧を絞り込み・並び替えし、1ページ分と次のページのカーソルを返す
+func (q *collectionListQuery) apply(collectionItems []entities.CollectionItem) ([]entities.CollectionItem, string, error) {
+	filtered := make([]entities.CollectionItem, 0, len(collectionItems))
+	for _, item := range collectionItems {
+		if q.SeriesID != nil && (item.SeriesID == nil || *item.SeriesID != *q.SeriesID) {
+			continue
+		}
+		if q.CategoryID != nil && (item.CategoryID == nil || *item.CategoryID != *q.CategoryID) {
+			continue
+		}
+		if q.Rarity != nil && item.Rarity != *q.Rarity {
+			continue
+		}
+		if q.Owned != nil && bool(item.HasItem) != *q.Owned {
+			continue
+		}
+		if q.Cursor != nil && !q.less(q.Cursor, q.cursorOf(&item)) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return q.less(q.cursorOf(&filtered[i]), q.cursorOf(&filtered[j]))
+	})
+
+	if q.Limit == 0 || len(filtered) <= q.Limit {
+		return filtered, "", nil
+	}
+
+	page := filtered[:q.Limit]
+	cursorJson, err := json.Marshal(q.cursorOf(&page[len(page)-1]))
+	if err != nil {
+		return nil, "", err
+	}
+	return page, base64.RawURLEncoding.EncodeToString(cursorJson), nil
+}
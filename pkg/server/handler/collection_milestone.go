@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// コレクションの達成報酬をキャッシュから取得する。キャッシュからの取得に失敗した場合はDBから取得する。
+func getCollectionMilestones(repos *repositories.Repositories) (entities.CollectionMilestones, error) {
+	milestones, err := repos.CollectionMilestoneRepository.GetCollectionMilestonesFromCache()
+	if err != nil {
+		log.Println(err)
+		return repos.CollectionMilestoneRepository.GetCollectionMilestones()
+	}
+	return milestones, nil
+}
+
+// 所持アイテムによって新たに達成したコレクションの達成報酬を記録し、報酬を付与する。
+// ownedには今回獲得したアイテムを含めた所持アイテムを渡す。報酬のアイテムで達成した報酬も続けて付与する。
+// 達成の記録はユーザごとに1度しか成功しないため、同じ報酬が2度付与されることはない。
+func grantCollectionMilestonesTransaction(tx *sql.Tx, repos *repositories.Repositories, userID entities.UserID, items entities.Items, owned map[entities.ItemID]bool) (entities.CollectionMilestones, error) {
+	milestones, err := getCollectionMilestones(repos)
+	if err != nil {
+		return nil, err
+	}
+	if len(milestones) == 0 {
+		return nil, nil
+	}
+
+	achieved, err := repos.CollectionMilestoneRepository.GetAchievedMilestones(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var granted entities.CollectionMilestones
+	for {
+		summary := entities.NewCollectionSummary(items, owned)
+		progressed := false
+		for _, milestone := range milestones {
+			if _, ok := achieved[milestone.ID]; ok || !milestone.IsAchieved(summary) {
+				continue
+			}
+			achieved[milestone.ID] = time.Now()
+
+			inserted, err := repos.CollectionMilestoneRepository.AddAchievedMilestoneTransaction(tx, userID, milestone.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !inserted {
+				continue
+			}
+
+			// 報酬のコインを付与
+			if milestone.RewardCoin > 0 {
+				if err := repos.UserRepository.AddUserCoinsByIDTransaction(tx, userID, milestone.RewardCoin); err != nil {
+					return nil, err
+				}
+				err = repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entities.CoinLedgerEntry{
+					UserID: userID,
+					Amount: milestone.RewardCoin,
+					Reason: entities.CoinLedgerReasonCollection,
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			// 報酬のアイテムを付与
+			if milestone.RewardItemID != nil && milestone.RewardItemCount > 0 {
+				rewardItemIDs := make([]entities.ItemID, milestone.RewardItemCount)
+				for i := range rewardItemIDs {
+					rewardItemIDs[i] = *milestone.RewardItemID
+				}
+				if err := repos.CollectionItemRepository.AddCollectionItemsTransaction(tx, userID, rewardItemIDs); err != nil {
+					return nil, err
+				}
+				if !owned[*milestone.RewardItemID] {
+					owned[*milestone.RewardItemID] = true
+					progressed = true
+				}
+			}
+
+			granted = append(granted, milestone)
+		}
+
+		if !progressed {
+			return granted, nil
+		}
+	}
+}
+
+// トレードやマーケットで受け取ったアイテムによるコレクションの達成報酬を付与する。
+// 所持アイテムは確定済みのものを取得し、受け取ったアイテムを加えて判定する。
+func grantReceivedItemMilestonesTransaction(tx *sql.Tx, repos *repositories.Repositories, userID entities.UserID, items entities.Items, received []entities.ItemID) (entities.CollectionMilestones, error) {
+	if len(received) == 0 {
+		return nil, nil
+	}
+	userItems, err := repos.CollectionItemRepository.GetUserItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	owned := make(map[entities.ItemID]bool, len(userItems)+len(received))
+	for _, userItem := range userItems {
+		owned[userItem.ItemID] = userItem.Count > 0
+	}
+	for _, itemID := range received {
+		owned[itemID] = true
+	}
+	return grantCollectionMilestonesTransaction(tx, repos, userID, items, owned)
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/coupon"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// 存在しないコードを入力できる回数。超えた場合はcouponRedeemLockoutの間クーポンを使えなくする
+	maxCouponRedeemFailures = 10
+	couponRedeemLockout     = time.Hour
+)
+
+// 存在しないコードの入力を記録する。記録に失敗してもレスポンスは変えない
+func recordCouponRedeemFailure(repos *repositories.Repositories, userID entities.UserID) {
+	if _, err := repos.CouponRepository.AddRedeemFailure(userID, couponRedeemLockout); err != nil {
+		log.Println(err)
+	}
+}
+
+// シリアルコードを入力してクーポンの報酬を受け取る 対象のコードをJSONで"code": "ABCD-EFGH-JKLM"のように指定
+// コードの区切りの"-"と大文字・小文字は区別しない
+// 総当たりを防ぐため、存在しないコードを続けて入力したユーザは一定時間クーポンを使えなくする
+func HandleCouponRedeem(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		failures, err := repos.CouponRepository.GetRedeemFailureCount(userID)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if failures >= maxCouponRedeemFailures {
+			writer.Header().Set("Retry-After", fmt.Sprint(int(couponRedeemLockout.Seconds())))
+			response.SetStatusAndJson(writer, http.StatusTooManyRequests, map[string]string{"error": "too many invalid codes, try again later"})
+			return
+		}
+
+		type redeemRequest struct {
+			Code string `json:"code"`
+		}
+		var req redeemRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		code := entities.NormalizeCouponCode(req.Code)
+		if !coupon.ValidCode(code) {
+			recordCouponRedeemFailure(repos, userID)
+			response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": repositories.ErrCouponNotFound.Error()})
+			return
+		}
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 同じキャンペーンの別々のコードを同時に使われても1ユーザあたりの回数を超えないよう、先にユーザの行をロックする
+		if _, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, userID); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		couponCode, err := repos.CouponRepository.GetCouponCodeForUpdateTransaction(tx, code)
+		if err != nil {
+			if errors.Is(err, repositories.ErrCouponNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				recordCouponRedeemFailure(repos, userID)
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		campaign, err := repos.CouponRepository.GetCouponCampaignByIDTransaction(tx, couponCode.CampaignID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		if !campaign.IsStarted(now) {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "coupon is not available yet"})
+			return
+		}
+		if campaign.IsEnded(now) {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "coupon has expired"})
+			return
+		}
+		redeemed, err := repos.CouponRepository.CountUserRedemptionsTransaction(tx, campaign.ID, userID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if redeemed >= campaign.PerUserLimit {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "coupon redemption limit reached"})
+			return
+		}
+		switch campaign.Kind {
+		case entities.CouponKindSingle:
+			if couponCode.RedeemedCount > 0 {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "coupon is already used"})
+				return
+			}
+		case entities.CouponKindShared:
+			if campaign.TotalLimit > 0 && couponCode.RedeemedCount >= campaign.TotalLimit {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "coupon has run out"})
+				return
+			}
+		}
+
+		if err := repos.CouponRepository.RedeemCouponTransaction(tx, couponCode, userID); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := grantRewardTransaction(tx, repos, userID, &campaign.Reward, entities.CoinLedgerReasonCoupon); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		achieved, err := grantReceivedItemMilestonesTransaction(tx, repos, userID, items.Released(now), campaign.Reward.ItemIDs())
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.CouponRedeemResult{
+			CampaignName:       campaign.Name,
+			Reward:             campaign.Reward,
+			AchievedMilestones: achieved,
+		})
+	}
+}
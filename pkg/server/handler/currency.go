@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+var errNotEnoughCoin = errors.New("not enough coin")
+
+// 設定した消費順に従って有償コインと無償コインからamountを消費し、通貨ごとに台帳へ記録する
+// ユーザの行をロックしたトランザクション内で呼び出すこと。userの残高も消費後の値に更新する
+func spendCoinsTransaction(tx *sql.Tx, repos *repositories.Repositories, conf *config.Config, user *entities.User, amount entities.Coin, reason entities.CoinLedgerReason) (entities.CoinSpend, error) {
+	spend, ok := user.SplitCoinSpend(amount, conf.CoinSpendOrder)
+	if !ok {
+		return entities.CoinSpend{}, errNotEnoughCoin
+	}
+	if err := repos.UserRepository.SpendUserCoinsByIDTransaction(tx, user.ID, spend); err != nil {
+		return entities.CoinSpend{}, err
+	}
+	for _, entry := range []entities.CoinLedgerEntry{
+		{UserID: user.ID, Amount: -spend.Free, Currency: entities.CurrencyFree, Reason: reason},
+		{UserID: user.ID, Amount: -spend.Paid, Currency: entities.CurrencyPaid, Reason: reason},
+	} {
+		if entry.Amount == 0 {
+			continue
+		}
+		if err := repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entry); err != nil {
+			return entities.CoinSpend{}, err
+		}
+	}
+	user.Coin -= spend.Free
+	user.PaidCoin -= spend.Paid
+	return spend, nil
+}
+
+// 有償コインと無償コインにdeltaの内訳の分だけ加算し、通貨ごとに台帳へ記録する
+// 返金の取り消しでは負の値を渡す。ユーザの行をロックしたトランザクション内で呼び出すこと。userの残高も加算後の値に更新する
+func addCoinsTransaction(tx *sql.Tx, repos *repositories.Repositories, user *entities.User, delta entities.CoinSpend, reason entities.CoinLedgerReason) error {
+	if err := repos.UserRepository.AddUserCoinBalancesByIDTransaction(tx, user.ID, delta); err != nil {
+		return err
+	}
+	for _, entry := range []entities.CoinLedgerEntry{
+		{UserID: user.ID, Amount: delta.Free, Currency: entities.CurrencyFree, Reason: reason},
+		{UserID: user.ID, Amount: delta.Paid, Currency: entities.CurrencyPaid, Reason: reason},
+	} {
+		if entry.Amount == 0 {
+			continue
+		}
+		if err := repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entry); err != nil {
+			return err
+		}
+	}
+	user.Coin += delta.Free
+	user.PaidCoin += delta.Paid
+	return nil
+}
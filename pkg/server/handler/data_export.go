@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/export"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// dataExportResponse エクスポートの状態。ダウンロードできる場合は署名付きのダウンロード用URLを含める
+type dataExportResponse struct {
+	entities.DataExport
+	DownloadURL string `json:"downloadUrl,omitempty"`
+}
+
+func newDataExportResponse(conf *config.Config, dataExport *entities.DataExport, at time.Time) dataExportResponse {
+	exported := *dataExport
+	// 期限切れのファイルは次のエクスポートの作成時に消すため、それまでは状態を読み替える
+	if exported.Status == entities.DataExportStatusReady && exported.ExpiresAt != nil && !at.Before(*exported.ExpiresAt) {
+		exported.Status = entities.DataExportStatusExpired
+	}
+	return dataExportResponse{
+		DataExport:  exported,
+		DownloadURL: export.DownloadURL(conf.DataExportSigningKey, &exported),
+	}
+}
+
+// 個人データのエクスポートを依頼する。作成はバックグラウンドで行い、/user/export/statusで状態を確認する
+// 形式はJSONで"format": "json"(1つのJSON)または"zip"(JSON Linesのファイルをまとめたzip)のように指定し、省略した場合はjson
+// 作成中のエクスポートがある場合は409を返す
+func HandleDataExportRequest(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+
+		type exportRequest struct {
+			Format entities.DataExportFormat `json:"format"`
+		}
+		var req exportRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.Format == "" {
+			req.Format = entities.DataExportFormatJSON
+		}
+		if !req.Format.IsValid() {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "format must be json or zip"})
+			return
+		}
+
+		dataExport, err := export.Request(repos, conf, principal.UserID, req.Format)
+		if err != nil {
+			if errors.Is(err, export.ErrExportInProgress) {
+				response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, newDataExportResponse(conf, dataExport, time.Now()))
+	}
+}
+
+// 自身のエクスポートの状態を取得する。ダウンロードできる場合はdownloadUrlを返す
+// 対象のエクスポートIDはクエリパラメータで?exportId=1のように指定
+func HandleGetDataExportStatus(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+
+		exportID, err := strconv.ParseInt(request.URL.Query().Get("exportId"), 10, 64)
+		if err != nil || exportID < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "exportId must be a positive integer"})
+			return
+		}
+
+		dataExport, err := repos.DataExportRepository.GetUserDataExport(principal.UserID, entities.DataExportID(exportID))
+		if err != nil {
+			if errors.Is(err, repositories.ErrDataExportNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, newDataExportResponse(conf, dataExport, time.Now()))
+	}
+}
+
+// エクスポートのファイルをダウンロードする。認証の代わりに/user/export/statusで発行した署名付きURLを使う
+// ダウンロードは1回のみで、ダウンロード済み・期限切れの場合は410を返す
+func HandleDataExportDownload(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		exportID, err := strconv.ParseInt(query.Get("exportId"), 10, 64)
+		if err != nil || exportID < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "exportId must be a positive integer"})
+			return
+		}
+		expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "expires must be an integer"})
+			return
+		}
+
+		dataExport, file, err := export.Download(repos, conf, entities.DataExportID(exportID), expires, query.Get("signature"))
+		if err != nil {
+			switch {
+			case errors.Is(err, export.ErrInvalidSignature):
+				response.SetStatusAndJson(writer, http.StatusForbidden, map[string]string{"error": err.Error()})
+			case errors.Is(err, repositories.ErrDataExportNotFound), errors.Is(err, repositories.ErrDataExportUnavailable):
+				response.SetStatusAndJson(writer, http.StatusGone, map[string]string{"error": repositories.ErrDataExportUnavailable.Error()})
+			default:
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				log.Println(err)
+			}
+		}()
+
+		writer.Header().Set("Content-Type", export.ContentType(dataExport.Format))
+		writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"data-export-%d.%s\"", dataExport.ID, dataExport.Format))
+		writer.Header().Set("Content-Length", strconv.FormatInt(file.Size, 10))
+		writer.Header().Set("Cache-Control", "no-store")
+		writer.WriteHeader(http.StatusOK)
+		// ファイルを全て読み込まずに、少しずつレスポンスに書き出す
+		if _, err := io.Copy(writer, file); err != nil {
+			log.Println(err)
+		}
+	}
+}
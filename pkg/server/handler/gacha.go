@@ -2,30 +2,46 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"math/rand"
 	"net/http"
+	"time"
 
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/gacha"
 	"42tokyo-road-to-dojo-go/pkg/http/response"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
 	"42tokyo-road-to-dojo-go/pkg/server/entities"
 )
 
+// ノンスの最大長
+const maxGachaNonceLength = 64
+
 // ガチャを引く
 // 引く回数をJSONで"times": 10のように指定
+// "nonce"を指定した場合は公平モードとなり、期間ごとのシード・ユーザID・ノンスから結果を決定する
 // ctxからユーザーIDを取得し、ユーザーの所持コイン数を確認
 // トランザクションの期間を短くするために、先にガチャの結果を計算
 // Response用にガチャの結果を整形し
 // トランザクション開始し、
+// ユーザの行をロックして所持コインと月間消費上限を確認し、
 // 所持コインを引く処理を行う、
 // 所持アイテムに加える処理を行う、
-// その際に、すでに持っているアイテムの場合は、Repositoryを呼び出さない。新規の場合のみ呼び出し、isNewをtrueにする
-func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
+// 排出されたアイテムはまとめて1回のクエリで所持数に加算し、ガチャ前に持っていなかったアイテムの初回排出のみisNewをtrueにする
+// 新しく獲得したアイテムでコレクションの達成報酬を達成した場合は報酬を付与する
+// 最後にガチャの実行記録を保存する
+func HandleGachaDraw(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		userID := request.Context().Value("userID").(entities.UserID)
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
 
 		type times struct {
-			Times int `json:"times"`
+			Times int    `json:"times"`
+			Nonce string `json:"nonce"`
 		}
 		var timesJSON times
 		err := json.NewDecoder(request.Body).Decode(&timesJSON)
@@ -48,6 +64,11 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 			return
 		}
 
+		if len(timesJSON.Nonce) > maxGachaNonceLength {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "nonce is too long"})
+			return
+		}
+
 		// game_settingsテーブルからガチャの設定を取得
 		gameSettingsRepo := repos.GameSettingsRepository
 		gameSettings, err := gameSettingsRepo.GetActiveGameSettingsFromCache()
@@ -72,7 +93,8 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 		}
 
 		// 所持コイン < ガチャのコスト * 引く回数 の場合はエラー
-		if user.Coin < entities.Coin(gameSettings.GachaCoinConsumption)*entities.Coin(timesInt) {
+		cost := entities.Coin(gameSettings.GachaCoinConsumption) * entities.Coin(timesInt)
+		if user.TotalCoin() < cost {
 			log.Println("not enough coin")
 			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "not enough coin"})
 			return
@@ -89,46 +111,27 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 			return
 		}
 
-		itemsWithWeight := make([]entities.ItemWithWeight, 0, len(*items))
-
-		// 重み付けの合計を計算
-		var totalWeight entities.Weight = 0
-		for _, item := range *items {
-			itemsWithWeight = append(itemsWithWeight, entities.ItemWithWeight{
-				Item:   item,
-				Weight: 0,
-			})
-
-			switch item.Rarity {
-			case entities.N:
-				itemsWithWeight[len(itemsWithWeight)-1].Weight = gameSettings.NWeight
-				totalWeight += gameSettings.NWeight
-			case entities.R:
-				itemsWithWeight[len(itemsWithWeight)-1].Weight = gameSettings.RWeight
-				totalWeight += gameSettings.RWeight
-			case entities.SR:
-				itemsWithWeight[len(itemsWithWeight)-1].Weight = gameSettings.SrWeight
-				totalWeight += gameSettings.SrWeight
+		// 公平モードの場合は現在の期間のシードから乱数生成器を作る
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		var seed *entities.GachaSeed
+		if timesJSON.Nonce != "" {
+			seed, err = getCurrentGachaSeed(repos, conf, time.Now())
+			if err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
 			}
+			rng = gacha.NewFairRand(seed.Seed, userID, timesJSON.Nonce)
 		}
 
-		// ガチャの結果であるアイテムのIDを保存するための変数
-		var gachaGetIDs []entities.ItemID
-
-		// times回ガチャを引く
-		for i := int64(0); i < timesInt; i++ {
-			// 乱数を生成
-			randomNum := rand.Int63n(int64(totalWeight))
-
-			// 重み付けの合計を超えるまで、重み付けを引いていく
-			var weightSum int64 = 0
-			for _, itemWithWeight := range itemsWithWeight {
-				weightSum += int64(itemWithWeight.Weight)
-				if randomNum < weightSum {
-					gachaGetIDs = append(gachaGetIDs, itemWithWeight.ID)
-					break
-				}
-			}
+		// ガチャの結果であるアイテムのIDを計算 公開前と排出終了後のアイテムは排出しない
+		now := time.Now()
+		pool := gacha.NewPool(*items, gameSettings, now)
+		gachaGetIDs, err := pool.Draw(rng, timesInt)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
 		}
 
 		// トランザクションの開始
@@ -139,8 +142,48 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 			return
 		}
 
-		// 所持コインを引く処理
-		err = userRepo.UpdateUserCoinsByIDTransaction(tx, userID, user.Coin-entities.Coin(gameSettings.GachaCoinConsumption)*entities.Coin(timesInt))
+		// ユーザの行をロックして、残高と月間消費上限を確認し直す
+		user, err = userRepo.GetUserByIDForUpdateTransaction(tx, userID)
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if user.TotalCoin() < cost {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+			}
+			log.Println("not enough coin")
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "not enough coin"})
+			return
+		}
+		remaining, ok, err := checkSpendingCapTransaction(tx, repos, conf, user, cost)
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if !ok {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+			}
+			response.SetStatusAndJson(writer, http.StatusForbidden, map[string]interface{}{
+				"error":     "monthly spending cap exceeded",
+				"remaining": remaining,
+			})
+			return
+		}
+
+		// 有償コインと無償コインから所持コインを引き、消費したコインを通貨ごとに記録する
+		_, err = spendCoinsTransaction(tx, repos, conf, user, cost, entities.CoinLedgerReasonGacha)
 		if err != nil {
 			if err := tx.Rollback(); err != nil {
 				log.Println(err)
@@ -167,7 +210,7 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 		// GachResultListを作成
 		var gachaResultList entities.GachaResultList
 
-		// ガチャの結果をループで回しながら、isNewの判定と設定、newはコレクションアイテムに追加する
+		// ガチャの結果をループで回しながら、isNewの判定と設定を行う
 		// ハッシュマップを使って、O(n)で済むようにする
 		var itemsMap = make(map[entities.ItemID]entities.Item, len(*items))
 		for _, item := range *items {
@@ -178,8 +221,7 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 			collectionItemMap[collectionItem] = true
 		}
 
-		// isNewのアイテムIDを格納するためのスライス
-		var isNewItemIDs []entities.ItemID
+		hasNewItem := false
 		for _, gachaGetID := range gachaGetIDs {
 			if _, ok := collectionItemMap[gachaGetID]; ok {
 				gachaResultList.Items = append(gachaResultList.Items, entities.GachaResult{
@@ -189,8 +231,8 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 					IsNew:  false,
 				})
 			} else {
-				isNewItemIDs = append(isNewItemIDs, gachaGetID)
 				collectionItemMap[gachaGetID] = true
+				hasNewItem = true
 				gachaResultList.Items = append(gachaResultList.Items, entities.GachaResult{
 					ID:     gachaGetID,
 					Name:   itemsMap[gachaGetID].Name,
@@ -200,17 +242,63 @@ func HandleGachaDraw(repos *repositories.Repositories) http.HandlerFunc {
 			}
 		}
 
-		// 所持アイテムに加える処理
-		err = collectionItemRepo.AddCollectionItemsTransaction(tx, userID, isNewItemIDs)
+		// 所持アイテムに加える処理 重複して排出されたアイテムも所持数に加算する
+		err = collectionItemRepo.AddCollectionItemsTransaction(tx, userID, gachaGetIDs)
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 新しく獲得したアイテムがある場合は、コレクションの達成報酬を確認して付与する
+		if hasNewItem {
+			gachaResultList.AchievedMilestones, err = grantCollectionMilestonesTransaction(tx, repos, userID, items.Released(now), collectionItemMap)
+			if err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		// ガチャの実行記録を保存
+		draw := entities.GachaDraw{
+			UserID:        userID,
+			GameSettingID: gameSettings.ID,
+			Times:         timesInt,
+			ItemIDs:       gachaGetIDs,
+		}
+		if seed != nil {
+			// 抽選後にマスターデータを変更しても検証できるように、抽選に使った排出対象を保存する
+			poolAt := now
+			draw.SeedID = &seed.ID
+			draw.Nonce = timesJSON.Nonce
+			draw.Pool = pool.Entries()
+			draw.PoolAt = &poolAt
+		}
+		err = repos.GachaDrawRepository.AddGachaDrawTransaction(tx, &draw)
 		if err != nil {
 			if err := tx.Rollback(); err != nil {
 				log.Println(err)
 				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			}
 			log.Println(err)
+			if errors.Is(err, repositories.ErrGachaNonceAlreadyUsed) {
+				response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
 			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
+		gachaResultList.DrawID = draw.ID
+		if seed != nil {
+			gachaResultList.SeedID = &seed.ID
+			gachaResultList.SeedHash = seed.SeedHash
+			gachaResultList.Nonce = timesJSON.Nonce
+		}
 
 		// commit
 		if err := tx.Commit(); err != nil {
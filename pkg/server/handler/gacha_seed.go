@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/gacha"
+	"42tokyo-road-to-dojo-go/pkg/gacha/verifier"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 現在の期間の公平ガチャ用シードを取得する。まだなければ生成する。
+func getCurrentGachaSeed(repos *repositories.Repositories, conf *config.Config, now time.Time) (*entities.GachaSeed, error) {
+	seed, err := gacha.NewSeed()
+	if err != nil {
+		return nil, err
+	}
+	startsAt := now.UTC().Truncate(conf.FairSeedPeriod)
+	return repos.GachaSeedRepository.GetOrCreateSeed(&entities.GachaSeed{
+		Seed:     seed,
+		SeedHash: gacha.HashSeed(seed),
+		StartsAt: startsAt,
+		EndsAt:   startsAt.Add(conf.FairSeedPeriod),
+	})
+}
+
+// 期間が終了していないシードは秘密のまま、ハッシュのみを返す
+func publicGachaSeed(seed *entities.GachaSeed, now time.Time) *entities.GachaSeed {
+	public := *seed
+	if now.Before(seed.EndsAt) {
+		public.Seed = ""
+	}
+	return &public
+}
+
+// 現在の期間の公平ガチャ用シードのハッシュを取得する
+func HandleGetCurrentGachaSeed(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		now := time.Now()
+		seed, err := getCurrentGachaSeed(repos, conf, now)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, publicGachaSeed(seed, now))
+	}
+}
+
+// 公平ガチャ用シードを取得する id はクエリパラメータ
+// 期間が終了したシードは、シード本体も公開する
+func HandleGetGachaSeed(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		// 入力の受け取り
+		id := request.URL.Query().Get("id")
+		if id == "" {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "id is required"})
+			return
+		}
+
+		// validation
+		id64, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if id64 < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "id must be positive"})
+			return
+		}
+
+		seed, err := repos.GachaSeedRepository.GetSeedByID(entities.GachaSeedID(id64))
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": "seed not found"})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, publicGachaSeed(seed, time.Now()))
+	}
+}
+
+// 公平モードで引いたガチャの結果を、公開済みのシードから再計算して検証する
+// 排出対象は現在のマスターデータではなく、実行記録に保存した抽選時のものを使う
+// 検証するガチャの実行記録のIDをJSONで"drawId": 1のように指定
+func HandleGachaVerify(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type verifyRequest struct {
+			DrawID entities.GachaDrawID `json:"drawId"`
+		}
+		var req verifyRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 自身のガチャの実行記録のみ検証できる
+		draw, err := repos.GachaDrawRepository.GetGachaDrawByID(req.DrawID)
+		if err != nil || draw.UserID != userID {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": "draw not found"})
+			return
+		}
+		if draw.SeedID == nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": verifier.ErrNotFairDraw.Error()})
+			return
+		}
+
+		seed, err := repos.GachaSeedRepository.GetSeedByID(*draw.SeedID)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		seed = publicGachaSeed(seed, time.Now())
+
+		result, err := verifier.Verify(draw, seed)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, verifier.ErrSeedNotRevealed) || errors.Is(err, verifier.ErrPoolNotRecorded) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, result)
+	}
+}
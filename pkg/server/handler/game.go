@@ -14,7 +14,11 @@ import (
 func HandleGameFinish(repos *repositories.Repositories) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		// 入力の受け取りとvalidation
-		userID := request.Context().Value("userID").(entities.UserID)
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
 
 		type score = struct {
 			Score int `json:"score"`
@@ -62,7 +66,7 @@ func HandleGameFinish(repos *repositories.Repositories) http.HandlerFunc {
 
 		// user.highScoreと比較して、今回のスコアの方が高ければuser.highScoreを更新
 		userRepo := repos.UserRepository
-		user, err := userRepo.GetUserByID(userID)
+		user, err := userRepo.GetUserByIDForUpdateTransaction(tx, userID)
 		if err != nil {
 			if err := tx.Rollback(); err != nil {
 				log.Println(err)
@@ -87,7 +91,23 @@ func HandleGameFinish(repos *repositories.Repositories) http.HandlerFunc {
 
 		//scoreからコインの数を計算し、user.coinsに加算し、Responseに増加したコインの数を返却
 		coin := entities.Coin(scoreInt / 10)
-		err = userRepo.UpdateUserCoinsByID(userID, user.Coin+coin)
+		err = userRepo.UpdateUserCoinsByIDTransaction(tx, userID, user.Coin+coin)
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 獲得したコインを記録
+		err = repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entities.CoinLedgerEntry{
+			UserID: userID,
+			Amount: coin,
+			Reason: entities.CoinLedgerReasonGameFinish,
+		})
 		if err != nil {
 			if err := tx.Rollback(); err != nil {
 				log.Println(err)
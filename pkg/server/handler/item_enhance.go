@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// アイテムを強化する
+// 強化するアイテムをJSONで"collectionID": 1, "levels": 2のように指定(levelsは省略時1)
+// レアリティごとの設定に従い、1レベルあたり重複アイテムcopiesPerLevel個とコインcoinPerLevel枚を消費する
+// 所持数は1個以上残す必要がある
+// 所持数・コインの確認と消費、レベルの更新は1つのトランザクション内で行う
+func HandleItemEnhance(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type enhanceRequest struct {
+			ItemID entities.ItemID     `json:"collectionID"`
+			Levels *entities.ItemLevel `json:"levels"`
+		}
+		var req enhanceRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// validation
+		levels := entities.ItemLevel(1)
+		if req.Levels != nil {
+			levels = *req.Levels
+		}
+		if levels < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "levels must be greater than 0"})
+			return
+		}
+
+		// キャッシュからアイテムと強化設定を取得
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		var item *entities.Item
+		for i := range *items {
+			if (*items)[i].ID == req.ItemID {
+				item = &(*items)[i]
+				break
+			}
+		}
+		if item == nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "item not found"})
+			return
+		}
+
+		enhanceSettings, err := repos.ItemEnhanceSettingsRepository.GetItemEnhanceSettingsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		setting, ok := enhanceSettings.FindByRarity(item.Rarity)
+		if !ok {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "item cannot be enhanced"})
+			return
+		}
+
+		// 消費量の計算があふれないように、掛け算の前に最大レベルで上限を確認する
+		if levels > setting.MaxLevel {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "level exceeds max level"})
+			return
+		}
+		consumedCopies := setting.CopiesPerLevel * entities.ItemCount(levels)
+		consumedCoin := setting.CoinPerLevel * entities.Coin(levels)
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// ユーザと所持アイテムの行をロックして取得
+		user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, userID)
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		userItem, err := repos.CollectionItemRepository.GetUserItemForUpdateTransaction(tx, userID, req.ItemID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, sql.ErrNoRows) {
+				rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "item is not owned"})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 最大レベル・所持数・所持コインの確認
+		if levels > setting.MaxLevel-userItem.Level {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "level exceeds max level"})
+			return
+		}
+		if userItem.Count-consumedCopies < 1 {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "not enough copies"})
+			return
+		}
+		if user.TotalCoin() < consumedCoin {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "not enough coin"})
+			return
+		}
+
+		// 重複アイテムを消費してレベルを上げる
+		userItem.Count -= consumedCopies
+		userItem.Level += levels
+		if err := repos.CollectionItemRepository.UpdateUserItemTransaction(tx, userID, userItem); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 有償コインと無償コインからコインを消費して記録する
+		if consumedCoin > 0 {
+			if _, err := spendCoinsTransaction(tx, repos, conf, user, consumedCoin, entities.CoinLedgerReasonEnhance); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.ItemEnhanceResult{
+			ID:             userItem.ItemID,
+			Level:          userItem.Level,
+			MaxLevel:       setting.MaxLevel,
+			Count:          userItem.Count,
+			ConsumedCopies: consumedCopies,
+			ConsumedCoin:   consumedCoin,
+		})
+	}
+}
@@ -0,0 +1,507 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	// 出品一覧の1ページあたりの件数
+	defaultMarketListingLimit = 20
+	maxMarketListingLimit     = 100
+	// 1回のリクエストで期限切れにする出品の最大件数
+	marketExpireBatchSize = 50
+)
+
+// 出品したアイテムのIDを個数分並べる
+func marketListingItemIDs(listing *entities.MarketListing) []entities.ItemID {
+	itemIDs := make([]entities.ItemID, listing.Count)
+	for i := range itemIDs {
+		itemIDs[i] = listing.ItemID
+	}
+	return itemIDs
+}
+
+// 出品を終了し、預かっていたアイテムを出品者に返す
+func returnMarketListingTransaction(tx *sql.Tx, repos *repositories.Repositories, listing *entities.MarketListing, status entities.MarketListingStatus) error {
+	if err := repos.CollectionItemRepository.AddCollectionItemsTransaction(tx, listing.SellerID, marketListingItemIDs(listing)); err != nil {
+		return err
+	}
+	if err := repos.MarketListingRepository.CloseMarketListingTransaction(tx, listing.ID, status, nil); err != nil {
+		return err
+	}
+	listing.Status = status
+	return nil
+}
+
+// 出品中のまま期限を過ぎた出品を期限切れにして、アイテムを出品者に返す
+// 定期実行の仕組みがないため、マーケットのAPIが呼ばれるたびに古いものから一定件数ずつ処理する
+func expireMarketListings(repos *repositories.Repositories, now time.Time) error {
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		return err
+	}
+	listings, err := repos.MarketListingRepository.GetExpiredMarketListingsForUpdateTransaction(tx, now, marketExpireBatchSize)
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Println(err)
+		}
+		return err
+	}
+	for i := range listings {
+		if err := returnMarketListingTransaction(tx, repos, &listings[i], entities.MarketListingStatusExpired); err != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 所持アイテムをマーケットに出品する
+// 出品するコレクションID・個数・価格をJSONで{"collectionID": 1, "count": 1, "price": 100}のように指定
+// 出品したアイテムは所持数から引いて預かる。所持数は1個以上残す必要がある
+// 手数料は出品時の手数料率で計算し、購入時に売上から差し引く
+func HandleMarketSell(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type sellRequest struct {
+			ItemID entities.ItemID     `json:"collectionID"`
+			Count  *entities.ItemCount `json:"count"`
+			Price  entities.Coin       `json:"price"`
+		}
+		var req sellRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// validation
+		count := entities.ItemCount(1)
+		if req.Count != nil {
+			count = *req.Count
+		}
+		if count < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "count must be greater than 0"})
+			return
+		}
+		if req.Price < 1 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "price must be greater than 0"})
+			return
+		}
+		item, err := repos.ItemRepository.GetItemByID(req.ItemID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrItemNotFound) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		// 未公開のアイテムはコレクション一覧と同じく存在しないものとして扱い、公開前に他のユーザに知られないようにする
+		now := time.Now().UTC().Truncate(time.Second)
+		if !item.IsReleased(now) {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": repositories.ErrItemNotFound.Error()})
+			return
+		}
+
+		listing := entities.MarketListing{
+			SellerID:  userID,
+			ItemID:    req.ItemID,
+			Count:     count,
+			Price:     req.Price,
+			Fee:       entities.MarketFee(req.Price, conf.MarketFeePercent),
+			Status:    entities.MarketListingStatusActive,
+			ExpiresAt: now.Add(conf.MarketListingTTL),
+			CreatedAt: now,
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 所持アイテムの行をロックして、出品する分を預かる
+		userItem, err := repos.CollectionItemRepository.GetUserItemForUpdateTransaction(tx, userID, req.ItemID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, sql.ErrNoRows) {
+				rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "item is not owned"})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if userItem.Count-count < 1 {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "not enough copies"})
+			return
+		}
+		userItem.Count -= count
+		if err := repos.CollectionItemRepository.UpdateUserItemTransaction(tx, userID, userItem); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := repos.MarketListingRepository.AddMarketListingTransaction(tx, &listing); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, listing)
+	}
+}
+
+// 出品中の一覧を取得する
+// クエリパラメータでコレクションID(collectionID)・レアリティ(rarity)による絞り込み、
+// 並び替え(sort=price で価格の安い順、sort=new で新しい順)、件数(limit)と開始位置(offset)を指定できる
+func HandleGetMarketListings(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		status := entities.MarketListingStatusActive
+		filter := entities.MarketListingFilter{Status: &status, ReleasedOnly: true, SortBy: "new", Limit: defaultMarketListingLimit}
+
+		// 入力の受け取り
+		query := request.URL.Query()
+		if v := query.Get("collectionID"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id < 1 {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "collectionID must be a positive integer"})
+				return
+			}
+			itemID := entities.ItemID(id)
+			filter.ItemID = &itemID
+		}
+		if v := query.Get("rarity"); v != "" {
+			r, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || r < int64(entities.N) || r > int64(entities.SR) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "rarity must be 1, 2 or 3"})
+				return
+			}
+			rarity := entities.Rarity(r)
+			filter.Rarity = &rarity
+		}
+		if v := query.Get("sort"); v != "" {
+			if v != "price" && v != "new" {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "sort must be price or new"})
+				return
+			}
+			filter.SortBy = v
+		}
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil || limit < 1 || limit > maxMarketListingLimit {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and " + strconv.Itoa(maxMarketListingLimit)})
+				return
+			}
+			filter.Limit = limit
+		}
+		if v := query.Get("offset"); v != "" {
+			offset, err := strconv.Atoi(v)
+			if err != nil || offset < 0 {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "offset must not be negative"})
+				return
+			}
+			filter.Offset = offset
+		}
+
+		now := time.Now()
+		if err := expireMarketListings(repos, now); err != nil {
+			// 期限切れの出品は一覧の取得時に除外されるため、処理に失敗しても一覧は返す
+			log.Println(err)
+		}
+
+		listings, err := repos.MarketListingRepository.GetMarketListings(&filter, now)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.MarketListingList{Listings: listings})
+	}
+}
+
+// 自身の出品の一覧を新しい順に取得する statusを指定した場合はその状態の出品のみ取得する
+func HandleGetMyMarketListings(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		filter := entities.MarketListingFilter{SellerID: &userID, SortBy: "new", Limit: maxMarketListingLimit}
+
+		// 入力の受け取り
+		if v := request.URL.Query().Get("status"); v != "" {
+			status := entities.MarketListingStatus(v)
+			switch status {
+			case entities.MarketListingStatusActive, entities.MarketListingStatusSold, entities.MarketListingStatusCanceled, entities.MarketListingStatusExpired:
+			default:
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+				return
+			}
+			filter.Status = &status
+		}
+
+		now := time.Now()
+		if err := expireMarketListings(repos, now); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		listings, err := repos.MarketListingRepository.GetMarketListings(&filter, now)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.MarketListingList{Listings: listings})
+	}
+}
+
+// 出品を購入する 対象の出品IDをJSONで"listingId": 1のように指定
+// トランザクション内で出品と購入者・出品者の行をロックし、
+// 購入者のコインを出品者に移して(手数料を差し引く)、預かっていたアイテムを購入者に渡し、出品を終了する
+func HandleMarketBuy(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type buyRequest struct {
+			ListingID entities.MarketListingID `json:"listingId"`
+		}
+		var req buyRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 出品の行をロックして、出品中か確認する
+		listing, err := repos.MarketListingRepository.GetMarketListingByIDForUpdateTransaction(tx, req.ListingID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrMarketListingNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if listing.Status != entities.MarketListingStatusActive {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "listing is already " + string(listing.Status)})
+			return
+		}
+		now := time.Now()
+		if listing.IsExpired(now) {
+			// 期限切れにしてアイテムを出品者に返してから応答する
+			if err := returnMarketListingTransaction(tx, repos, listing, entities.MarketListingStatusExpired); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": "listing has expired"})
+			return
+		}
+		if listing.SellerID == userID {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "cannot buy your own listing"})
+			return
+		}
+
+		// デッドロックを避けるため、ユーザIDの小さい順に購入者と出品者の行をロックする
+		lockOrder := []entities.UserID{userID, listing.SellerID}
+		if lockOrder[0] > lockOrder[1] {
+			lockOrder[0], lockOrder[1] = lockOrder[1], lockOrder[0]
+		}
+		users := make(map[entities.UserID]*entities.User, 2)
+		for _, id := range lockOrder {
+			user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, id)
+			if err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			users[id] = user
+		}
+		// 有償コインはユーザ間で移動できないため、マーケットでは無償コインのみ使える
+		if users[userID].Coin < listing.Price {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "not enough free coin"})
+			return
+		}
+
+		// 購入者のコインを出品者に移し、手数料を差し引く
+		proceeds := listing.Price - listing.Fee
+		for _, entry := range []entities.CoinLedgerEntry{
+			{UserID: userID, Amount: -listing.Price, Reason: entities.CoinLedgerReasonMarketBuy},
+			{UserID: listing.SellerID, Amount: proceeds, Reason: entities.CoinLedgerReasonMarketSell},
+		} {
+			if entry.Amount == 0 {
+				continue
+			}
+			if err := repos.UserRepository.AddUserCoinsByIDTransaction(tx, entry.UserID, entry.Amount); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if err := repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entry); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		// 預かっていたアイテムを購入者に渡し、出品を終了する
+		itemIDs := marketListingItemIDs(listing)
+		if err := repos.CollectionItemRepository.AddCollectionItemsTransaction(tx, userID, itemIDs); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := repos.MarketListingRepository.CloseMarketListingTransaction(tx, listing.ID, entities.MarketListingStatusSold, &userID); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		listing.Status = entities.MarketListingStatusSold
+		listing.BuyerID = &userID
+
+		// 購入したアイテムでコレクションの達成報酬を達成した場合は報酬を付与する
+		achieved, err := grantReceivedItemMilestonesTransaction(tx, repos, userID, items.Released(now), itemIDs)
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.MarketPurchaseResult{Listing: *listing, AchievedMilestones: achieved})
+	}
+}
+
+// 自身の出品を取り消し、預かっていたアイテムを返す 対象の出品IDをJSONで"listingId": 1のように指定
+func HandleMarketCancel(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type cancelRequest struct {
+			ListingID entities.MarketListingID `json:"listingId"`
+		}
+		var req cancelRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		listing, err := repos.MarketListingRepository.GetMarketListingByIDForUpdateTransaction(tx, req.ListingID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrMarketListingNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if listing.SellerID != userID {
+			rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": repositories.ErrMarketListingNotFound.Error()})
+			return
+		}
+		if listing.Status != entities.MarketListingStatusActive {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "listing is already " + string(listing.Status)})
+			return
+		}
+
+		// 期限切れの出品は取り消しではなく期限切れとして記録する
+		status := entities.MarketListingStatusCanceled
+		if listing.IsExpired(time.Now()) {
+			status = entities.MarketListingStatusExpired
+		}
+		if err := returnMarketListingTransaction(tx, repos, listing, status); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, listing)
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// プレゼントボックスの一覧と一括受け取りで扱う最大件数
+const maxPresentListLimit = 100
+
+// プレゼントの報酬を付与して受け取り済みにし、受け取ったアイテムで達成したコレクションの達成報酬を付与する
+func claimPresentsTransaction(tx *sql.Tx, repos *repositories.Repositories, userID entities.UserID, presents []entities.Present, items entities.Items, now time.Time) (entities.CollectionMilestones, error) {
+	presentIDs := make([]entities.PresentID, 0, len(presents))
+	var receivedItemIDs []entities.ItemID
+	for i := range presents {
+		if err := grantRewardTransaction(tx, repos, userID, &presents[i].Reward, entities.CoinLedgerReasonPresent); err != nil {
+			return nil, err
+		}
+		presentIDs = append(presentIDs, presents[i].ID)
+		receivedItemIDs = append(receivedItemIDs, presents[i].Reward.ItemIDs()...)
+		claimedAt := now.UTC().Truncate(time.Second)
+		presents[i].ClaimedAt = &claimedAt
+	}
+	if err := repos.PresentRepository.ClaimPresentsTransaction(tx, presentIDs, now); err != nil {
+		return nil, err
+	}
+	return grantReceivedItemMilestonesTransaction(tx, repos, userID, items.Released(now), receivedItemIDs)
+}
+
+// プレゼントボックスの一覧を取得する
+// 受け取っておらず期限も過ぎていないプレゼントを新しい順に返す
+func HandleGetPresentList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		presents, err := repos.PresentRepository.GetUnclaimedPresents(userID, time.Now(), maxPresentListLimit)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PresentList{Presents: presents})
+	}
+}
+
+// プレゼントを1件受け取る 対象のプレゼントIDをJSONで"presentId": 1のように指定
+// トランザクション内でプレゼントの行をロックし、報酬の付与と受け取り済みの記録を行う
+func HandlePresentClaim(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type claimRequest struct {
+			PresentID entities.PresentID `json:"presentId"`
+		}
+		var req claimRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		present, err := repos.PresentRepository.GetPresentByIDForUpdateTransaction(tx, req.PresentID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrPresentNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if present.UserID != userID {
+			rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": repositories.ErrPresentNotFound.Error()})
+			return
+		}
+		if present.ClaimedAt != nil {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "present is already claimed"})
+			return
+		}
+		now := time.Now()
+		if present.IsExpired(now) {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "present has expired"})
+			return
+		}
+
+		presents := []entities.Present{*present}
+		achieved, err := claimPresentsTransaction(tx, repos, userID, presents, *items, now)
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PresentClaimResult{Presents: presents, AchievedMilestones: achieved})
+	}
+}
+
+// 受け取っておらず期限も過ぎていないプレゼントを古い順にまとめて受け取る
+// 1回で受け取れるのは100件までで、残りは再度呼び出して受け取る
+func HandlePresentClaimAll(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		presents, err := repos.PresentRepository.GetUnclaimedPresentsForUpdateTransaction(tx, userID, now, maxPresentListLimit)
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		achieved, err := claimPresentsTransaction(tx, repos, userID, presents, *items, now)
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PresentClaimResult{Presents: presents, AchievedMilestones: achieved})
+	}
+}
+
+// 所持しているチケットの一覧を取得する
+func HandleGetTicketList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		tickets, err := repos.TicketRepository.GetUserTickets(userID)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.UserTicketList{Tickets: tickets})
+	}
+}
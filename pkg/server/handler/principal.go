@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/auth/authcontext"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+)
+
+// Contextからユーザのトークンで認証されたリクエスト元を取得する
+// 認証のミドルウェアを通っていない場合はエラーレスポンスを返し、okにfalseを返す
+func requestPrincipal(writer http.ResponseWriter, request *http.Request) (principal *authcontext.Principal, ok bool) {
+	principal, ok = authcontext.FromContext(request.Context())
+	if !ok || !principal.HasRole(authcontext.RoleUser) {
+		log.Println("principal is not found")
+		response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": "principal is not found"})
+		return nil, false
+	}
+	return principal, true
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/purchase"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 返金通知の本文の最大サイズ
+const maxRefundNotificationSize = 64 << 10
+
+var errPurchaseDisabled = errors.New("in-app purchase is disabled")
+
+// ストアで販売中の商品の一覧を取得する
+func HandleGetPurchaseProducts(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if conf.PurchaseVerifier == nil {
+			response.SetStatusAndJson(writer, http.StatusServiceUnavailable, map[string]string{"error": errPurchaseDisabled.Error()})
+			return
+		}
+
+		products, err := repos.PurchaseRepository.GetActiveProducts()
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PurchaseProductList{Products: products})
+	}
+}
+
+// ストアのレシートを検証して、購入した商品のコインを付与する 対象のストアとレシートをJSONで"store": "apple", "receipt": "..."のように指定
+// 同じ取引のレシートが再送された場合は付与せずに付与済みの記録を返すため、クライアントは失敗時に何度でも再送してよい
+func HandlePurchaseVerify(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		if conf.PurchaseVerifier == nil {
+			response.SetStatusAndJson(writer, http.StatusServiceUnavailable, map[string]string{"error": errPurchaseDisabled.Error()})
+			return
+		}
+
+		type verifyRequest struct {
+			Store   entities.PurchaseStore `json:"store"`
+			Receipt string                 `json:"receipt"`
+		}
+		var req verifyRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if !req.Store.IsValid() {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "store must be apple or google"})
+			return
+		}
+		if req.Receipt == "" {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "receipt is required"})
+			return
+		}
+
+		transaction, err := conf.PurchaseVerifier.VerifyReceipt(request.Context(), req.Store, req.Receipt)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, purchase.ErrInvalidReceipt) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusBadGateway, map[string]string{"error": "failed to verify receipt"})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 同じレシートが同時に送られても1回だけ付与するよう、先にユーザの行をロックしてから付与済みか確認する
+		user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, userID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		granted, err := repos.PurchaseRepository.GetPurchaseByTransactionIDTransaction(tx, transaction.Store, transaction.TransactionID)
+		switch {
+		case err == nil:
+			if granted.UserID != userID {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "receipt is already used by another user"})
+				return
+			}
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+			}
+			response.SetStatusAndJson(writer, http.StatusOK, entities.PurchaseResult{
+				Purchase:       *granted,
+				AlreadyGranted: true,
+				Coin:           user.Coin,
+				PaidCoin:       user.PaidCoin,
+			})
+			return
+		case !errors.Is(err, repositories.ErrPurchaseNotFound):
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		product, err := repos.PurchaseRepository.GetActiveProductByID(transaction.ProductID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrPurchaseProductNotFound) {
+				rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		p := entities.Purchase{
+			UserID:        userID,
+			Store:         transaction.Store,
+			TransactionID: transaction.TransactionID,
+			ProductID:     product.ProductID,
+			PaidCoin:      product.PaidCoin,
+			BonusCoin:     product.BonusCoin,
+			Status:        entities.PurchaseStatusCompleted,
+			PurchasedAt:   transaction.PurchasedAt,
+			CreatedAt:     time.Now(),
+		}
+		if err := repos.PurchaseRepository.AddPurchaseTransaction(tx, &p); err != nil {
+			// 別のユーザが同じレシートを同時に送った場合
+			if errors.Is(err, repositories.ErrPurchaseDuplicate) {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "receipt is already used by another user"})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		// 購入したコインは有償コイン、おまけのコインは無償コインとして付与する
+		if err := addCoinsTransaction(tx, repos, user, entities.CoinSpend{Free: product.BonusCoin, Paid: product.PaidCoin}, entities.CoinLedgerReasonPurchase); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.PurchaseResult{
+			Purchase: p,
+			Coin:     user.Coin,
+			PaidCoin: user.PaidCoin,
+		})
+	}
+}
+
+// ストアからの返金通知を受け取り、付与したコインを取り消す 対象のストアを?store=appleのように指定し、通知の本文はそのまま渡す
+// ストアのサーバから呼ばれるためユーザの認証は行わず、通知の正当性はVerifierで検証する
+// 消費済みで残高が負になった場合も取り消し、購入にサポートの対応が必要な印を付ける
+func HandlePurchaseRefundNotification(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if conf.PurchaseVerifier == nil {
+			response.SetStatusAndJson(writer, http.StatusServiceUnavailable, map[string]string{"error": errPurchaseDisabled.Error()})
+			return
+		}
+
+		store := entities.PurchaseStore(request.URL.Query().Get("store"))
+		if !store.IsValid() {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "store must be apple or google"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxRefundNotificationSize))
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		refund, err := conf.PurchaseVerifier.VerifyRefundNotification(request.Context(), store, body)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, purchase.ErrInvalidReceipt) {
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusBadGateway, map[string]string{"error": "failed to verify notification"})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// 購入と同じ順序でロックするため、購入したユーザを調べてからユーザの行、購入の行の順にロックする
+		p, err := repos.PurchaseRepository.GetPurchaseByTransactionIDTransaction(tx, refund.Store, refund.TransactionID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrPurchaseNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, p.UserID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		p, err = repos.PurchaseRepository.GetPurchaseByTransactionIDForUpdateTransaction(tx, refund.Store, refund.TransactionID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		// 同じ通知が再送された場合は取り消し済みの記録を返す
+		if p.Status == entities.PurchaseStatusRefunded {
+			if err := tx.Rollback(); err != nil {
+				log.Println(err)
+			}
+			response.SetStatusAndJson(writer, http.StatusOK, p)
+			return
+		}
+
+		if err := addCoinsTransaction(tx, repos, user, entities.CoinSpend{Free: -p.BonusCoin, Paid: -p.PaidCoin}, entities.CoinLedgerReasonRefund); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		now := time.Now()
+		needsSupport := user.Coin < 0 || user.PaidCoin < 0
+		if err := repos.PurchaseRepository.RefundPurchaseTransaction(tx, p.ID, now, needsSupport); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if needsSupport {
+			log.Printf("purchase %d refunded with negative balance: user %d coin %d paid coin %d", p.ID, user.ID, user.Coin, user.PaidCoin)
+		}
+
+		p.Status = entities.PurchaseStatusRefunded
+		p.RefundedAt = &now
+		p.NeedsSupport = needsSupport
+		response.SetStatusAndJson(writer, http.StatusOK, p)
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"database/sql"
+
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 報酬をユーザに付与する。コインの増加はreasonで記録する
+func grantRewardTransaction(tx *sql.Tx, repos *repositories.Repositories, userID entities.UserID, reward *entities.Reward, reason entities.CoinLedgerReason) error {
+	if reward.Coin > 0 {
+		if err := repos.UserRepository.AddUserCoinsByIDTransaction(tx, userID, reward.Coin); err != nil {
+			return err
+		}
+		err := repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entities.CoinLedgerEntry{
+			UserID: userID,
+			Amount: reward.Coin,
+			Reason: reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if itemIDs := reward.ItemIDs(); len(itemIDs) > 0 {
+		if err := repos.CollectionItemRepository.AddCollectionItemsTransaction(tx, userID, itemIDs); err != nil {
+			return err
+		}
+	}
+	if reward.TicketType != nil && reward.TicketCount > 0 {
+		if err := repos.TicketRepository.AddUserTicketsTransaction(tx, userID, *reward.TicketType, reward.TicketCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
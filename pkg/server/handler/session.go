@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 別の端末用に新しいセッションを作成し、そのトークンを返す 端末名をJSONで"deviceName": "iPad"のように指定
+// 端末ごとにトークンを分けることで、端末を個別にログアウトできる
+func HandleSessionCreate(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type createRequest struct {
+			DeviceName string `json:"deviceName"`
+		}
+		var req createRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		deviceName, err := auth.NormalizeDeviceName(req.DeviceName)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		issued, err := auth.CreateSession(repos, conf, userID, deviceName)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, issued)
+	}
+}
+
+// ログイン中のセッションの一覧を最後に使われた順に取得する。リクエストに使ったセッションはcurrentがtrueになる
+func HandleGetSessionList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		sessionID := principal.SessionID
+
+		sessions, err := repos.SessionRepository.GetActiveSessions(userID, time.Now())
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == sessionID
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.SessionList{Sessions: sessions})
+	}
+}
+
+// セッションを失効させ、その端末からログアウトする 対象のセッションIDをJSONで"sessionId": 1のように指定
+// リクエストに使ったセッションも指定できる
+func HandleSessionRevoke(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type revokeRequest struct {
+			SessionID entities.SessionID `json:"sessionId"`
+		}
+		var req revokeRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := auth.RevokeSession(repos, userID, req.SessionID); err != nil {
+			if errors.Is(err, repositories.ErrSessionNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		writer.WriteHeader(http.StatusOK)
+	}
+}
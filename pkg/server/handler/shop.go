@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// 1回で購入できる最大個数
+const maxShopPurchaseCount = 99
+
+// 販売中の商品の一覧を取得する
+// 販売期間内で報酬のアイテムが公開済みの商品を表示順に返し、ユーザが購入済みの個数を含める
+func HandleGetShopList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		offers, err := repos.ShopOfferRepository.GetShopOffersFromCache()
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		purchased, err := repos.ShopOfferRepository.GetUserPurchaseCounts(userID)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		itemMap := items.ByID()
+		list := entities.ShopOfferList{Offers: []entities.UserShopOffer{}}
+		for _, offer := range offers {
+			if !offer.IsAvailable(now, itemMap) {
+				continue
+			}
+			list.Offers = append(list.Offers, entities.UserShopOffer{ShopOffer: offer, PurchasedCount: purchased[offer.ID]})
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, list)
+	}
+}
+
+// 商品をコインで購入する 対象の商品と個数をJSONで"offerId": 1, "count": 1のように指定(countを省略した場合は1個)
+// トランザクション内でユーザの行をロックし、残高と購入上限の確認、コインの消費、報酬の付与を行う
+func HandleShopBuy(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type buyRequest struct {
+			OfferID entities.ShopOfferID `json:"offerId"`
+			Count   int64                `json:"count"`
+		}
+		var req buyRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.Count == 0 {
+			req.Count = 1
+		}
+		if req.Count < 1 || req.Count > maxShopPurchaseCount {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("count must be between 1 and %d", maxShopPurchaseCount)})
+			return
+		}
+
+		offer, err := repos.ShopOfferRepository.GetShopOfferFromCache(req.OfferID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrShopOfferNotFound) {
+				response.SetStatusAndJson(writer, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		now := time.Now()
+		if !offer.IsAvailable(now, items.ByID()) {
+			response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": "offer is not on sale"})
+			return
+		}
+		cost := offer.Price * entities.Coin(req.Count)
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// ユーザの行をロックして、残高と購入済みの個数を確認する
+		user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, userID)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if user.TotalCoin() < cost {
+			rollbackWithError(writer, tx, http.StatusBadRequest, map[string]string{"error": "not enough coin"})
+			return
+		}
+		if offer.PerUserLimit > 0 {
+			purchased, err := repos.ShopOfferRepository.GetUserPurchaseCountTransaction(tx, userID, offer.ID)
+			if err != nil {
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if purchased+req.Count > offer.PerUserLimit {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]interface{}{
+					"error":     "purchase limit reached",
+					"remaining": offer.PerUserLimit - purchased,
+				})
+				return
+			}
+		}
+
+		// 有償コインと無償コインから所持コインを引いて、消費したコインを通貨ごとに記録する
+		spend, err := spendCoinsTransaction(tx, repos, conf, user, cost, entities.CoinLedgerReasonShop)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := repos.ShopOfferRepository.AddUserPurchaseTransaction(tx, userID, offer.ID, req.Count); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		reward := offer.Reward.Times(req.Count)
+		if err := grantRewardTransaction(tx, repos, userID, &reward, entities.CoinLedgerReasonShop); err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		achieved, err := grantReceivedItemMilestonesTransaction(tx, repos, userID, items.Released(now), reward.ItemIDs())
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.ShopPurchaseResult{
+			Offer:              *offer,
+			Count:              req.Count,
+			Reward:             reward,
+			Spent:              spend,
+			Coin:               user.Coin,
+			PaidCoin:           user.PaidCoin,
+			AchievedMilestones: achieved,
+		})
+	}
+}
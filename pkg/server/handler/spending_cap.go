@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"database/sql"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// nowを含む暦月の期間 [from, to) をlocのタイムゾーンで求める
+func calendarMonth(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := now.In(loc)
+	from := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	return from, from.AddDate(0, 1, 0)
+}
+
+// 年齢区分が設定されたユーザについて、今月のガチャでの消費額にamountを加えても上限を超えないか確認する。
+// 消費額はcoin_ledgerに記録されたものを集計する。
+// 同時に複数のガチャが実行されても上限を超えないよう、ユーザの行をロックしたトランザクション内で呼び出すこと。
+// 上限を超える場合はokがfalseとなり、remainingに今月の残りの消費可能額を返す。
+func checkSpendingCapTransaction(tx *sql.Tx, repos *repositories.Repositories, conf *config.Config, user *entities.User, amount entities.Coin) (remaining entities.Coin, ok bool, err error) {
+	if user.AgeBracket == nil {
+		return 0, true, nil
+	}
+
+	spendingCap, err := repos.SpendingCapRepository.GetSpendingCapByAgeBracketTransaction(tx, *user.AgeBracket)
+	if err != nil {
+		return 0, false, err
+	}
+
+	from, to := calendarMonth(time.Now(), conf.SpendingCapLocation)
+	spent, err := repos.CoinLedgerRepository.GetSpentCoinsTransaction(tx, user.ID, entities.CoinLedgerReasonGacha, from, to)
+	if err != nil {
+		return 0, false, err
+	}
+
+	remaining = spendingCap.MonthlyCap - spent
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, amount <= remaining, nil
+}
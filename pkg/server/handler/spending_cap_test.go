@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarMonth(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		loc      *time.Location
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{
+			name:     "月の途中",
+			now:      time.Date(2026, 4, 15, 12, 0, 0, 0, time.UTC),
+			loc:      time.UTC,
+			wantFrom: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "月初ちょうど",
+			now:      time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			loc:      time.UTC,
+			wantFrom: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "年をまたぐ",
+			now:      time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC),
+			loc:      time.UTC,
+			wantFrom: time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "UTCでは前月でもタイムゾーンでは翌月",
+			now:      time.Date(2026, 3, 31, 15, 0, 0, 0, time.UTC),
+			loc:      jst,
+			wantFrom: time.Date(2026, 4, 1, 0, 0, 0, 0, jst),
+			wantTo:   time.Date(2026, 5, 1, 0, 0, 0, 0, jst),
+		},
+		{
+			name:     "UTCでは翌月でもタイムゾーンでは前月",
+			now:      time.Date(2026, 4, 30, 14, 59, 59, 0, time.UTC),
+			loc:      jst,
+			wantFrom: time.Date(2026, 4, 1, 0, 0, 0, 0, jst),
+			wantTo:   time.Date(2026, 5, 1, 0, 0, 0, 0, jst),
+		},
+		{
+			name:     "うるう年の2月",
+			now:      time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			loc:      time.UTC,
+			wantFrom: time.Date(2028, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2028, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := calendarMonth(tt.now, tt.loc)
+			if !from.Equal(tt.wantFrom) || !to.Equal(tt.wantTo) {
+				t.Errorf("calendarMonth() = [%v, %v), want [%v, %v)", from, to, tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
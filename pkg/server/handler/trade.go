@@ -0,0 +1,527 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+// トレード1件で片方が渡せるアイテムの最大種類数
+const maxTradeItems = 10
+
+// 所持数が足りず、トレードで渡すアイテムを1個以上残せない
+var errTradeNotEnoughItems = errors.New("not enough items to trade")
+
+// トレードで渡すアイテムを検証する。同じアイテムを重複して指定することはできない
+// 未公開のアイテムはコレクション一覧と同じく存在しないものとして扱い、公開前にトレードで知られないようにする
+func validateTradeItems(tradeItems []entities.TradeItem, itemMap map[entities.ItemID]entities.Item, at time.Time) error {
+	if len(tradeItems) > maxTradeItems {
+		return errors.New("too many items in a trade")
+	}
+	seen := make(map[entities.ItemID]bool, len(tradeItems))
+	for _, tradeItem := range tradeItems {
+		if tradeItem.Count < 1 {
+			return errors.New("count must be greater than 0")
+		}
+		if seen[tradeItem.ItemID] {
+			return errors.New("duplicate item in a trade")
+		}
+		seen[tradeItem.ItemID] = true
+		item, ok := itemMap[tradeItem.ItemID]
+		if !ok || !item.IsReleased(at) {
+			return errors.New("item not found")
+		}
+	}
+	return nil
+}
+
+// 所持アイテムがトレードで渡すアイテムを1個以上残して所持しているか確認する
+func hasTradeItems(userItems []entities.UserItem, tradeItems []entities.TradeItem) bool {
+	counts := make(map[entities.ItemID]entities.ItemCount, len(userItems))
+	for _, userItem := range userItems {
+		counts[userItem.ItemID] = userItem.Count
+	}
+	for _, tradeItem := range tradeItems {
+		if counts[tradeItem.ItemID]-tradeItem.Count < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// トレードを提案する
+// 相手のユーザIDと、渡すアイテム・コイン(offer)と受け取るアイテム・コイン(request)をJSONで指定
+// 例: {"receiverId": 2, "offerItems": [{"collectionID": 1, "count": 2}], "offerCoin": 0, "requestItems": [{"collectionID": 5, "count": 1}], "requestCoin": 100}
+// 提案の時点ではアイテム・コインを確保せず、成立時にロックを取って改めて確認する
+func HandleTradePropose(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type proposeRequest struct {
+			ReceiverID   entities.UserID      `json:"receiverId"`
+			OfferItems   []entities.TradeItem `json:"offerItems"`
+			OfferCoin    entities.Coin        `json:"offerCoin"`
+			RequestItems []entities.TradeItem `json:"requestItems"`
+			RequestCoin  entities.Coin        `json:"requestCoin"`
+		}
+		var req proposeRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// validation
+		if req.ReceiverID == userID {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "cannot trade with yourself"})
+			return
+		}
+		if req.OfferCoin < 0 || req.RequestCoin < 0 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "coin must not be negative"})
+			return
+		}
+		if len(req.OfferItems) == 0 && len(req.RequestItems) == 0 {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "trade must include at least one item"})
+			return
+		}
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		itemMap := make(map[entities.ItemID]entities.Item, len(*items))
+		for _, item := range *items {
+			itemMap[item.ID] = item
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		if err := validateTradeItems(req.OfferItems, itemMap, now); err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := validateTradeItems(req.RequestItems, itemMap, now); err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if _, err := repos.UserRepository.GetUserByID(req.ReceiverID); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "receiver not found"})
+			return
+		}
+
+		// 提案の時点で渡せないトレードは受け付けない
+		user, err := repos.UserRepository.GetUserByID(userID)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		// 有償コインはユーザ間で移動できないため、トレードには無償コインのみ使える
+		if user.Coin < req.OfferCoin {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "not enough free coin"})
+			return
+		}
+		userItems, err := repos.CollectionItemRepository.GetUserItems(userID)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if !hasTradeItems(userItems, req.OfferItems) {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": errTradeNotEnoughItems.Error()})
+			return
+		}
+
+		trade := entities.Trade{
+			ProposerID:   userID,
+			ReceiverID:   req.ReceiverID,
+			OfferItems:   req.OfferItems,
+			OfferCoin:    req.OfferCoin,
+			RequestItems: req.RequestItems,
+			RequestCoin:  req.RequestCoin,
+			Status:       entities.TradeStatusPending,
+			ExpiresAt:    now.Add(conf.TradeExpiry),
+			CreatedAt:    now,
+		}
+		if trade.OfferItems == nil {
+			trade.OfferItems = []entities.TradeItem{}
+		}
+		if trade.RequestItems == nil {
+			trade.RequestItems = []entities.TradeItem{}
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := repos.TradeRepository.AddTradeTransaction(tx, &trade); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		response.SetStatusAndJson(writer, http.StatusOK, trade)
+	}
+}
+
+// fromの所持アイテムをtoに移す。fromは各アイテムを1個以上残す必要がある
+func moveTradeItemsTransaction(tx *sql.Tx, repos *repositories.Repositories, from, to entities.UserID, tradeItems []entities.TradeItem) error {
+	var movedIDs []entities.ItemID
+	for _, tradeItem := range tradeItems {
+		userItem, err := repos.CollectionItemRepository.GetUserItemForUpdateTransaction(tx, from, tradeItem.ItemID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errTradeNotEnoughItems
+			}
+			return err
+		}
+		if userItem.Count-tradeItem.Count < 1 {
+			return errTradeNotEnoughItems
+		}
+		userItem.Count -= tradeItem.Count
+		if err := repos.CollectionItemRepository.UpdateUserItemTransaction(tx, from, userItem); err != nil {
+			return err
+		}
+		for i := entities.ItemCount(0); i < tradeItem.Count; i++ {
+			movedIDs = append(movedIDs, tradeItem.ItemID)
+		}
+	}
+	return repos.CollectionItemRepository.AddCollectionItemsTransaction(tx, to, movedIDs)
+}
+
+// トレードで渡すアイテムのIDの一覧
+func tradeItemIDs(tradeItems []entities.TradeItem) []entities.ItemID {
+	itemIDs := make([]entities.ItemID, 0, len(tradeItems))
+	for _, tradeItem := range tradeItems {
+		itemIDs = append(itemIDs, tradeItem.ItemID)
+	}
+	return itemIDs
+}
+
+// fromの無償コインをtoに移し、双方の増減を記録する
+func moveTradeCoinTransaction(tx *sql.Tx, repos *repositories.Repositories, from, to entities.UserID, coin entities.Coin) error {
+	if coin == 0 {
+		return nil
+	}
+	if err := repos.UserRepository.AddUserCoinsByIDTransaction(tx, from, -coin); err != nil {
+		return err
+	}
+	if err := repos.UserRepository.AddUserCoinsByIDTransaction(tx, to, coin); err != nil {
+		return err
+	}
+	for _, entry := range []entities.CoinLedgerEntry{
+		{UserID: from, Amount: -coin, Reason: entities.CoinLedgerReasonTrade},
+		{UserID: to, Amount: coin, Reason: entities.CoinLedgerReasonTrade},
+	} {
+		if err := repos.CoinLedgerRepository.AddCoinLedgerEntryTransaction(tx, &entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 届いたトレードを承諾する 対象のトレードIDをJSONで"tradeId": 1のように指定
+// トランザクション内でトレードと両者のユーザ・所持アイテムの行をロックし、
+// 両者が渡すアイテム・コインを持っていることを確認してから交換する
+func HandleTradeAccept(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		type acceptRequest struct {
+			TradeID entities.TradeID `json:"tradeId"`
+		}
+		var req acceptRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		items, err := repos.ItemRepository.GetItemsFromCache()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// トレードの行をロックして、自身に届いた応答待ちのトレードか確認する
+		trade, err := repos.TradeRepository.GetTradeByIDForUpdateTransaction(tx, req.TradeID)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, repositories.ErrTradeNotFound) {
+				rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if trade.ReceiverID != userID {
+			rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": repositories.ErrTradeNotFound.Error()})
+			return
+		}
+		if trade.Status != entities.TradeStatusPending {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "trade is already " + string(trade.Status)})
+			return
+		}
+		now := time.Now()
+		if trade.IsExpired(now) {
+			// 期限切れを記録してから応答する
+			if err := repos.TradeRepository.UpdateTradeStatusTransaction(tx, trade.ID, entities.TradeStatusExpired); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			if err := tx.Commit(); err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": "trade has expired"})
+			return
+		}
+
+		// デッドロックを避けるため、ユーザIDの小さい順に両者の行をロックする
+		lockOrder := []entities.UserID{trade.ProposerID, trade.ReceiverID}
+		if lockOrder[0] > lockOrder[1] {
+			lockOrder[0], lockOrder[1] = lockOrder[1], lockOrder[0]
+		}
+		users := make(map[entities.UserID]*entities.User, 2)
+		for _, id := range lockOrder {
+			user, err := repos.UserRepository.GetUserByIDForUpdateTransaction(tx, id)
+			if err != nil {
+				log.Println(err)
+				rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			users[id] = user
+		}
+		if users[trade.ProposerID].Coin < trade.OfferCoin || users[trade.ReceiverID].Coin < trade.RequestCoin {
+			rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "not enough free coin"})
+			return
+		}
+
+		// アイテムとコインを交換する
+		err = moveTradeItemsTransaction(tx, repos, trade.ProposerID, trade.ReceiverID, trade.OfferItems)
+		if err == nil {
+			err = moveTradeItemsTransaction(tx, repos, trade.ReceiverID, trade.ProposerID, trade.RequestItems)
+		}
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, errTradeNotEnoughItems) {
+				rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		err = moveTradeCoinTransaction(tx, repos, trade.ProposerID, trade.ReceiverID, trade.OfferCoin)
+		if err == nil {
+			err = moveTradeCoinTransaction(tx, repos, trade.ReceiverID, trade.ProposerID, trade.RequestCoin)
+		}
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if err := repos.TradeRepository.UpdateTradeStatusTransaction(tx, trade.ID, entities.TradeStatusAccepted); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		trade.Status = entities.TradeStatusAccepted
+
+		// 受け取ったアイテムでコレクションの達成報酬を達成した場合は、両者に報酬を付与する
+		releasedItems := items.Released(now)
+		if _, err := grantReceivedItemMilestonesTransaction(tx, repos, trade.ProposerID, releasedItems, tradeItemIDs(trade.RequestItems)); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		achieved, err := grantReceivedItemMilestonesTransaction(tx, repos, trade.ReceiverID, releasedItems, tradeItemIDs(trade.OfferItems))
+		if err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.TradeAcceptResult{Trade: *trade, AchievedMilestones: achieved})
+	}
+}
+
+// 応答待ちのトレードを終了する。拒否は受け取った側、取り消しは提案した側のみ行える
+func closeTrade(repos *repositories.Repositories, writer http.ResponseWriter, request *http.Request, status entities.TradeStatus) {
+	principal, ok := requestPrincipal(writer, request)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+
+	type closeRequest struct {
+		TradeID entities.TradeID `json:"tradeId"`
+	}
+	var req closeRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		log.Println(err)
+		response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// トランザクションの開始
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		log.Println(err)
+		response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	trade, err := repos.TradeRepository.GetTradeByIDForUpdateTransaction(tx, req.TradeID)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, repositories.ErrTradeNotFound) {
+			rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if (status == entities.TradeStatusDeclined && trade.ReceiverID != userID) ||
+		(status == entities.TradeStatusCanceled && trade.ProposerID != userID) {
+		rollbackWithError(writer, tx, http.StatusNotFound, map[string]string{"error": repositories.ErrTradeNotFound.Error()})
+		return
+	}
+	if trade.Status != entities.TradeStatusPending {
+		rollbackWithError(writer, tx, http.StatusConflict, map[string]string{"error": "trade is already " + string(trade.Status)})
+		return
+	}
+	// 期限切れのトレードは拒否・取り消しではなく期限切れとして記録する
+	if trade.IsExpired(time.Now()) {
+		status = entities.TradeStatusExpired
+	}
+
+	if err := repos.TradeRepository.UpdateTradeStatusTransaction(tx, trade.ID, status); err != nil {
+		log.Println(err)
+		rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	trade.Status = status
+
+	response.SetStatusAndJson(writer, http.StatusOK, trade)
+}
+
+// 届いたトレードを拒否する 対象のトレードIDをJSONで"tradeId": 1のように指定
+func HandleTradeDecline(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		closeTrade(repos, writer, request, entities.TradeStatusDeclined)
+	}
+}
+
+// 提案したトレードを取り消す 対象のトレードIDをJSONで"tradeId": 1のように指定
+func HandleTradeCancel(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		closeTrade(repos, writer, request, entities.TradeStatusCanceled)
+	}
+}
+
+// 自身に届いたトレード(direction=incoming)または提案したトレード(direction=outgoing)の一覧を新しい順に取得する
+// statusを指定した場合はその状態のトレードのみ取得する
+// 一覧を取得する前に、期限を過ぎた応答待ちのトレードを期限切れにする
+func HandleGetTradeList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		// 入力の受け取り
+		direction := request.URL.Query().Get("direction")
+		if direction == "" {
+			direction = "incoming"
+		}
+		if direction != "incoming" && direction != "outgoing" {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "direction must be incoming or outgoing"})
+			return
+		}
+		var status *entities.TradeStatus
+		if v := request.URL.Query().Get("status"); v != "" {
+			s := entities.TradeStatus(v)
+			switch s {
+			case entities.TradeStatusPending, entities.TradeStatusAccepted, entities.TradeStatusDeclined, entities.TradeStatusCanceled, entities.TradeStatusExpired:
+			default:
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+				return
+			}
+			status = &s
+		}
+
+		if err := repos.TradeRepository.ExpireTrades(userID, time.Now()); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		var trades []entities.Trade
+		var err error
+		if direction == "incoming" {
+			trades, err = repos.TradeRepository.GetTradesByReceiverID(userID, status)
+		} else {
+			trades, err = repos.TradeRepository.GetTradesByProposerID(userID, status)
+		}
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if trades == nil {
+			trades = []entities.Trade{}
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, entities.TradeList{Trades: trades})
+	}
+}
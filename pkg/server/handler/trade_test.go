@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+func TestValidateTradeItems(t *testing.T) {
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	released := now.Add(-time.Hour)
+	unreleased := now.Add(time.Hour)
+	itemMap := map[entities.ItemID]entities.Item{
+		1: {ID: 1, Status: entities.ItemStatusActive},
+		2: {ID: 2, Status: entities.ItemStatusRetired},
+		3: {ID: 3, Status: entities.ItemStatusHidden},
+		4: {ID: 4, Status: entities.ItemStatusActive, ReleasedAt: &released},
+		5: {ID: 5, Status: entities.ItemStatusActive, ReleasedAt: &unreleased},
+		6: {ID: 6, Status: entities.ItemStatusActive, HiddenUntilReleased: true},
+	}
+
+	tests := []struct {
+		name       string
+		tradeItems []entities.TradeItem
+		wantErr    bool
+	}{
+		{name: "アイテムなし", tradeItems: nil},
+		{name: "公開済みのアイテム", tradeItems: []entities.TradeItem{{ItemID: 1, Count: 1}, {ItemID: 4, Count: 2}}},
+		{name: "排出終了したアイテム", tradeItems: []entities.TradeItem{{ItemID: 2, Count: 1}}},
+		{name: "非表示のアイテム", tradeItems: []entities.TradeItem{{ItemID: 3, Count: 1}}, wantErr: true},
+		{name: "公開日前のアイテム", tradeItems: []entities.TradeItem{{ItemID: 5, Count: 1}}, wantErr: true},
+		{name: "公開日が決まるまで非公開のアイテム", tradeItems: []entities.TradeItem{{ItemID: 6, Count: 1}}, wantErr: true},
+		{name: "存在しないアイテム", tradeItems: []entities.TradeItem{{ItemID: 99, Count: 1}}, wantErr: true},
+		{name: "個数が0", tradeItems: []entities.TradeItem{{ItemID: 1, Count: 0}}, wantErr: true},
+		{name: "同じアイテムを重複して指定", tradeItems: []entities.TradeItem{{ItemID: 1, Count: 1}, {ItemID: 1, Count: 1}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTradeItems(tt.tradeItems, itemMap, now); (err != nil) != tt.wantErr {
+				t.Errorf("validateTradeItems() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
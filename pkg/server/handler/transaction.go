@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+)
+
+// トランザクションをロールバックしてからエラーレスポンスを返す
+func rollbackWithError(writer http.ResponseWriter, tx *sql.Tx, statusCode int, body interface{}) {
+	if err := tx.Rollback(); err != nil {
+		log.Println(err)
+	}
+	response.SetStatusAndJson(writer, statusCode, body)
+}
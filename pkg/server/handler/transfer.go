@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	httprequest "42tokyo-road-to-dojo-go/pkg/http/request"
+	"42tokyo-road-to-dojo-go/pkg/http/response"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/transfer"
+)
+
+// 機種変更用の引き継ぎIDとパスワードを発行する。以前に発行したコードは使えなくなる
+// パスワードはハッシュのみを保存するため、このレスポンスでしか確認できない
+func HandleTransferIssue(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		issued, err := transfer.Issue(repos, userID, httprequest.ClientIP(request))
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, issued)
+	}
+}
+
+// 引き継ぎIDとパスワードで新しい端末にログインする 引き継ぎIDとパスワードをJSONで"transferId": "ABCDE23456", "password": "..."のように指定
+// 成功すると新しい端末のトークンを返し、以前の端末のセッションはすべて失効する
+// 総当たりを防ぐため、失敗が続いた引き継ぎIDやIPアドレスからの試行は一定時間受け付けない
+func HandleTransferRedeem(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		type redeemRequest struct {
+			TransferID string `json:"transferId"`
+			Password   string `json:"password"`
+			DeviceName string `json:"deviceName"`
+		}
+		var req redeemRequest
+		if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		deviceName, err := auth.NormalizeDeviceName(req.DeviceName)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		result, err := transfer.Redeem(repos, conf, req.TransferID, req.Password, deviceName, httprequest.ClientIP(request))
+		if err != nil {
+			switch {
+			case errors.Is(err, transfer.ErrInvalidCredentials):
+				response.SetStatusAndJson(writer, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			case errors.Is(err, transfer.ErrTooManyFailures):
+				writer.Header().Set("Retry-After", fmt.Sprint(int(transfer.LockoutPeriod.Seconds())))
+				response.SetStatusAndJson(writer, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+			default:
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, result)
+	}
+}
@@ -2,28 +2,37 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
-	"github.com/google/uuid"
-
+	"42tokyo-road-to-dojo-go/pkg/account"
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
 	"42tokyo-road-to-dojo-go/pkg/http/response"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
 	"42tokyo-road-to-dojo-go/pkg/server/entities"
 )
 
-// ユーザ作成
-func HandleUserCreate(repos *repositories.Repositories) http.HandlerFunc {
+// ユーザ作成 ユーザと最初のセッションを作成し、トークンとその有効期限を返す
+// 端末名をJSONで"deviceName": "iPhone"のように指定できる(省略可)
+func HandleUserCreate(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		userRepo := repos.UserRepository
-		var user entities.User
-		err := json.NewDecoder(request.Body).Decode(&user)
+		type createRequest struct {
+			entities.User
+			DeviceName string `json:"deviceName"`
+		}
+		var req createRequest
+		err := json.NewDecoder(request.Body).Decode(&req)
 		if err != nil {
 			log.Println(err)
 			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
+		user := req.User
 
 		// validation
 		err = validateUser(&user)
@@ -33,19 +42,50 @@ func HandleUserCreate(repos *repositories.Repositories) http.HandlerFunc {
 			return
 		}
 
-		// トークンを生成
-		user.AuthToken = entities.AuthToken(uuid.New().String())
+		if user.AgeBracket != nil {
+			if err := validateAgeBracket(repos, *user.AgeBracket); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		deviceName, err := auth.NormalizeDeviceName(req.DeviceName)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
 
-		// ユーザを作成
-		err = userRepo.CreateUser(&user)
+		// トランザクションの開始
+		tx, err := repos.DB.Begin()
 		if err != nil {
 			log.Println(err)
 			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
 
+		// ユーザを作成
+		err = userRepo.CreateUserTransaction(tx, &user)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// セッションを作成してトークンを発行
+		issued, err := auth.CreateSessionTransaction(tx, repos, conf, user.ID, deviceName)
+		if err != nil {
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// commit
+		if err := tx.Commit(); err != nil {
+			log.Println(err)
+			rollbackWithError(writer, tx, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
 		// レスポンスヘッダにステータスコードを設定
-		response.SetStatusAndJson(writer, http.StatusOK, map[string]string{"token": string(user.AuthToken)})
+		response.SetStatusAndJson(writer, http.StatusOK, issued)
 	}
 }
 
@@ -60,17 +100,29 @@ func validateUser(user *entities.User) error {
 	return nil
 }
 
+// 年齢区分がspending_capsに登録されているか確認する
+func validateAgeBracket(repos *repositories.Repositories, ageBracket entities.AgeBracket) error {
+	caps, err := repos.SpendingCapRepository.GetSpendingCaps()
+	if err != nil {
+		return err
+	}
+	for _, spendingCap := range caps {
+		if spendingCap.AgeBracket == ageBracket {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown age bracket: %s", ageBracket)
+}
+
 // ユーザ取得 ContextからユーザIDを取得してユーザを取得する
 func HandleUserGet(repos *repositories.Repositories) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		userRepo := repos.UserRepository
-		ctx := request.Context()
-		userID, ok := ctx.Value("userID").(entities.UserID)
+		principal, ok := requestPrincipal(writer, request)
 		if !ok {
-			log.Println("userID is not found")
-			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": "userID is not found"})
 			return
 		}
+		userID := principal.UserID
 
 		// ユーザを取得
 		user, err := userRepo.GetUserByID(userID)
@@ -89,13 +141,11 @@ func HandleUserGet(repos *repositories.Repositories) http.HandlerFunc {
 func HandleUserUpdate(repos *repositories.Repositories) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		userRepo := repos.UserRepository
-		ctx := request.Context()
-		userID, ok := ctx.Value("userID").(entities.UserID)
+		principal, ok := requestPrincipal(writer, request)
 		if !ok {
-			log.Println("userID is not found")
-			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": "userID is not found"})
 			return
 		}
+		userID := principal.UserID
 
 		var user entities.User
 		err := json.NewDecoder(request.Body).Decode(&user)
@@ -113,6 +163,25 @@ func HandleUserUpdate(repos *repositories.Repositories) http.HandlerFunc {
 			return
 		}
 
+		// 年齢区分は未設定の場合のみ設定できる。名前だけ更新されないように、先に設定する
+		if user.AgeBracket != nil {
+			if err := validateAgeBracket(repos, *user.AgeBracket); err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			err = userRepo.SetUserAgeBracketByID(userID, *user.AgeBracket)
+			if err != nil {
+				log.Println(err)
+				if errors.Is(err, repositories.ErrAgeBracketAlreadySet) {
+					response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+					return
+				}
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
 		// ユーザを更新
 		user.ID = userID
 		err = userRepo.UpdateUserNameByID(userID, user.Name)
@@ -126,3 +195,43 @@ func HandleUserUpdate(repos *repositories.Repositories) http.HandlerFunc {
 		writer.WriteHeader(http.StatusOK)
 	}
 }
+
+// ユーザ削除 ユーザの削除を予約し、削除する日時を返す。予約済みの場合は予約済みの日時を返す
+// 猶予期間(-account-deletion-grace)が過ぎるまではこれまで通りAPIを使え、/user/delete/cancelで取り消せる
+func HandleUserDelete(repos *repositories.Repositories, conf *config.Config) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+
+		scheduledAt, err := account.RequestDeletion(repos, conf, principal.UserID)
+		if err != nil {
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, map[string]time.Time{"deletionScheduledAt": scheduledAt})
+	}
+}
+
+// ユーザ削除の取り消し 予約したユーザの削除を取り消す。予約していない場合は409を返す
+func HandleUserDeleteCancel(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		principal, ok := requestPrincipal(writer, request)
+		if !ok {
+			return
+		}
+
+		if err := account.CancelDeletion(repos, principal.UserID); err != nil {
+			if errors.Is(err, repositories.ErrDeletionNotScheduled) {
+				response.SetStatusAndJson(writer, http.StatusConflict, map[string]string{"error": err.Error()})
+				return
+			}
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		writer.WriteHeader(http.StatusOK)
+	}
+}
@@ -3,6 +3,8 @@ package handler
 import (
 	"log"
 	"net/http"
+	"sort"
+	"time"
 
 	"42tokyo-road-to-dojo-go/pkg/http/response"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
@@ -10,24 +12,33 @@ import (
 )
 
 // ユーザの所持アイテムを取得
-// キャッシュからItemを取得し、DBから自身の所持アイテムのIDを取得する。
-// その後、所持アイテムにはHasItemをtrueに設定する。
+// キャッシュからItemを取得し、DBから自身の所持アイテムのIDと所持数を取得する。
+// その後、所持アイテムにはHasItemをtrueに設定し、所持数と強化レベルを設定する。
+// クエリパラメータでシリーズ・カテゴリ・レアリティ・所持状況による絞り込み、並び替え、カーソルによるページングができる。
+// 絞り込みはリクエストごとにSQLを発行せず、キャッシュしたマスターデータに対してメモリ上で行う。
+// あわせて全体・レアリティ・シリーズごとの所持状況とコレクションの達成報酬の達成状況を返す。
 // このとき、キャッシュからの取得に失敗した場合はDBから取得する。
 func HandleGetCollectionList(repos *repositories.Repositories) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		itemRepo := repos.ItemRepository
 		collectionItemRepo := repos.CollectionItemRepository
 		// ContextからUserIDを取得
-		ctx := request.Context()
-		userID, ok := ctx.Value("userID").(entities.UserID)
+		principal, ok := requestPrincipal(writer, request)
 		if !ok {
-			log.Println("userID is not found")
-			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": "userID is not found"})
 			return
 		}
+		userID := principal.UserID
+		// 絞り込み・並び替え・ページングの条件を取得
+		listQuery, err := parseCollectionListQuery(request.URL.Query())
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
 		// キャッシュからアイテムを取得
 		var items *entities.Items
-		items, err := itemRepo.GetItemsFromCache()
+		items, err = itemRepo.GetItemsFromCache()
 		if err != nil {
 			log.Println(err)
 			// キャッシュからの取得に失敗した場合はDBから取得する
@@ -39,36 +50,149 @@ func HandleGetCollectionList(repos *repositories.Repositories) http.HandlerFunc
 			}
 		}
 
-		// 自身の所持アイテムのIDを取得
-		collectItemIDs, err := collectionItemRepo.GetCollectionItems(userID)
+		// 自身の所持アイテムのIDと所持数を取得
+		userItems, err := collectionItemRepo.GetUserItems(userID)
 		if err != nil {
 			log.Println(err)
 			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
 
+		// レアリティごとの最大レベルを取得するため、キャッシュから強化設定を取得
+		enhanceSettings, err := repos.ItemEnhanceSettingsRepository.GetItemEnhanceSettingsFromCache()
+		if err != nil {
+			log.Println(err)
+			// キャッシュからの取得に失敗した場合はDBから取得する
+			enhanceSettings, err = repos.ItemEnhanceSettingsRepository.GetItemEnhanceSettings()
+			if err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
 		var collectionItems []entities.CollectionItem
-		// HashMapによる高速化のため、IDｓのスライスをmapに変換する
-		collectItemIDsMap := make(map[entities.ItemID]bool)
-		for _, id := range *collectItemIDs {
-			collectItemIDsMap[id] = true
+		// HashMapによる高速化のため、所持アイテムのスライスをmapに変換する
+		userItemMap := make(map[entities.ItemID]entities.UserItem, len(userItems))
+		for _, userItem := range userItems {
+			userItemMap[userItem.ItemID] = userItem
 		}
 
-		// 所持アイテムはHasItemをtrueに設定する
-		for _, item := range *items {
-			hasItem := entities.HasItem(collectItemIDsMap[item.ID])
+		// 公開前のアイテムは一覧と達成状況に含めない
+		releasedItems := items.Released(time.Now())
+
+		// 所持アイテムはHasItemをtrueに設定し、所持数と強化レベルを設定する
+		for _, item := range releasedItems {
+			userItem := userItemMap[item.ID]
+			var maxLevel entities.ItemLevel = 1
+			if setting, ok := enhanceSettings.FindByRarity(item.Rarity); ok {
+				maxLevel = setting.MaxLevel
+			}
 			collectionItems = append(collectionItems, entities.CollectionItem{
-				ID:      item.ID,
-				Name:    item.Name,
-				Rarity:  item.Rarity,
-				HasItem: hasItem,
+				ID:                   item.ID,
+				Name:                 item.Name,
+				Rarity:               item.Rarity,
+				SeriesID:             item.SeriesID,
+				CategoryID:           item.CategoryID,
+				Description:          item.Description,
+				IconAssetKey:         item.IconAssetKey,
+				IllustrationAssetKey: item.IllustrationAssetKey,
+				FlavorText:           item.FlavorText,
+				ReleasedAt:           item.ReleasedAt,
+				RetiredAt:            item.RetiredAt,
+				HasItem:              entities.HasItem(userItem.Count > 0),
+				Count:                userItem.Count,
+				Level:                userItem.Level,
+				MaxLevel:             maxLevel,
 			})
 		}
 
+		// 条件に合うアイテムを1ページ分取り出す
+		pageItems, nextCursor, err := listQuery.apply(collectionItems)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
+		// コレクションの達成状況を集計
+		progress, err := getCollectionProgress(repos, userID, releasedItems, userItemMap)
+		if err != nil {
+			log.Println(err)
+			response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+
 		var collectionItemList entities.CollectionItemList
-		collectionItemList.Items = collectionItems
+		collectionItemList.Items = pageItems
+		collectionItemList.Progress = progress
+		collectionItemList.NextCursor = nextCursor
 
 		// レスポンスヘッダにステータスコードを設定
 		response.SetStatusAndJson(writer, http.StatusOK, collectionItemList)
 	}
 }
+
+// 全体・レアリティ・シリーズごとの所持状況と、コレクションの達成報酬の達成状況を集計する
+func getCollectionProgress(repos *repositories.Repositories, userID entities.UserID, items entities.Items, userItemMap map[entities.ItemID]entities.UserItem) (*entities.CollectionProgress, error) {
+	owned := make(map[entities.ItemID]bool, len(userItemMap))
+	for itemID, userItem := range userItemMap {
+		owned[itemID] = userItem.Count > 0
+	}
+	summary := entities.NewCollectionSummary(items, owned)
+
+	progress := &entities.CollectionProgress{
+		CollectionCount: summary.All,
+		ByRarity:        make([]entities.CollectionRarityProgress, 0, len(summary.ByRarity)),
+	}
+	for _, rarity := range []entities.Rarity{entities.N, entities.R, entities.SR} {
+		if count, ok := summary.ByRarity[rarity]; ok {
+			progress.ByRarity = append(progress.ByRarity, entities.CollectionRarityProgress{Rarity: rarity, CollectionCount: count})
+		}
+	}
+	progress.BySeries = make([]entities.CollectionSeriesProgress, 0, len(summary.BySeries))
+	for seriesID, count := range summary.BySeries {
+		progress.BySeries = append(progress.BySeries, entities.CollectionSeriesProgress{SeriesID: seriesID, CollectionCount: count})
+	}
+	sort.Slice(progress.BySeries, func(i, j int) bool { return progress.BySeries[i].SeriesID < progress.BySeries[j].SeriesID })
+
+	milestones, err := getCollectionMilestones(repos)
+	if err != nil {
+		return nil, err
+	}
+	achieved, err := repos.CollectionMilestoneRepository.GetAchievedMilestones(userID)
+	if err != nil {
+		return nil, err
+	}
+	progress.Milestones = make([]entities.CollectionMilestoneProgress, 0, len(milestones))
+	for _, milestone := range milestones {
+		milestoneProgress := entities.CollectionMilestoneProgress{CollectionMilestone: milestone}
+		if achievedAt, ok := achieved[milestone.ID]; ok {
+			milestoneProgress.Achieved = true
+			milestoneProgress.AchievedAt = &achievedAt
+		}
+		progress.Milestones = append(progress.Milestones, milestoneProgress)
+	}
+
+	return progress, nil
+}
+
+// アイテムのシリーズとカテゴリの一覧を取得する
+func HandleGetItemSeriesList(repos *repositories.Repositories) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		seriesRepo := repos.ItemSeriesRepository
+		list, err := seriesRepo.GetItemSeriesListFromCache()
+		if err != nil {
+			log.Println(err)
+			// キャッシュからの取得に失敗した場合はDBから取得する
+			list, err = seriesRepo.GetItemSeriesList()
+			if err != nil {
+				log.Println(err)
+				response.SetStatusAndJson(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		response.SetStatusAndJson(writer, http.StatusOK, list)
+	}
+}
@@ -4,34 +4,113 @@ import (
 	"log"
 	"net/http"
 
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/export"
 	"42tokyo-road-to-dojo-go/pkg/http/middleware"
 	"42tokyo-road-to-dojo-go/pkg/repositories"
 	"42tokyo-road-to-dojo-go/pkg/server/handler"
 )
 
-func Serve(addr string, repos *repositories.Repositories) {
+func Serve(addr string, repos *repositories.Repositories, conf *config.Config) {
+	// -rate-limitsで制限を指定したルートは、リクエスト元ごとに回数を制限する
+	rateLimit := func(route string, key middleware.RateLimitKey, nextFunc http.HandlerFunc) http.HandlerFunc {
+		return middleware.RateLimit(conf.RateLimiter, route, conf.RateLimits[route], key, nextFunc)
+	}
+	// 認証が必要なルートは、認証の前にIPアドレスごとに-auth-rate-limitで制限してから、認証後にユーザごとに制限する
+	// 不正なトークンを大量に送られても、トークンの照合でDBに問い合わせる前に止めるため
+	authenticate := func(route string, nextFunc http.HandlerFunc) http.HandlerFunc {
+		return middleware.RateLimit(conf.RateLimiter, "authenticated", conf.AuthRateLimit, middleware.ByIP,
+			middleware.Authenticate(repos, rateLimit(route, middleware.ByUser, nextFunc)))
+	}
+
 	/* ルーティング設定 */
 	// ゲーム設定関連
-	http.HandleFunc("/setting/get", get(handler.HandleSettingGet(repos)))
-	http.HandleFunc("/setting/all", get(handler.HandleAllSettingGet(repos)))
-	http.HandleFunc("/setting/get_by_id", get(handler.HandleGetGameSettingsByID(repos)))
+	http.HandleFunc("/setting/get", get(rateLimit("/setting/get", middleware.ByIP, handler.HandleSettingGet(repos))))
+	http.HandleFunc("/setting/all", get(rateLimit("/setting/all", middleware.ByIP, handler.HandleAllSettingGet(repos))))
+	http.HandleFunc("/setting/get_by_id", get(rateLimit("/setting/get_by_id", middleware.ByIP, handler.HandleGetGameSettingsByID(repos))))
 
 	// ユーザ関連
-	http.HandleFunc("/user/create", post(handler.HandleUserCreate(repos)))
-	http.HandleFunc("/user/get", get(middleware.Authenticate(repos, handler.HandleUserGet(repos))))
-	http.HandleFunc("/user/update", post(middleware.Authenticate(repos, handler.HandleUserUpdate(repos))))
+	http.HandleFunc("/user/create", post(rateLimit("/user/create", middleware.ByIP, handler.HandleUserCreate(repos, conf))))
+	http.HandleFunc("/user/get", get(authenticate("/user/get", handler.HandleUserGet(repos))))
+	http.HandleFunc("/user/update", post(authenticate("/user/update", handler.HandleUserUpdate(repos))))
+	http.HandleFunc("/user/delete", post(authenticate("/user/delete", handler.HandleUserDelete(repos, conf))))
+	http.HandleFunc("/user/delete/cancel", post(authenticate("/user/delete/cancel", handler.HandleUserDeleteCancel(repos))))
+	http.HandleFunc("/user/export", post(authenticate("/user/export", handler.HandleDataExportRequest(repos, conf))))
+	http.HandleFunc("/user/export/status", get(authenticate("/user/export/status", handler.HandleGetDataExportStatus(repos, conf))))
+	// ダウンロードは署名付きURLで認証する
+	http.HandleFunc(export.DownloadPath, get(rateLimit(export.DownloadPath, middleware.ByIP, handler.HandleDataExportDownload(repos, conf))))
+
+	// 認証関連
+	http.HandleFunc("/auth/refresh", post(rateLimit("/auth/refresh", middleware.ByIP, handler.HandleAuthRefresh(repos, conf))))
+	http.HandleFunc("/auth/logout_all", post(authenticate("/auth/logout_all", handler.HandleAuthLogoutAll(repos))))
+	http.HandleFunc("/session/create", post(authenticate("/session/create", handler.HandleSessionCreate(repos, conf))))
+	http.HandleFunc("/session/list", get(authenticate("/session/list", handler.HandleGetSessionList(repos))))
+	http.HandleFunc("/session/revoke", post(authenticate("/session/revoke", handler.HandleSessionRevoke(repos))))
+	http.HandleFunc("/transfer/issue", post(authenticate("/transfer/issue", handler.HandleTransferIssue(repos))))
+	http.HandleFunc("/transfer/redeem", post(rateLimit("/transfer/redeem", middleware.ByIP, handler.HandleTransferRedeem(repos, conf))))
 
 	// 所持アイテム関連
-	http.HandleFunc("/collection/list", get(middleware.Authenticate(repos, handler.HandleGetCollectionList(repos))))
+	http.HandleFunc("/collection/list", get(authenticate("/collection/list", handler.HandleGetCollectionList(repos))))
+	http.HandleFunc("/collection/series", get(authenticate("/collection/series", handler.HandleGetItemSeriesList(repos))))
+	http.HandleFunc("/item/enhance", post(authenticate("/item/enhance", handler.HandleItemEnhance(repos, conf))))
 
 	// ランキング関連
-	http.HandleFunc("/ranking/list", get(middleware.Authenticate(repos, handler.HandleGetRankingList(repos))))
+	http.HandleFunc("/ranking/list", get(authenticate("/ranking/list", handler.HandleGetRankingList(repos))))
 
 	// ゲーム関連
-	http.HandleFunc("/game/finish", post(middleware.Authenticate(repos, handler.HandleGameFinish(repos))))
+	http.HandleFunc("/game/finish", post(authenticate("/game/finish", handler.HandleGameFinish(repos))))
 
 	// ガチャ関連
-	http.HandleFunc("/gacha/draw", post(middleware.Authenticate(repos, handler.HandleGachaDraw(repos))))
+	http.HandleFunc("/gacha/draw", post(authenticate("/gacha/draw", handler.HandleGachaDraw(repos, conf))))
+	http.HandleFunc("/gacha/seed/current", get(authenticate("/gacha/seed/current", handler.HandleGetCurrentGachaSeed(repos, conf))))
+	http.HandleFunc("/gacha/seed/get", get(authenticate("/gacha/seed/get", handler.HandleGetGachaSeed(repos))))
+	http.HandleFunc("/gacha/verify", post(authenticate("/gacha/verify", handler.HandleGachaVerify(repos))))
+
+	// トレード関連
+	http.HandleFunc("/trade/propose", post(authenticate("/trade/propose", handler.HandleTradePropose(repos, conf))))
+	http.HandleFunc("/trade/accept", post(authenticate("/trade/accept", handler.HandleTradeAccept(repos))))
+	http.HandleFunc("/trade/decline", post(authenticate("/trade/decline", handler.HandleTradeDecline(repos))))
+	http.HandleFunc("/trade/cancel", post(authenticate("/trade/cancel", handler.HandleTradeCancel(repos))))
+	http.HandleFunc("/trade/list", get(authenticate("/trade/list", handler.HandleGetTradeList(repos))))
+
+	// マーケット関連
+	http.HandleFunc("/market/sell", post(authenticate("/market/sell", handler.HandleMarketSell(repos, conf))))
+	http.HandleFunc("/market/listings", get(authenticate("/market/listings", handler.HandleGetMarketListings(repos))))
+	http.HandleFunc("/market/mine", get(authenticate("/market/mine", handler.HandleGetMyMarketListings(repos))))
+	http.HandleFunc("/market/buy", post(authenticate("/market/buy", handler.HandleMarketBuy(repos))))
+	http.HandleFunc("/market/cancel", post(authenticate("/market/cancel", handler.HandleMarketCancel(repos))))
+
+	// プレゼント関連
+	http.HandleFunc("/present/list", get(authenticate("/present/list", handler.HandleGetPresentList(repos))))
+	http.HandleFunc("/present/claim", post(authenticate("/present/claim", handler.HandlePresentClaim(repos))))
+	http.HandleFunc("/present/claim_all", post(authenticate("/present/claim_all", handler.HandlePresentClaimAll(repos))))
+	http.HandleFunc("/ticket/list", get(authenticate("/ticket/list", handler.HandleGetTicketList(repos))))
+
+	// クーポン関連
+	http.HandleFunc("/coupon/redeem", post(authenticate("/coupon/redeem", handler.HandleCouponRedeem(repos))))
+
+	// ショップ関連
+	http.HandleFunc("/shop/list", get(authenticate("/shop/list", handler.HandleGetShopList(repos))))
+	http.HandleFunc("/shop/buy", post(authenticate("/shop/buy", handler.HandleShopBuy(repos, conf))))
+
+	// 課金関連
+	http.HandleFunc("/purchase/products", get(authenticate("/purchase/products", handler.HandleGetPurchaseProducts(repos, conf))))
+	http.HandleFunc("/purchase/verify", post(authenticate("/purchase/verify", handler.HandlePurchaseVerify(repos, conf))))
+	http.HandleFunc("/purchase/notify", post(rateLimit("/purchase/notify", middleware.ByIP, handler.HandlePurchaseRefundNotification(repos, conf))))
+
+	// 管理API
+	http.HandleFunc("/admin/item/status", post(middleware.AdminAuthenticate(conf, handler.HandleAdminItemStatusUpdate(repos))))
+	http.HandleFunc("/admin/item/delete", post(middleware.AdminAuthenticate(conf, handler.HandleAdminItemDelete(repos))))
+	http.HandleFunc("/admin/present/send", post(middleware.AdminAuthenticate(conf, handler.HandleAdminPresentSend(repos))))
+	http.HandleFunc("/admin/compensation/create", post(middleware.AdminAuthenticate(conf, handler.HandleAdminCompensationCreate(repos))))
+	http.HandleFunc("/admin/compensation/resume", post(middleware.AdminAuthenticate(conf, handler.HandleAdminCompensationResume(repos))))
+	http.HandleFunc("/admin/compensation/get", get(middleware.AdminAuthenticate(conf, handler.HandleAdminCompensationGet(repos))))
+	http.HandleFunc("/admin/compensation/list", get(middleware.AdminAuthenticate(conf, handler.HandleAdminCompensationList(repos))))
+	http.HandleFunc("/admin/purchase/flagged", get(middleware.AdminAuthenticate(conf, handler.HandleAdminGetFlaggedPurchases(repos))))
+	http.HandleFunc("/admin/purchase/resolve", post(middleware.AdminAuthenticate(conf, handler.HandleAdminPurchaseResolve(repos))))
+	http.HandleFunc("/admin/user/status", post(middleware.AdminAuthenticate(conf, handler.HandleAdminUserStatusUpdate(repos))))
+	http.HandleFunc("/admin/user/status/get", get(middleware.AdminAuthenticate(conf, handler.HandleAdminGetUserStatus(repos))))
+	http.HandleFunc("/admin/report/currency", get(middleware.AdminAuthenticate(conf, handler.HandleAdminCurrencyReport(repos))))
 
 	/* ===== サーバの起動 ===== */
 	log.Println("Server running...")
@@ -57,7 +136,7 @@ func httpMethod(apiFunc http.HandlerFunc, method string) http.HandlerFunc {
 
 		// CORS対応
 		writer.Header().Add("Access-Control-Allow-Origin", "*")
-		writer.Header().Add("Access-Control-Allow-Headers", "Content-Type,Accept,Origin,x-token")
+		writer.Header().Add("Access-Control-Allow-Headers", "Content-Type,Accept,Origin,x-token,x-admin-token")
 
 		// プリフライトリクエストは処理を通さない
 		if request.Method == http.MethodOptions {
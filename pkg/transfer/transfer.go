@@ -0,0 +1,185 @@
+// Package transfer 機種変更のための引き継ぎIDとパスワードの発行と、引き継ぎを行う。
+// 引き継ぐと新しい端末にセッションを作成し、以前の端末のセッションはすべて失効させる。
+package transfer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"42tokyo-road-to-dojo-go/pkg/auth"
+	"42tokyo-road-to-dojo-go/pkg/config"
+	"42tokyo-road-to-dojo-go/pkg/randcode"
+	"42tokyo-road-to-dojo-go/pkg/repositories"
+	"42tokyo-road-to-dojo-go/pkg/server/entities"
+)
+
+const (
+	transferIDLength = 10
+	// 失敗回数の制限と合わせて、パスワードは総当たりでは当てられない
+	passwordLength = randcode.SecureLength
+	saltLength     = 16
+	// 引き継ぎIDが既存のコードと重複した場合に発行し直す回数
+	maxIssueAttempts = 3
+
+	// LockoutPeriod 失敗した回数を数える期間。上限に達した場合はこの間引き継ぎを受け付けない
+	LockoutPeriod = time.Hour
+	// 1つの引き継ぎIDに対して失敗できる回数
+	maxFailuresPerTransferID = 5
+	// 1つのIPアドレスから失敗できる回数。多数の引き継ぎIDを順に試されるのを防ぐ
+	maxFailuresPerIP = 20
+	// 受け付ける入力の最大長。transfer_audit_logs.transfer_idのカラムの長さ
+	maxInputLength = 64
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid transfer id or password")
+	ErrTooManyFailures    = errors.New("too many failed attempts, try again later")
+)
+
+// Normalize 入力された引き継ぎIDやパスワードから区切りの"-"と空白を取り除き、大文字に揃える
+func Normalize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(s)))
+}
+
+func hashPassword(salt, password string) string {
+	sum := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue ユーザの引き継ぎIDとパスワードを発行する。以前に発行したコードは使えなくなる
+func Issue(repos *repositories.Repositories, userID entities.UserID, ip string) (*entities.IssuedTransferCode, error) {
+	password, err := randcode.New(passwordLength)
+	if err != nil {
+		return nil, err
+	}
+	saltBytes := make([]byte, saltLength)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return nil, err
+	}
+	salt := hex.EncodeToString(saltBytes)
+
+	for attempt := 1; ; attempt++ {
+		transferID, err := randcode.New(transferIDLength)
+		if err != nil {
+			return nil, err
+		}
+		code := entities.TransferCode{
+			UserID:       userID,
+			TransferID:   transferID,
+			PasswordSalt: salt,
+			PasswordHash: hashPassword(salt, password),
+			CreatedAt:    time.Now(),
+		}
+		err = repos.TransferRepository.ReplaceTransferCode(&code)
+		if errors.Is(err, repositories.ErrTransferCodeDuplicate) && attempt < maxIssueAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		audit(repos, &entities.TransferAuditLog{UserID: &userID, TransferID: transferID, Action: entities.TransferAuditIssue, IP: ip})
+		return &entities.IssuedTransferCode{TransferID: transferID, Password: password, CreatedAt: code.CreatedAt}, nil
+	}
+}
+
+// Redeem 引き継ぎIDとパスワードを照合し、新しい端末のセッションを作成する
+// 引き継ぎコードは1回だけ使え、以前の端末のセッションはすべて失効させる
+// 失敗が続いた引き継ぎIDやIPアドレスからの試行はErrTooManyFailuresを返す
+func Redeem(repos *repositories.Repositories, conf *config.Config, transferID, password, deviceName, ip string) (*entities.TransferResult, error) {
+	transferID = Normalize(transferID)
+	password = Normalize(password)
+	// 発行したコードと一致しえない長さの入力は照合せずに拒否する
+	if len(transferID) > maxInputLength || len(password) > maxInputLength {
+		return nil, ErrInvalidCredentials
+	}
+
+	byTransferID, byIP, err := repos.TransferRepository.GetTransferFailureCounts(transferID, ip)
+	if err != nil {
+		return nil, err
+	}
+	if byTransferID >= maxFailuresPerTransferID || byIP >= maxFailuresPerIP {
+		audit(repos, &entities.TransferAuditLog{TransferID: transferID, Action: entities.TransferAuditLocked, IP: ip})
+		return nil, ErrTooManyFailures
+	}
+
+	tx, err := repos.DB.Begin()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Println(err)
+		}
+	}()
+
+	// 同じコードで同時に引き継がれても1回だけ成功するよう、行をロックして確認する
+	code, err := repos.TransferRepository.GetTransferCodeForUpdateTransaction(tx, transferID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrTransferCodeNotFound) {
+			return nil, fail(repos, nil, transferID, ip)
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashPassword(code.PasswordSalt, password)), []byte(code.PasswordHash)) != 1 {
+		return nil, fail(repos, &code.UserID, transferID, ip)
+	}
+
+	if err := repos.TransferRepository.DeleteTransferCodeTransaction(tx, code.UserID); err != nil {
+		return nil, err
+	}
+	_, revokedHashes, err := auth.RevokeAllTransaction(tx, repos, code.UserID)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := auth.CreateSessionTransaction(tx, repos, conf, code.UserID, deviceName)
+	if err != nil {
+		return nil, err
+	}
+	err = repos.TransferRepository.AddTransferAuditLogTransaction(tx, &entities.TransferAuditLog{
+		UserID:     &code.UserID,
+		TransferID: transferID,
+		Action:     entities.TransferAuditRedeem,
+		IP:         ip,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	auth.InvalidateTokens(repos, revokedHashes)
+	return &entities.TransferResult{UserID: code.UserID, IssuedAuthToken: *issued}, nil
+}
+
+// 失敗した回数を増やして監査ログに記録し、ErrInvalidCredentialsを返す
+// 存在しない引き継ぎIDとパスワードの誤りは区別せずに返す
+func fail(repos *repositories.Repositories, userID *entities.UserID, transferID, ip string) error {
+	if err := repos.TransferRepository.AddTransferFailure(transferID, ip, LockoutPeriod); err != nil {
+		log.Println(err)
+	}
+	audit(repos, &entities.TransferAuditLog{UserID: userID, TransferID: transferID, Action: entities.TransferAuditFailure, IP: ip})
+	return ErrInvalidCredentials
+}
+
+// 監査ログに記録する。記録に失敗しても処理は続ける
+func audit(repos *repositories.Repositories, entry *entities.TransferAuditLog) {
+	if err := repos.TransferRepository.AddTransferAuditLog(entry); err != nil {
+		log.Println(err)
+	}
+}
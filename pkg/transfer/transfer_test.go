@@ -0,0 +1,22 @@
+package transfer
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "ABCDE23456", want: "ABCDE23456"},
+		{input: "abcde23456", want: "ABCDE23456"},
+		{input: "ABCD-E234-56", want: "ABCDE23456"},
+		{input: "  abcd e234\t56 \n", want: "ABCDE23456"},
+		{input: "--", want: ""},
+		{input: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}